@@ -0,0 +1,133 @@
+// achievements is a module that decides which achievements a player has
+// earned in a game. The achievements are declared in data, see
+// definitions.json, as conditions on metrics about the player's game.
+package achievements
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Metrics describing a player's part in a finished game that conditions
+// can check.
+const (
+	MetricWon           = "won"            // 1 if the player won
+	MetricSolved        = "solved"         // 1 if the player guessed the answer
+	MetricHostStumped   = "host_stumped"   // 1 if the player hosted and nobody guessed the answer
+	MetricFuzzyWin      = "fuzzy_win"      // 1 if the winning guess was only nearly right
+	MetricQuestionsUsed = "questions_used" // questions used in the game
+	MetricWrongGuesses  = "wrong_guesses"  // the player's wrong guesses in the game
+	MetricPlayers       = "players"        // players in the game, not counting the host
+	MetricPoints        = "points"         // points the player scored in the game
+	MetricStreak        = "streak"         // games won in a row, including this one
+	MetricGamesPlayed   = "games_played"   // games finished, including this one
+	MetricGamesWon      = "games_won"      // games won, including this one
+)
+
+var metrics = map[string]bool{
+	MetricWon: true, MetricSolved: true, MetricHostStumped: true, MetricFuzzyWin: true,
+	MetricQuestionsUsed: true, MetricWrongGuesses: true, MetricPlayers: true, MetricPoints: true,
+	MetricStreak: true, MetricGamesPlayed: true, MetricGamesWon: true,
+}
+
+// operators compare a metric with a condition's value.
+var operators = map[string]func(a, b float64) bool{
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+}
+
+// Condition compares one metric with a value, e.g. questions_used < 5.
+type Condition struct {
+	Metric string
+	Op     string
+	Value  float64
+}
+
+// Definition is an achievement that is unlocked once all its conditions
+// are met in a single game.
+type Definition struct {
+	ID          string
+	Name        string
+	Description string
+	Conditions  []Condition
+}
+
+// Met reports whether the metrics meet every condition.
+func (d Definition) Met(m map[string]float64) bool {
+	for _, c := range d.Conditions {
+		if !operators[c.Op](m[c.Metric], c.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Load reads achievement definitions from JSON and checks that each one
+// has a unique id and only uses known metrics and operators.
+func Load(r io.Reader) ([]Definition, error) {
+	var defs []Definition
+	if err := json.NewDecoder(r).Decode(&defs); err != nil {
+		return nil, fmt.Errorf("unable to read achievements: %w", err)
+	}
+	seen := make(map[string]bool)
+	for _, d := range defs {
+		if d.ID == "" || seen[d.ID] {
+			return nil, fmt.Errorf("achievement ids must be set and unique, got %q", d.ID)
+		}
+		seen[d.ID] = true
+		if len(d.Conditions) == 0 {
+			return nil, fmt.Errorf("achievement %s has no conditions", d.ID)
+		}
+		for _, c := range d.Conditions {
+			if !metrics[c.Metric] {
+				return nil, fmt.Errorf("achievement %s uses unknown metric %q", d.ID, c.Metric)
+			}
+			if operators[c.Op] == nil {
+				return nil, fmt.Errorf("achievement %s uses unknown operator %q", d.ID, c.Op)
+			}
+		}
+	}
+	return defs, nil
+}
+
+//go:embed definitions.json
+var definitions []byte
+
+// Default are the achievements in definitions.json.
+var Default = mustLoad(definitions)
+
+func mustLoad(b []byte) []Definition {
+	defs, err := Load(bytes.NewReader(b))
+	if err != nil {
+		panic(err)
+	}
+	return defs
+}
+
+// Earned returns the definitions whose conditions the metrics meet.
+func Earned(defs []Definition, m map[string]float64) []Definition {
+	var earned []Definition
+	for _, d := range defs {
+		if d.Met(m) {
+			earned = append(earned, d)
+		}
+	}
+	return earned
+}
+
+// Find returns the definition with the id.
+func Find(defs []Definition, id string) (Definition, bool) {
+	for _, d := range defs {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
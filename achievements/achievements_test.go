@@ -0,0 +1,72 @@
+package achievements
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultLoads(t *testing.T) {
+	if len(Default) == 0 {
+		t.Fatal("no default achievements")
+	}
+	for _, id := range []string{"quick_solver", "stumper", "unstoppable", "close_enough"} {
+		if _, ok := Find(Default, id); !ok {
+			t.Errorf("missing default achievement %s", id)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{"valid", `[{"ID": "a", "Conditions": [{"Metric": "won", "Op": "==", "Value": 1}]}]`, false},
+		{"not json", `achievements`, true},
+		{"missing id", `[{"Conditions": [{"Metric": "won", "Op": "==", "Value": 1}]}]`, true},
+		{"duplicate id", `[{"ID": "a", "Conditions": [{"Metric": "won", "Op": "==", "Value": 1}]}, {"ID": "a", "Conditions": [{"Metric": "won", "Op": "==", "Value": 1}]}]`, true},
+		{"no conditions", `[{"ID": "a"}]`, true},
+		{"unknown metric", `[{"ID": "a", "Conditions": [{"Metric": "luck", "Op": "==", "Value": 1}]}]`, true},
+		{"unknown operator", `[{"ID": "a", "Conditions": [{"Metric": "won", "Op": "~", "Value": 1}]}]`, true},
+	}
+	for _, tt := range tests {
+		if _, err := Load(strings.NewReader(tt.json)); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEarned(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics map[string]float64
+		want    []string
+	}{
+		{"lost", map[string]float64{MetricQuestionsUsed: 3, MetricGamesPlayed: 1}, nil},
+		{
+			"quick flawless win",
+			map[string]float64{MetricWon: 1, MetricSolved: 1, MetricQuestionsUsed: 4, MetricStreak: 1},
+			[]string{"first_win", "quick_solver", "flawless"},
+		},
+		{
+			"host stumps players on a streak",
+			map[string]float64{MetricWon: 1, MetricHostStumped: 1, MetricPlayers: 3, MetricQuestionsUsed: 20, MetricStreak: 10},
+			[]string{"first_win", "stumper", "hot_streak", "unstoppable"},
+		},
+		{
+			"fuzzy win",
+			map[string]float64{MetricWon: 1, MetricSolved: 1, MetricFuzzyWin: 1, MetricQuestionsUsed: 12, MetricWrongGuesses: 2},
+			[]string{"first_win", "close_enough"},
+		},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, d := range Earned(Default, tt.metrics) {
+			got = append(got, d.ID)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// replay plays back a finished game in the terminal.
+//
+//	replay -user alice -password ... -speed 4 -as bob 123
+//
+// The username and password can also be set with GAME_USERNAME and
+// GAME_PASSWORD.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// timeline is the replay of a finished game as the server sends it, only
+// what is played back is read. The server is not imported so the client
+// stays small.
+type timeline struct {
+	GameID      int64
+	Host        string
+	Category    string
+	Perspective string // the participant the game is seen as, empty for everyone's view
+	Answer      string
+	Winner      string
+	Duration    time.Duration
+	Events      []timelineEvent
+}
+
+// timelineEvent is an event of the game and when it happened.
+type timelineEvent struct {
+	Type     string
+	Username string
+	Message  string
+	Offset   time.Duration // since the game started
+	Mine     bool          // made by or to the participant the game is seen as
+}
+
+// event types sent by the server
+const (
+	eventJoined           = "joined"
+	eventLeft             = "left"
+	eventKicked           = "kicked"
+	eventBanned           = "banned"
+	eventHostChanged      = "host_changed"
+	eventAnswerSet        = "answer_set"
+	eventTurn             = "turn"
+	eventTurnSkipped      = "turn_skipped"
+	eventQuestionAsked    = "question_asked"
+	eventQuestionAnswered = "question_answered"
+	eventGuessed          = "guessed"
+	eventGameEnded        = "game_ended"
+	eventAchievement      = "achievement_unlocked"
+)
+
+func main() {
+	serverURL := flag.String("server", "http://localhost:3000", "address of the game server")
+	username := flag.String("user", os.Getenv("GAME_USERNAME"), "username to log in with")
+	password := flag.String("password", os.Getenv("GAME_PASSWORD"), "password to log in with")
+	speed := flag.Float64("speed", 1, "playback speed, 2 plays twice as fast and 0 plays without pauses")
+	maxPause := flag.Duration("max-pause", 5*time.Second, "longest pause between events, 0 for no limit")
+	as := flag.String("as", "", "see the game as this host or player")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: replay [flags] <gameID>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	gameID, err := strconv.ParseInt(flag.Arg(0), 10, 64)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "the game id must be a number")
+		os.Exit(2)
+	}
+	if *speed < 0 {
+		fmt.Fprintln(os.Stderr, "the speed cannot be negative")
+		os.Exit(2)
+	}
+
+	replay, err := fetchReplay(http.DefaultClient, *serverURL, *username, *password, gameID, *as)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	player := player{out: os.Stdout, speed: *speed, maxPause: *maxPause, sleep: time.Sleep}
+	player.play(replay)
+}
+
+// fetchReplay gets the game's timeline from the server.
+func fetchReplay(client *http.Client, serverURL, username, password string, gameID int64, as string) (timeline, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return timeline{}, fmt.Errorf("invalid server address: %w", err)
+	}
+	u = u.JoinPath("game", strconv.FormatInt(gameID, 10), "replay")
+	if as != "" {
+		u.RawQuery = url.Values{"as": {as}}.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return timeline{}, err
+	}
+	req.SetBasicAuth(username, password)
+	resp, err := client.Do(req)
+	if err != nil {
+		return timeline{}, fmt.Errorf("unable to reach the game server: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return timeline{}, fmt.Errorf("unable to read the replay: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return timeline{}, errors.New(string(body))
+	}
+	var replay timeline
+	if err := json.Unmarshal(body, &replay); err != nil {
+		return timeline{}, fmt.Errorf("unable to read the replay: %w", err)
+	}
+	return replay, nil
+}
+
+// player writes a replay out as it happened.
+type player struct {
+	out      io.Writer
+	speed    float64       // 0 plays without pauses
+	maxPause time.Duration // 0 for no limit
+	sleep    func(time.Duration)
+}
+
+// play writes the timeline one event at a time, pausing between them for
+// as long as the players did, and reveals the answer at the end.
+func (p player) play(replay timeline) {
+	fmt.Fprintf(p.out, "replaying game %d", replay.GameID)
+	if replay.Category != "" {
+		fmt.Fprintf(p.out, " (%s)", replay.Category)
+	}
+	fmt.Fprintf(p.out, " hosted by %s", replay.Host)
+	if replay.Perspective != "" {
+		fmt.Fprintf(p.out, ", seen as %s", replay.Perspective)
+	}
+	fmt.Fprintln(p.out)
+
+	var last time.Duration
+	for i, e := range replay.Events {
+		if i > 0 {
+			p.pause(e.Offset - last)
+		}
+		last = e.Offset
+		marker := " "
+		if e.Mine {
+			marker = "*"
+		}
+		fmt.Fprintf(p.out, "[%s]%s %s\n", clock(e.Offset), marker, describe(e))
+	}
+
+	p.pause(time.Second)
+	fmt.Fprintf(p.out, "the answer was %s\n", replay.Answer)
+	if replay.Winner != "" {
+		fmt.Fprintf(p.out, "%s won after %s\n", replay.Winner, clock(replay.Duration))
+	}
+}
+
+// pause waits for the time between two events at the playback speed.
+func (p player) pause(d time.Duration) {
+	if p.speed == 0 || d <= 0 {
+		return
+	}
+	d = time.Duration(float64(d) / p.speed)
+	if p.maxPause > 0 && d > p.maxPause {
+		d = p.maxPause
+	}
+	p.sleep(d)
+}
+
+// clock formats the time since the game started as minutes and seconds.
+func clock(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// describe returns the event as a line of the timeline.
+func describe(e timelineEvent) string {
+	switch e.Type {
+	case eventJoined:
+		return e.Username + " joined"
+	case eventLeft:
+		return e.Username + " left"
+	case eventKicked, eventBanned:
+		line := fmt.Sprintf("%s was %s", e.Username, e.Type)
+		if e.Message != "" {
+			line += ": " + e.Message
+		}
+		return line
+	case eventHostChanged:
+		return e.Message
+	case eventAnswerSet:
+		line := e.Username + " chose the answer"
+		if e.Message != "" {
+			line += ": " + e.Message
+		}
+		return line
+	case eventTurn:
+		return e.Message
+	case eventTurnSkipped:
+		return e.Username + " ran out of time to ask a question"
+	case eventQuestionAsked:
+		return fmt.Sprintf("%s asked: %s", e.Username, e.Message)
+	case eventQuestionAnswered:
+		return fmt.Sprintf("%s answered: %s", e.Username, e.Message)
+	case eventGuessed:
+		return fmt.Sprintf("%s guessed: %s", e.Username, e.Message)
+	case eventGameEnded:
+		return "the game ended: " + e.Message
+	case eventAchievement:
+		return fmt.Sprintf("%s unlocked %s", e.Username, e.Message)
+	default:
+		return fmt.Sprintf("%s %s %s", e.Type, e.Username, e.Message)
+	}
+}
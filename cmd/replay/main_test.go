@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/soypete/golang-cli-game/server"
+)
+
+var testReplay = timeline{
+	GameID:      12,
+	Host:        "alice",
+	Category:    "animals",
+	Perspective: "bob",
+	Answer:      "a cat",
+	Winner:      "bob",
+	Duration:    75 * time.Second,
+	Events: []timelineEvent{
+		{Type: eventJoined, Username: "bob", Offset: 5 * time.Second, Mine: true},
+		{Type: eventQuestionAsked, Username: "bob", Message: "is it a pet?", Offset: 25 * time.Second, Mine: true},
+		{Type: eventQuestionAnswered, Username: "alice", Message: "yes", Offset: 65 * time.Second},
+		{Type: eventGameEnded, Username: "bob", Message: "solved", Offset: 75 * time.Second},
+	},
+}
+
+func TestPlay(t *testing.T) {
+	tests := []struct {
+		name     string
+		speed    float64
+		maxPause time.Duration
+		want     []time.Duration
+	}{
+		{"real time", 1, 0, []time.Duration{20 * time.Second, 40 * time.Second, 10 * time.Second, time.Second}},
+		{"twice as fast", 2, 0, []time.Duration{10 * time.Second, 20 * time.Second, 5 * time.Second, 500 * time.Millisecond}},
+		{"long pauses are cut short", 1, 15 * time.Second, []time.Duration{15 * time.Second, 15 * time.Second, 10 * time.Second, time.Second}},
+		{"no pauses", 0, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var pauses []time.Duration
+			p := player{out: &out, speed: tt.speed, maxPause: tt.maxPause, sleep: func(d time.Duration) {
+				pauses = append(pauses, d)
+			}}
+			p.play(testReplay)
+			if len(pauses) != len(tt.want) {
+				t.Fatalf("got pauses %v want %v", pauses, tt.want)
+			}
+			for i := range pauses {
+				if pauses[i] != tt.want[i] {
+					t.Errorf("got pauses %v want %v", pauses, tt.want)
+				}
+			}
+			want := `replaying game 12 (animals) hosted by alice, seen as bob
+[00:05]* bob joined
+[00:25]* bob asked: is it a pet?
+[01:05]  alice answered: yes
+[01:15]  the game ended: solved
+the answer was a cat
+bob won after 01:15
+`
+			if out.String() != want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+			}
+		})
+	}
+}
+
+func TestFetchReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		if r.URL.Path != "/game/12/replay" || r.URL.Query().Get("as") != "bob" || username != "bob" || password != "secret" {
+			http.Error(w, "Bad Request, unexpected request", http.StatusBadRequest)
+			return
+		}
+		// sent the way the server sends it
+		json.NewEncoder(w).Encode(server.Replay{
+			GameID:      12,
+			Host:        "alice",
+			Perspective: "bob",
+			Answer:      "a cat",
+			Events: []server.ReplayEvent{
+				{Event: server.Event{Type: server.EventJoined, Username: "bob"}, Offset: 5 * time.Second, Mine: true},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	replay, err := fetchReplay(srv.Client(), srv.URL, "bob", "secret", 12, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := timelineEvent{Type: eventJoined, Username: "bob", Offset: 5 * time.Second, Mine: true}
+	if replay.Answer != "a cat" || replay.Perspective != "bob" || len(replay.Events) != 1 || replay.Events[0] != want {
+		t.Errorf("got %+v", replay)
+	}
+	if _, err := fetchReplay(srv.Client(), srv.URL, "bob", "secret", 13, ""); err == nil {
+		t.Error("expected the server's error to be returned")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		event timelineEvent
+		want  string
+	}{
+		{timelineEvent{Type: eventTurnSkipped, Username: "bob", Message: "ran out of time to ask a question"}, "bob ran out of time to ask a question"},
+		{timelineEvent{Type: eventAchievement, Username: "bob", Message: "First Win: Win a game as a player or the host"}, "bob unlocked First Win: Win a game as a player or the host"},
+		{timelineEvent{Type: eventKicked, Username: "bob", Message: "spam"}, "bob was kicked: spam"},
+	}
+	for _, tt := range tests {
+		if got := describe(tt.event); got != tt.want {
+			t.Errorf("got %q want %q", got, tt.want)
+		}
+	}
+}
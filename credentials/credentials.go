@@ -0,0 +1,136 @@
+// credentials is a module that generates usernames and passwords with a
+// cryptographically secure random source.
+package credentials
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Character classes passwords are built from. Easily confused characters
+// such as l, 1, O and 0 are left out so generated passwords can be typed.
+const (
+	lower   = "abcdefghijkmnopqrstuvwxyz"
+	upper   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	digits  = "23456789"
+	symbols = "!@#$%^&*()_+-="
+)
+
+// PasswordPolicy describes what passwords must look like. Generated
+// passwords are Length long and use every required class, and chosen
+// passwords must be at least Length long and contain every required class.
+type PasswordPolicy struct {
+	Length        int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// DefaultPolicy is a 16 character password using every class.
+var DefaultPolicy = PasswordPolicy{
+	Length:        16,
+	RequireLower:  true,
+	RequireUpper:  true,
+	RequireDigit:  true,
+	RequireSymbol: true,
+}
+
+// classes returns the character classes the policy requires.
+func (p PasswordPolicy) classes() []string {
+	var classes []string
+	if p.RequireLower {
+		classes = append(classes, lower)
+	}
+	if p.RequireUpper {
+		classes = append(classes, upper)
+	}
+	if p.RequireDigit {
+		classes = append(classes, digits)
+	}
+	if p.RequireSymbol {
+		classes = append(classes, symbols)
+	}
+	return classes
+}
+
+// Validate checks that passwords can be generated with the policy.
+func (p PasswordPolicy) Validate() error {
+	classes := p.classes()
+	if len(classes) == 0 {
+		return errors.New("password policy must require at least one character class")
+	}
+	if p.Length < 8 {
+		return errors.New("password policy length must be at least 8")
+	}
+	if p.Length < len(classes) {
+		return fmt.Errorf("password policy length must be at least %d to fit every class", len(classes))
+	}
+	return nil
+}
+
+// Check reports why a chosen password does not meet the policy.
+func (p PasswordPolicy) Check(password string) error {
+	if len([]rune(password)) < p.Length {
+		return fmt.Errorf("password must be at least %d characters", p.Length)
+	}
+	var missing []string
+	check := func(required bool, name string, ok func(rune) bool) {
+		if required && strings.IndexFunc(password, ok) < 0 {
+			missing = append(missing, name)
+		}
+	}
+	check(p.RequireLower, "a lowercase letter", func(r rune) bool { return r >= 'a' && r <= 'z' })
+	check(p.RequireUpper, "an uppercase letter", func(r rune) bool { return r >= 'A' && r <= 'Z' })
+	check(p.RequireDigit, "a digit", func(r rune) bool { return r >= '0' && r <= '9' })
+	check(p.RequireSymbol, "a symbol", func(r rune) bool {
+		return !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9')
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("password must contain %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// randInt returns a uniformly random number in [0, n).
+func randInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("unable to generate random number: %w", err)
+	}
+	return int(i.Int64()), nil
+}
+
+// Password returns a random password that meets the policy.
+func Password(p PasswordPolicy) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	classes := p.classes()
+	all := strings.Join(classes, "")
+	password := make([]byte, p.Length)
+	for i := range password {
+		// the first characters make sure every class is used, the
+		// order is shuffled below
+		set := all
+		if i < len(classes) {
+			set = classes[i]
+		}
+		n, err := randInt(len(set))
+		if err != nil {
+			return "", err
+		}
+		password[i] = set[n]
+	}
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
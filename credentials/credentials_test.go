@@ -0,0 +1,102 @@
+package credentials
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPassword(t *testing.T) {
+	policies := []PasswordPolicy{
+		DefaultPolicy,
+		{Length: 8, RequireDigit: true},
+		{Length: 32, RequireLower: true, RequireSymbol: true},
+	}
+	for _, p := range policies {
+		for i := 0; i < 50; i++ {
+			password, err := Password(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(password) != p.Length {
+				t.Errorf("Password(%+v) = %q, want length %d", p, password, p.Length)
+			}
+			if err := p.Check(password); err != nil {
+				t.Errorf("Password(%+v) = %q does not meet the policy: %v", p, password, err)
+			}
+		}
+	}
+}
+
+func TestPasswordInvalidPolicy(t *testing.T) {
+	policies := []PasswordPolicy{
+		{},
+		{Length: 16},
+		{Length: 4, RequireLower: true},
+	}
+	for _, p := range policies {
+		if _, err := Password(p); err == nil {
+			t.Errorf("Password(%+v) should fail", p)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"Sup3r-Secret-Pass", true},
+		{"Sh0rt!", false},
+		{"alllowercase1234!", false},
+		{"NoDigitsInHere!!!", false},
+		{"NoSymbolsInHere12", false},
+	}
+	for _, tt := range tests {
+		err := DefaultPolicy.Check(tt.password)
+		if (err == nil) != tt.ok {
+			t.Errorf("Check(%q) = %v, want ok %v", tt.password, err, tt.ok)
+		}
+	}
+}
+
+func TestUsername(t *testing.T) {
+	pattern := regexp.MustCompile(`^[A-Z][a-z]+[A-Z][a-z]+[0-9]{4}$`)
+	for i := 0; i < 50; i++ {
+		username, err := Username()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pattern.MatchString(username) {
+			t.Errorf("Username() = %q, want adjective+noun+number", username)
+		}
+	}
+}
+
+func TestUniqueUsername(t *testing.T) {
+	taken := errors.New("taken")
+	var tried []string
+	username, err := UniqueUsername(5, taken, func(username string) error {
+		tried = append(tried, username)
+		if len(tried) < 3 {
+			return taken
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tried) != 3 || username != tried[2] {
+		t.Errorf("got %q after trying %v, want the third username", username, tried)
+	}
+
+	_, err = UniqueUsername(5, taken, func(string) error { return taken })
+	if err == nil || !strings.Contains(err.Error(), "5 attempts") {
+		t.Errorf("expected to give up after 5 attempts, got %v", err)
+	}
+	dbErr := errors.New("db down")
+	if _, err := UniqueUsername(5, taken, func(string) error { return dbErr }); err != dbErr {
+		t.Errorf("expected the create error, got %v", err)
+	}
+}
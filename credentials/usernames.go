@@ -0,0 +1,64 @@
+package credentials
+
+import (
+	"fmt"
+)
+
+var adjectives = []string{
+	"Amber", "Ancient", "Bold", "Brave", "Bright", "Calm", "Clever", "Cosmic", "Crimson", "Curious",
+	"Daring", "Dizzy", "Eager", "Electric", "Fancy", "Fearless", "Fierce", "Frosty", "Fuzzy", "Gentle",
+	"Giant", "Golden", "Grumpy", "Happy", "Hidden", "Humble", "Icy", "Jolly", "Lucky", "Mighty",
+	"Misty", "Mystic", "Neon", "Nimble", "Noble", "Plucky", "Quick", "Quiet", "Rapid", "Rusty",
+	"Shadow", "Shiny", "Silent", "Silver", "Sleepy", "Sneaky", "Stormy", "Sunny", "Swift", "Witty",
+}
+
+var nouns = []string{
+	"Badger", "Beaver", "Bison", "Comet", "Coyote", "Dragon", "Eagle", "Falcon", "Ferret", "Gecko",
+	"Giant", "Gopher", "Griffin", "Hawk", "Hedgehog", "Heron", "Hunter", "Jaguar", "Knight", "Koala",
+	"Lemur", "Leopard", "Lynx", "Mage", "Marmot", "Moose", "Narwhal", "Ninja", "Otter", "Owl",
+	"Panda", "Panther", "Phoenix", "Pirate", "Puffin", "Raccoon", "Raven", "Rocket", "Sage", "Siren",
+	"Sparrow", "Sphinx", "Squid", "Tiger", "Toucan", "Walrus", "Wizard", "Wolf", "Wombat", "Yeti",
+}
+
+// usernameNumbers is how many numbers are added to the end of a generated
+// username, which with the word lists gives 25 million usernames.
+const usernameNumbers = 10000
+
+// Username returns a random username made of an adjective, a noun and a
+// number, e.g. BraveOtter0427.
+func Username() (string, error) {
+	a, err := randInt(len(adjectives))
+	if err != nil {
+		return "", err
+	}
+	n, err := randInt(len(nouns))
+	if err != nil {
+		return "", err
+	}
+	num, err := randInt(usernameNumbers)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s%04d", adjectives[a], nouns[n], num), nil
+}
+
+// UniqueUsername generates usernames until create accepts one, giving up
+// after attempts tries. create should return taken when the username
+// already exists.
+func UniqueUsername(attempts int, taken error, create func(username string) error) (string, error) {
+	for i := 0; i < attempts; i++ {
+		username, err := Username()
+		if err != nil {
+			return "", err
+		}
+		err = create(username)
+		if err == taken {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return username, nil
+	}
+	return "", fmt.Errorf("unable to find a free username after %d attempts", attempts)
+}
@@ -0,0 +1,197 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrUsernameTaken is returned when registering or renaming a user with a
+// username that is already in use.
+var ErrUsernameTaken = errors.New("username is taken")
+
+// UsernameChange records a user renaming themselves.
+type UsernameChange struct {
+	OldUsername string    `db:"old_username"`
+	NewUsername string    `db:"new_username"`
+	ChangedAt   time.Time `db:"changed_at"`
+}
+
+// usernameReferences are the columns outside the users table that refer to
+// a user by username. Questions and guesses refer to the user id so they
+// follow a rename on their own.
+var usernameReferences = []string{
+	`UPDATE games SET host = $2 WHERE host = $1`,
+	`UPDATE games SET players = array_replace(players, $1, $2) WHERE $1 = ANY(players)`,
+	`UPDATE games SET turn_player = $2 WHERE turn_player = $1`,
+	`UPDATE game_removals SET username = $2 WHERE username = $1`,
+	`UPDATE game_removals SET removed_by = $2 WHERE removed_by = $1`,
+	`UPDATE game_invites SET created_by = $2 WHERE created_by = $1`,
+	`UPDATE game_standings SET username = $2 WHERE username = $1`,
+	`UPDATE user_stats SET username = $2 WHERE username = $1`,
+	`UPDATE leaderboard SET username = $2 WHERE username = $1`,
+	`UPDATE rating_history SET username = $2 WHERE username = $1`,
+	`UPDATE user_achievements SET username = $2 WHERE username = $1`,
+	`UPDATE game_events SET username = $2 WHERE username = $1`,
+	`UPDATE game_events SET data = jsonb_set(data, '{By}', to_jsonb($2::TEXT)) WHERE data->>'By' = $1`,
+}
+
+// renameUser changes the username everywhere it is used.
+func renameUser(tx *sqlx.Tx, oldUsername, newUsername string) error {
+	var taken bool
+	err := tx.Get(&taken, `SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)`, newUsername)
+	if err != nil {
+		return fmt.Errorf("unable to check username %s: %w", newUsername, err)
+	}
+	if taken {
+		return ErrUsernameTaken
+	}
+	if _, err := tx.Exec(`UPDATE users SET username = $2 WHERE username = $1`, oldUsername, newUsername); err != nil {
+		return fmt.Errorf("unable to rename user %s: %w", oldUsername, err)
+	}
+	for _, query := range usernameReferences {
+		if _, err := tx.Exec(query, oldUsername, newUsername); err != nil {
+			return fmt.Errorf("unable to rename user %s: %w", oldUsername, err)
+		}
+	}
+	return nil
+}
+
+// RenameUser changes the user's username, keeping their games, stats and
+// history, and records the old name.
+func (c *Client) RenameUser(oldUsername, newUsername string) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to rename user %s: %w", oldUsername, err)
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.Get(&userID, `SELECT id FROM users WHERE username = $1 AND deleted_at IS NULL FOR UPDATE`, oldUsername)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := renameUser(tx, oldUsername, newUsername); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO username_history (user_id, old_username, new_username) VALUES ($1, $2, $3)`,
+		userID, oldUsername, newUsername)
+	if err != nil {
+		return fmt.Errorf("unable to record rename of %s: %w", oldUsername, err)
+	}
+	return tx.Commit()
+}
+
+// GetUsernameHistory returns the usernames the user has had, oldest first.
+func (c *Client) GetUsernameHistory(username string) ([]UsernameChange, error) {
+	var history []UsernameChange
+	err := c.db.Select(&history, `SELECT h.old_username, h.new_username, h.changed_at
+					FROM username_history h JOIN users u ON u.id = h.user_id
+					WHERE u.username = $1 ORDER BY h.changed_at, h.id`, username)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get username history of %s: %w", username, err)
+	}
+	return history, nil
+}
+
+// RestoreUser undoes the deletion of the user if it happened less than
+// window ago and the password matches.
+func (c *Client) RestoreUser(username, password string, window time.Duration) error {
+	results, err := c.db.Exec(`UPDATE users SET deleted_at = NULL
+					WHERE username = $1 AND password = $2
+						AND deleted_at > NOW() - make_interval(secs => $3)`,
+		username, password, window.Seconds())
+	if err != nil {
+		return fmt.Errorf("unable to restore user %s: %w", username, err)
+	}
+	if n, err := results.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// AnonymizeDeletedUsers removes everything that identifies users deleted
+// more than window ago. Their games are kept for the other players, with
+// the user renamed to deleted-<id>. It returns how many users were
+// anonymized.
+func (c *Client) AnonymizeDeletedUsers(window time.Duration) (int, error) {
+	var users []struct {
+		ID       int64  `db:"id"`
+		Username string `db:"username"`
+	}
+	err := c.db.Select(&users, `SELECT id, username FROM users
+					WHERE NOT anonymized AND deleted_at < NOW() - make_interval(secs => $1)`, window.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("unable to list deleted users: %w", err)
+	}
+	for i, u := range users {
+		if err := c.anonymizeUser(u.ID, u.Username); err != nil {
+			return i, err
+		}
+	}
+	return len(users), nil
+}
+
+func (c *Client) anonymizeUser(userID int64, username string) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to anonymize user %d: %w", userID, err)
+	}
+	defer tx.Rollback()
+
+	if err := renameUser(tx, username, fmt.Sprintf("deleted-%d", userID)); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE users SET anonymized = true, password = '', display_name = '', avatar = '', bio = '',
+						last_seen = NULL, admin = false
+					WHERE id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("unable to anonymize user %d: %w", userID, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM username_history WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("unable to remove username history of user %d: %w", userID, err)
+	}
+	return tx.Commit()
+}
+
+// GetUserGames returns every game the user hosted, played or finished in,
+// oldest first.
+func (c *Client) GetUserGames(username string) ([]Game, error) {
+	var gameIDs []int64
+	err := c.db.Select(&gameIDs, `SELECT id FROM games
+					WHERE host = $1 OR $1 = ANY(players)
+						OR id IN (SELECT game_id FROM game_standings WHERE username = $1)
+					ORDER BY start_time, id`, username)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get games of %s: %w", username, err)
+	}
+	games := make([]Game, 0, len(gameIDs))
+	for _, id := range gameIDs {
+		game, err := c.GetGameData(id)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, game)
+	}
+	return games, nil
+}
+
+// GetUserQuestions returns every question the user has asked, oldest first.
+func (c *Client) GetUserQuestions(username string) ([]Question, error) {
+	questions, err := c.selectQuestions("u.username = $1", username)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get questions of %s: %w", username, err)
+	}
+	return questions, nil
+}
+
+// GetUserGuesses returns every guess the user has made, oldest first.
+func (c *Client) GetUserGuesses(username string) ([]Guess, error) {
+	guesses, err := c.selectGuesses("u.username = $1", username)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get guesses of %s: %w", username, err)
+	}
+	return guesses, nil
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Achievement is an achievement a user has unlocked.
+type Achievement struct {
+	Username      string    `db:"username"`
+	AchievementID string    `db:"achievement_id"`
+	GameID        int64     `db:"game_id"` // the game it was unlocked in
+	UnlockedAt    time.Time `db:"unlocked_at"`
+}
+
+// UnlockAchievements records that the user unlocked the achievements in the
+// game and returns the ids of those they did not already have. The newly
+// unlocked achievements are added to the game's history.
+func (c *Client) UnlockAchievements(username string, gameID int64, ids []string) ([]string, error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("unable to unlock achievements for %s: %w", username, err)
+	}
+	defer tx.Rollback()
+
+	var unlocked []string
+	err = tx.Select(&unlocked, `INSERT INTO user_achievements (username, achievement_id, game_id)
+					SELECT $1, id, $2 FROM unnest($3::VARCHAR[]) AS id
+					ON CONFLICT (username, achievement_id) DO NOTHING
+					RETURNING achievement_id`, username, gameID, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("unable to unlock achievements for %s: %w", username, err)
+	}
+	for _, id := range unlocked {
+		if err := appendGameEvent(tx, gameID, GameEventAchievement, username, GameEventData{Achievement: id}); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to unlock achievements for %s: %w", username, err)
+	}
+	return unlocked, nil
+}
+
+// GetUserAchievements returns the achievements the user has unlocked,
+// oldest first.
+func (c *Client) GetUserAchievements(username string) ([]Achievement, error) {
+	var unlocked []Achievement
+	err := c.db.Select(&unlocked, `SELECT username, achievement_id, game_id, unlocked_at
+					FROM user_achievements WHERE username = $1 ORDER BY unlocked_at, achievement_id`, username)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get achievements of %s: %w", username, err)
+	}
+	return unlocked, nil
+}
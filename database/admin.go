@@ -0,0 +1,159 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUserBanned is returned when a banned user logs in with the right
+// password.
+var ErrUserBanned = errors.New("user is banned")
+
+// UserFilter narrows down the users listed by ListUsers.
+type UserFilter struct {
+	Search         string // part of the username or display name
+	Banned         bool   // only banned users
+	IncludeDeleted bool   // include deleted users that have not been anonymized yet
+	Limit          int
+	Offset         int
+}
+
+// GameFilter narrows down the games listed by ListGames.
+type GameFilter struct {
+	Player string // host or player of the game
+	Ended  *bool  // nil lists games whether or not they have ended
+	Limit  int
+	Offset int
+}
+
+// defaultListLimit is how many rows are listed when no limit is given.
+const defaultListLimit = 50
+
+func listLimit(limit int) int {
+	if limit <= 0 || limit > 500 {
+		return defaultListLimit
+	}
+	return limit
+}
+
+// ListUsers returns the users matching the filter, newest first.
+func (c *Client) ListUsers(filter UserFilter) ([]User, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) int {
+		args = append(args, v)
+		return len(args)
+	}
+	if !filter.IncludeDeleted {
+		where = append(where, "deleted_at IS NULL")
+	}
+	if filter.Banned {
+		where = append(where, "banned_at IS NOT NULL")
+	}
+	if filter.Search != "" {
+		n := arg("%" + escapeLike(strings.ToLower(filter.Search)) + "%")
+		where = append(where, fmt.Sprintf("(LOWER(username) LIKE $%d OR LOWER(display_name) LIKE $%d)", n, n))
+	}
+	query := "SELECT " + userColumns + " FROM users"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", arg(listLimit(filter.Limit)), arg(filter.Offset))
+
+	var users []User
+	if err := c.db.Select(&users, query, args...); err != nil {
+		return nil, fmt.Errorf("unable to list users: %w", err)
+	}
+	return users, nil
+}
+
+// GetUserIncludingDeleted returns the user's profile like GetUserData,
+// including users that deleted their account and have not been anonymized
+// yet.
+func (c *Client) GetUserIncludingDeleted(username string) (User, error) {
+	var user User
+	err := c.db.Get(&user, "SELECT "+userColumns+" FROM users WHERE username = $1;", username)
+	if err == sql.ErrNoRows {
+		return user, ErrUserNotFound
+	}
+	if err != nil {
+		return user, fmt.Errorf("failed to get user %s from db: %w", username, err)
+	}
+	return user, nil
+}
+
+// ListGames returns the games matching the filter, newest first.
+func (c *Client) ListGames(filter GameFilter) ([]Game, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) int {
+		args = append(args, v)
+		return len(args)
+	}
+	if filter.Player != "" {
+		n := arg(filter.Player)
+		where = append(where, fmt.Sprintf("(host = $%d OR $%d = ANY(players))", n, n))
+	}
+	if filter.Ended != nil {
+		where = append(where, fmt.Sprintf("ended = $%d", arg(*filter.Ended)))
+	}
+	query := "SELECT id FROM games"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY start_time DESC, id DESC LIMIT $%d OFFSET $%d", arg(listLimit(filter.Limit)), arg(filter.Offset))
+
+	var gameIDs []int64
+	if err := c.db.Select(&gameIDs, query, args...); err != nil {
+		return nil, fmt.Errorf("unable to list games: %w", err)
+	}
+	games := make([]Game, 0, len(gameIDs))
+	for _, id := range gameIDs {
+		game, err := c.GetGameData(id)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, game)
+	}
+	return games, nil
+}
+
+// ResetPassword sets a new password for the user.
+func (c *Client) ResetPassword(username, password string) error {
+	results, err := c.db.Exec(`UPDATE users SET password = $2 WHERE username = $1 AND deleted_at IS NULL`, username, password)
+	if err != nil {
+		return fmt.Errorf("unable to reset password of %s: %w", username, err)
+	}
+	if n, err := results.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// BanUser stops the user from logging in until they are unbanned.
+func (c *Client) BanUser(username, reason string) error {
+	results, err := c.db.Exec(`UPDATE users SET banned_at = COALESCE(banned_at, NOW()), ban_reason = $2
+					WHERE username = $1 AND deleted_at IS NULL`, username, reason)
+	if err != nil {
+		return fmt.Errorf("unable to ban %s: %w", username, err)
+	}
+	if n, err := results.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// UnbanUser lets a banned user log in again.
+func (c *Client) UnbanUser(username string) error {
+	results, err := c.db.Exec(`UPDATE users SET banned_at = NULL, ban_reason = ''
+					WHERE username = $1 AND deleted_at IS NULL`, username)
+	if err != nil {
+		return fmt.Errorf("unable to unban %s: %w", username, err)
+	}
+	if n, err := results.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditEntry is a record of a security relevant or game changing action.
+// Entries are never changed once they are recorded, they are only removed
+// by PruneAudit once they are older than the retention period.
+type AuditEntry struct {
+	ID        int64  `db:"id"`
+	Actor     string `db:"actor"`  // the user or admin, or the address of an allowlisted request
+	Action    string `db:"action"` // e.g. admin.user.ban
+	Target    string `db:"target"` // the user or game acted on
+	Detail    string `db:"detail"`
+	RequestID string `db:"request_id"`
+	IP        string `db:"ip"` // the address that connected, which may be a proxy
+	// ForwardedFor is the client address a trusted proxy forwarded the
+	// request for, empty if it did not come through one.
+	ForwardedFor string    `db:"forwarded_for"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// AuditFilter narrows down the entries listed by ListAudit.
+type AuditFilter struct {
+	Actor  string
+	Action string // actions starting with this, e.g. admin. or login.
+	Target string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+}
+
+// RecordAudit adds the entry to the audit log.
+func (c *Client) RecordAudit(entry AuditEntry) error {
+	_, err := c.db.Exec(`INSERT INTO audit_log (actor, action, target, detail, request_id, ip, forwarded_for)
+					VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entry.Actor, entry.Action, entry.Target, entry.Detail, entry.RequestID, entry.IP, entry.ForwardedFor)
+	if err != nil {
+		return fmt.Errorf("unable to record %s by %s in the audit log: %w", entry.Action, entry.Actor, err)
+	}
+	return nil
+}
+
+// ListAudit returns the audit log entries matching the filter, newest first.
+func (c *Client) ListAudit(filter AuditFilter) ([]AuditEntry, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) int {
+		args = append(args, v)
+		return len(args)
+	}
+	if filter.Actor != "" {
+		where = append(where, fmt.Sprintf("actor = $%d", arg(filter.Actor)))
+	}
+	if filter.Action != "" {
+		where = append(where, fmt.Sprintf("action LIKE $%d", arg(escapeLike(filter.Action)+"%")))
+	}
+	if filter.Target != "" {
+		where = append(where, fmt.Sprintf("target = $%d", arg(filter.Target)))
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, fmt.Sprintf("created_at >= $%d", arg(filter.Since)))
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, fmt.Sprintf("created_at < $%d", arg(filter.Until)))
+	}
+	query := "SELECT id, actor, action, target, detail, request_id, ip, forwarded_for, created_at FROM audit_log"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", arg(listLimit(filter.Limit)), arg(filter.Offset))
+
+	var entries []AuditEntry
+	if err := c.db.Select(&entries, query, args...); err != nil {
+		return nil, fmt.Errorf("unable to list audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// PruneAudit removes audit log entries older than the retention period and
+// returns how many were removed.
+func (c *Client) PruneAudit(retention time.Duration) (int, error) {
+	results, err := c.db.Exec(`DELETE FROM audit_log WHERE created_at < NOW() - make_interval(secs => $1)`, retention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("unable to prune audit log: %w", err)
+	}
+	n, err := results.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("unable to count pruned audit log entries: %w", err)
+	}
+	return int(n), nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
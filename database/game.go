@@ -1,8 +1,15 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
 	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // Game represents a game in the database.
@@ -10,21 +17,130 @@ type Game struct {
 	GameID        int64    `db:"id"`
 	Host          string   `db:"host"`
 	Players       []string `db:"players"` // TODO: only 5 players allowed per game
-	Answer        string   `db:"answer"`  // TODO: answer validation should account for capitalization and spelling errors.. maybe use a Levenshtein distance algorithm
+	Answer        string   `db:"answer"`
 	QuestionCount int64
+	WrongGuesses  int64
 	Questions     []Question `db:"questions"`
 	Guesses       []Guess    `db:"guesses"`
 	StartTime     time.Time  `db:"start_time"`
-	EndTime       time.Time  `db:"end_time, omitempty""`
+	EndTime       time.Time  `db:"end_time"`
 	Ended         bool       `db:"ended"`
+	Outcome       string     `db:"outcome"`     // how the game ended, empty while playing
+	Private       bool       `db:"private"`     // private games can only be joined with an invite
+	InviteCode    string     `db:"invite_code"` // secret code that lets anyone join a private game
+	Category      string     `db:"category"`    // what kind of thing the answer is, e.g. animals
+	Rules         Rules      `db:"rules"`
+	TurnPlayer    string     `db:"turn_player"`   // whose turn it is to ask when playing round robin
+	TurnDeadline  time.Time  `db:"turn_deadline"` // zero when the turn has no time limit
+	// PendingQuestion is set while a question is waiting for the host to answer it.
+	PendingQuestion bool       `db:"-"`
+	Removals        []Removal  `db:"-"`
+	Standings       []Standing `db:"-"` // final scores, set once the game has ended
+}
+
+// LogValue logs the game without its answer or invite code.
+func (g Game) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("id", g.GameID),
+		slog.String("host", g.Host),
+		slog.Any("players", g.Players),
+		slog.Bool("private", g.Private),
+		slog.String("category", g.Category),
+		slog.Bool("ended", g.Ended),
+		slog.String("outcome", g.Outcome),
+	)
+}
+
+// DefaultMaxPlayers is the number of players allowed in a game, including
+// the host, unless the rules say otherwise.
+const DefaultMaxPlayers = 5
+
+// Game phases, see Game.Phase.
+const (
+	PhaseStarting   = "starting"    // the host has not set the answer yet
+	PhaseInProgress = "in_progress" // players are asking questions
+	PhaseFinished   = "finished"    // someone guessed the answer or the game was stopped
+)
+
+// QuestionsUsed returns how many of the game's questions have been used,
+// counting wrong guesses if the rules say they cost a question.
+func (g Game) QuestionsUsed() int64 {
+	if g.Rules.WrongGuessCosts {
+		return g.QuestionCount + g.WrongGuesses
+	}
+	return g.QuestionCount
+}
+
+// QuestionsLeft returns how many questions the players can still ask.
+func (g Game) QuestionsLeft() int64 {
+	left := int64(g.Rules.WithDefaults().MaxQuestions) - g.QuestionsUsed()
+	if left < 0 {
+		return 0
+	}
+	return left
 }
 
+// Phase returns what stage the game is at.
+func (g Game) Phase() string {
+	switch {
+	case g.Ended:
+		return PhaseFinished
+	case g.Answer == "":
+		return PhaseStarting
+	default:
+		return PhaseInProgress
+	}
+}
+
+// GameSettings are chosen by the host when a game is created.
+type GameSettings struct {
+	Private    bool
+	InviteCode string // required for private games
+	Category   string
+	Rules      Rules
+}
+
+// Removal records a player that the host kicked or banned from a game.
+type Removal struct {
+	RemovalID int64     `db:"id"`
+	GameID    int64     `db:"game_id"`
+	Username  string    `db:"username"`   // the user that was removed
+	RemovedBy string    `db:"removed_by"` // the user that removed them
+	Reason    string    `db:"reason"`
+	Banned    bool      `db:"banned"` // banned users cannot rejoin the game
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// ErrBanned is returned when a user tries to join a game they are banned from.
+var ErrBanned = errors.New("user is banned from this game")
+
+// Game outcomes stored when a game ends.
+const (
+	OutcomeSolved   = "solved"    // a player guessed the answer
+	OutcomeStopped  = "stopped"   // the host stopped the game
+	OutcomeHostLeft = "host_left" // the host left after questions were asked
+	OutcomeStumped  = "stumped"   // the players ran out of questions or guesses
+	OutcomeTimeUp   = "time_up"   // the game ran past its time limit
+)
+
+// ErrGameFull is returned when a user tries to join a game that already
+// has as many players as its rules allow.
+var ErrGameFull = errors.New("game is full")
+
+// ErrGameNotFound is returned when a game does not exist.
+var ErrGameNotFound = errors.New("game not found")
+
+// ErrGameEnded is returned when ending a game that has already ended.
+var ErrGameEnded = errors.New("game has already ended")
+
 // Question represents a question in the database.
 type Question struct {
 	QuestionID   string
 	QuestionText string
+	Answer       string // the host's answer, empty until answered
 	UserID       string // the user who asked the question
 	GameID       string // the game the question is associated with
+	AskedAt      time.Time
 }
 
 // Guess represents a guess in the database. This is a user's guess of the answer.
@@ -34,57 +150,534 @@ type Guess struct {
 	UserID    string // the user who made the guess
 	GameID    string // the game the guess is associated with
 	Correct   bool   // whether the guess is correct or not
+	GuessedAt time.Time
 }
 
 // CreateGame starts a new game for the user with the given username
 // as the host. A new game is created and the user is added to the game.
 // The game id is returned, or an error if one occurs.
-func (c *Client) CreateGame(username string) (int64, error) {
-	query := `INSERT INTO games (host, players)
-					VALUES ($1, $1)`
-	results, err := c.db.Exec(query, username)
+func (c *Client) CreateGame(username string, settings GameSettings) (int64, error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("unable to create game instance: %w", err)
+	}
+	defer tx.Rollback()
+
+	// postgres does not support LastInsertId, so the id is returned by the
+	// query. The game is filled in from its created event.
+	var gameID int64
+	err = tx.Get(&gameID, `INSERT INTO games (host, answer) VALUES ('', '') RETURNING id`)
 	if err != nil {
 		return 0, fmt.Errorf("unable to create game instance: %w", err)
 	}
-	gameID, err := results.LastInsertId()
+	rules := settings.Rules.WithDefaults()
+	err = appendGameEvent(tx, gameID, GameEventCreated, username, GameEventData{
+		Private:    settings.Private,
+		InviteCode: settings.InviteCode,
+		Category:   settings.Category,
+		Rules:      &rules,
+	})
 	if err != nil {
-		return 0, fmt.Errorf("unable to get game id: %w", err)
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("unable to create game instance: %w", err)
 	}
 	return gameID, nil
 }
 
 // AddUserToGame adds the user with the given username to the game with the
-// given game id. An error is returned if one occurs.
+// given game id. ErrBanned or ErrGameFull is returned if the user cannot
+// join the game, or another error if one occurs.
 func (c *Client) AddUserToGame(username string, gameID int64) error {
-	// TODO: check if game is full or started. If so, return an error. We shouldn't add a user if answer guessing has already started.
-	query := `UPDATE games
-					SET players = array_append(players, $1)
-					WHERE game_id = $2`
-	_, err := c.db.Exec(query, username, gameID)
+	return c.joinGame(username, gameID, "")
+}
+
+// joinGame adds the user to the players of the game, using up one use of
+// the invite with the given token if there is one. The invite is only
+// used if the user joins.
+func (c *Client) joinGame(username string, gameID int64, token string) error {
+	tx, err := c.db.Beginx()
 	if err != nil {
 		return fmt.Errorf("unable to add user to game: %w", err)
 	}
-	return nil
+	defer tx.Rollback()
+
+	// lock the game first so a ban cannot be committed between checking
+	// for it and joining
+	var players []string
+	var maxPlayers int
+	err = tx.QueryRow(`SELECT players, COALESCE((rules->>'MaxPlayers')::INTEGER, $2) FROM games WHERE id = $1 FOR UPDATE`,
+		gameID, DefaultMaxPlayers).Scan(pq.Array(&players), &maxPlayers)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrGameNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("unable to add user to game: %w", err)
+	}
+	var banned bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM game_removals WHERE game_id = $1 AND username = $2 AND banned)`,
+		gameID, username).Scan(&banned)
+	if err != nil {
+		return fmt.Errorf("unable to check bans: %w", err)
+	}
+	if banned {
+		return ErrBanned
+	}
+	if len(players) >= maxPlayers {
+		return ErrGameFull
+	}
+	if token != "" {
+		if err := useInvite(tx, gameID, token); err != nil {
+			return err
+		}
+	}
+	if err := appendGameEvent(tx, gameID, GameEventJoined, username, GameEventData{}); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// GetGameData returns the game info for the game with the given game id.
+// GetGameData returns the game info for the game with the given game id,
+// as projected from its history by appendGameEvent.
 func (c *Client) GetGameData(gameID int64) (Game, error) {
-	query := `SELECT * FROM games WHERE game_id = $1`
+	query := `SELECT id, host, players, COALESCE(answer, ''), start_time, end_time, ended, COALESCE(outcome, ''),
+					private, COALESCE(invite_code, ''), COALESCE(category, ''),
+					rules, COALESCE(turn_player, ''), turn_deadline,
+					(SELECT COUNT(*) FROM questions WHERE game_id = games.id),
+					(SELECT COUNT(*) FROM guesses WHERE game_id = games.id AND NOT correct),
+					EXISTS (SELECT 1 FROM questions WHERE game_id = games.id AND answer IS NULL)
+					FROM games WHERE id = $1`
 	var game Game
-	err := c.db.QueryRowx(query, gameID).StructScan(&game)
+	var endTime, turnDeadline sql.NullTime
+	err := c.db.QueryRow(query, gameID).Scan(&game.GameID, &game.Host, pq.Array(&game.Players),
+		&game.Answer, &game.StartTime, &endTime, &game.Ended, &game.Outcome,
+		&game.Private, &game.InviteCode, &game.Category,
+		&game.Rules, &game.TurnPlayer, &turnDeadline,
+		&game.QuestionCount, &game.WrongGuesses, &game.PendingQuestion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Game{}, ErrGameNotFound
+	}
 	if err != nil {
 		return Game{}, fmt.Errorf("unable to get game info: %w", err)
 	}
+	game.EndTime = endTime.Time
+	game.TurnDeadline = turnDeadline.Time
 	return game, nil
 }
 
+// GetGameQuestions returns every question asked in the game with the given
+// game id in the order they were asked. The UserID of each question is the
+// username of the player who asked it.
+func (c *Client) GetGameQuestions(gameID int64) ([]Question, error) {
+	questions, err := c.selectQuestions("q.game_id = $1", gameID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get questions for game %d: %w", gameID, err)
+	}
+	return questions, nil
+}
+
+// selectQuestions returns the questions matching the where clause in the
+// order they were asked.
+func (c *Client) selectQuestions(where string, args ...interface{}) ([]Question, error) {
+	query := `SELECT q.id, q.question, COALESCE(q.answer, ''), u.username, q.game_id, q.asked_at
+					FROM questions q JOIN users u ON u.id = q.user_id
+					WHERE ` + where + `
+					ORDER BY q.asked_at, q.id`
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var questions []Question
+	for rows.Next() {
+		var q Question
+		if err := rows.Scan(&q.QuestionID, &q.QuestionText, &q.Answer, &q.UserID, &q.GameID, &q.AskedAt); err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+// GetGameGuesses returns every guess made in the game with the given
+// game id in the order they were made. The UserID of each guess is the
+// username of the player who guessed.
+func (c *Client) GetGameGuesses(gameID int64) ([]Guess, error) {
+	guesses, err := c.selectGuesses("g.game_id = $1", gameID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get guesses for game %d: %w", gameID, err)
+	}
+	return guesses, nil
+}
+
+// selectGuesses returns the guesses matching the where clause in the order
+// they were made.
+func (c *Client) selectGuesses(where string, args ...interface{}) ([]Guess, error) {
+	query := `SELECT g.id, g.guess, u.username, g.game_id, g.correct, g.guessed_at
+					FROM guesses g JOIN users u ON u.id = g.user_id
+					WHERE ` + where + `
+					ORDER BY g.guessed_at, g.id`
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var guesses []Guess
+	for rows.Next() {
+		var g Guess
+		if err := rows.Scan(&g.GuessID, &g.GuessText, &g.UserID, &g.GameID, &g.Correct, &g.GuessedAt); err != nil {
+			return nil, err
+		}
+		guesses = append(guesses, g)
+	}
+	return guesses, rows.Err()
+}
+
+// StopGame ends the game with the given game id on behalf of the host.
 func (c *Client) StopGame(gameID int64) error {
-	query := `UPDATE games
-					SET ended = true, end_time = NOW()
-					WHERE game_id = $1`
-	_, err := c.db.Exec(query, gameID)
+	return c.EndGame(gameID, OutcomeStopped, "")
+}
+
+// EndGame ends the game with the given game id, recording how it ended
+// and who won, if anyone did. Games that have already ended keep their
+// original outcome and ErrGameEnded is returned, ErrGameNotFound is
+// returned for games that do not exist.
+func (c *Client) EndGame(gameID int64, outcome, winner string) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to stop game: %w", err)
+	}
+	defer tx.Rollback()
+
+	var ended bool
+	err = tx.Get(&ended, `SELECT ended FROM games WHERE id = $1 FOR UPDATE`, gameID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrGameNotFound
+	}
 	if err != nil {
 		return fmt.Errorf("unable to stop game: %w", err)
 	}
-	return nil
+	if ended {
+		return ErrGameEnded
+	}
+	if err := appendGameEvent(tx, gameID, GameEventEnded, winner, GameEventData{Outcome: outcome}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveUserFromGame removes the user with the given username from the
+// players of the game with the given game id.
+func (c *Client) RemoveUserFromGame(username string, gameID int64) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to remove user from game: %w", err)
+	}
+	defer tx.Rollback()
+
+	var playing bool
+	err = tx.Get(&playing, `SELECT $1 = ANY(players) FROM games WHERE id = $2 FOR UPDATE`, username, gameID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to remove user from game: %w", err)
+	}
+	if !playing {
+		return nil
+	}
+	if err := appendGameEvent(tx, gameID, GameEventLeft, username, GameEventData{}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveFromGame removes the user from the players of the game and records
+// who removed them and why. Banned users are kept out of the game by
+// AddUserToGame.
+func (c *Client) RemoveFromGame(gameID int64, username, removedBy, reason string, ban bool) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to remove user from game: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = appendGameEvent(tx, gameID, GameEventRemoved, username, GameEventData{By: removedBy, Reason: reason, Banned: ban})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetGameRemovals returns the players kicked or banned from the game with
+// the given game id, oldest first.
+func (c *Client) GetGameRemovals(gameID int64) ([]Removal, error) {
+	var removals []Removal
+	err := c.db.Select(&removals, `SELECT id, game_id, username, removed_by, reason, banned, created_at
+					FROM game_removals WHERE game_id = $1 ORDER BY created_at, id`, gameID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get removals for game %d: %w", gameID, err)
+	}
+	return removals, nil
+}
+
+// TransferHost makes newHost the host of the game with the given game id
+// and removes the previous host from the players. The answer is kept so
+// the new host can carry on with it.
+func (c *Client) TransferHost(gameID int64, newHost string) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to transfer host of game %d: %w", gameID, err)
+	}
+	defer tx.Rollback()
+
+	var oldHost string
+	err = tx.Get(&oldHost, `SELECT host FROM games WHERE id = $1 AND $2 = ANY(players) FOR UPDATE`, gameID, newHost)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s is not a player in game %d", newHost, gameID)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to transfer host of game %d: %w", gameID, err)
+	}
+	if err := appendGameEvent(tx, gameID, GameEventHostChanged, newHost, GameEventData{By: oldHost}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetAnswer sets the secret answer the players are trying to guess.
+func (c *Client) SetAnswer(gameID int64, answer string) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to set answer for game %d: %w", gameID, err)
+	}
+	defer tx.Rollback()
+
+	var host string
+	err = tx.Get(&host, `SELECT host FROM games WHERE id = $1 FOR UPDATE`, gameID)
+	if err != nil {
+		return fmt.Errorf("unable to set answer for game %d: %w", gameID, err)
+	}
+	if err := appendGameEvent(tx, gameID, GameEventAnswerSet, host, GameEventData{Answer: answer}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddQuestion stores a question asked by the user with the given username.
+// If answerSeconds is positive the host has that long to answer before
+// ExpireQuestions answers it for them.
+// The question id is returned, or an error if one occurs.
+func (c *Client) AddQuestion(gameID int64, username, question string, answerSeconds int) (int64, error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("unable to add question to game %d: %w", gameID, err)
+	}
+	defer tx.Rollback()
+
+	// the question is stored from its event, which needs its id
+	var questionID int64
+	if err := tx.Get(&questionID, `SELECT nextval(pg_get_serial_sequence('questions', 'id'))`); err != nil {
+		return 0, fmt.Errorf("unable to add question to game %d: %w", gameID, err)
+	}
+	deadline, err := deadlineIn(tx, answerSeconds)
+	if err != nil {
+		return 0, fmt.Errorf("unable to add question to game %d: %w", gameID, err)
+	}
+	data := GameEventData{QuestionID: questionID, Text: question, Deadline: deadline}
+	if err := appendGameEvent(tx, gameID, GameEventQuestionAsked, username, data); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("unable to add question to game %d: %w", gameID, err)
+	}
+	return questionID, nil
+}
+
+// AnswerQuestion stores the host's answer to a question in the game.
+func (c *Client) AnswerQuestion(gameID, questionID int64, answer string) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to answer question %d: %w", questionID, err)
+	}
+	defer tx.Rollback()
+
+	var host string
+	err = tx.Get(&host, `SELECT g.host FROM questions q JOIN games g ON g.id = q.game_id
+					WHERE q.id = $1 AND q.game_id = $2 FOR UPDATE OF g`, questionID, gameID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("question %d does not exist in game %d", questionID, gameID)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get host of game %d: %w", gameID, err)
+	}
+	err = appendGameEvent(tx, gameID, GameEventQuestionAnswered, host, GameEventData{QuestionID: questionID, Answer: answer})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddGuess stores a guess of the answer made by the user with the given username.
+func (c *Client) AddGuess(gameID int64, username, guess string, correct bool) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to add guess to game %d: %w", gameID, err)
+	}
+	defer tx.Rollback()
+
+	// the guess is stored from its event, which needs its id
+	var guessID int64
+	err = tx.Get(&guessID, `SELECT nextval(pg_get_serial_sequence('guesses', 'id'))
+					FROM users WHERE username = $1`, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to add guess to game %d: %w", gameID, err)
+	}
+	err = appendGameEvent(tx, gameID, GameEventGuessed, username, GameEventData{GuessID: guessID, Text: guess, Correct: correct})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetTurn gives the turn to ask a question to the user with the given
+// username. If seconds is positive the turn ends after that long, see
+// ListExpiredTurns. An empty username means it is nobody's turn.
+func (c *Client) SetTurn(gameID int64, username string, seconds int) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to set turn for game %d: %w", gameID, err)
+	}
+	defer tx.Rollback()
+
+	if err := setTurn(tx, gameID, username, seconds); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SkipTurn records that the user ran out of time to ask a question and
+// gives the turn to next, as SetTurn does.
+func (c *Client) SkipTurn(gameID int64, username, next string, seconds int) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to skip turn for game %d: %w", gameID, err)
+	}
+	defer tx.Rollback()
+
+	if err := appendGameEvent(tx, gameID, GameEventTurnSkipped, username, GameEventData{}); err != nil {
+		return err
+	}
+	if err := setTurn(tx, gameID, next, seconds); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setTurn(tx *sqlx.Tx, gameID int64, username string, seconds int) error {
+	deadline, err := deadlineIn(tx, seconds)
+	if err != nil {
+		return fmt.Errorf("unable to set turn for game %d: %w", gameID, err)
+	}
+	return appendGameEvent(tx, gameID, GameEventTurn, username, GameEventData{Deadline: deadline})
+}
+
+// deadlineIn returns the time the given number of seconds from now, by the
+// database's clock, or nil if seconds is not positive.
+func deadlineIn(tx *sqlx.Tx, seconds int) (*time.Time, error) {
+	if seconds <= 0 {
+		return nil, nil
+	}
+	var deadline time.Time
+	if err := tx.Get(&deadline, `SELECT NOW() + make_interval(secs => $1)`, seconds); err != nil {
+		return nil, err
+	}
+	return &deadline, nil
+}
+
+// ListExpiredTurns returns the ids of games that are still being played
+// where the current player ran out of time to ask their question.
+func (c *Client) ListExpiredTurns() ([]int64, error) {
+	var gameIDs []int64
+	err := c.db.Select(&gameIDs, `SELECT id FROM games
+					WHERE NOT ended AND turn_deadline IS NOT NULL AND turn_deadline < NOW()`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list expired turns: %w", err)
+	}
+	return gameIDs, nil
+}
+
+// NoAnswer is stored as the answer to questions the host ran out of time
+// to answer.
+const NoAnswer = "no answer"
+
+// ExpireQuestions answers every question whose answer deadline has passed
+// with NoAnswer and returns them.
+func (c *Client) ExpireQuestions() ([]Question, error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("unable to expire questions: %w", err)
+	}
+	defer tx.Rollback()
+
+	var expired []struct {
+		QuestionID   int64     `db:"id"`
+		QuestionText string    `db:"question"`
+		GameID       int64     `db:"game_id"`
+		AskedAt      time.Time `db:"asked_at"`
+		Host         string    `db:"host"`
+	}
+	err = tx.Select(&expired, `SELECT q.id, q.question, q.game_id, q.asked_at, g.host
+					FROM questions q JOIN games g ON g.id = q.game_id
+					WHERE NOT g.ended
+					AND q.answer IS NULL AND q.deadline IS NOT NULL AND q.deadline < NOW()
+					FOR UPDATE OF q`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to expire questions: %w", err)
+	}
+	questions := make([]Question, 0, len(expired))
+	for _, q := range expired {
+		err := appendGameEvent(tx, q.GameID, GameEventQuestionAnswered, q.Host, GameEventData{QuestionID: q.QuestionID, Answer: NoAnswer})
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, Question{
+			QuestionID:   strconv.FormatInt(q.QuestionID, 10),
+			QuestionText: q.QuestionText,
+			Answer:       NoAnswer,
+			GameID:       strconv.FormatInt(q.GameID, 10),
+			AskedAt:      q.AskedAt,
+		})
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to expire questions: %w", err)
+	}
+	return questions, nil
+}
+
+// CountActiveGames returns the number of games that have not ended.
+func (c *Client) CountActiveGames() (int, error) {
+	var n int
+	err := c.db.Get(&n, `SELECT COUNT(*) FROM games WHERE NOT ended`)
+	if err != nil {
+		return 0, fmt.Errorf("unable to count active games: %w", err)
+	}
+	return n, nil
+}
+
+// ListExpiredGames returns the ids of games that are still being played
+// past the time limit in their rules.
+func (c *Client) ListExpiredGames() ([]int64, error) {
+	var gameIDs []int64
+	err := c.db.Select(&gameIDs, `SELECT id FROM games
+					WHERE NOT ended AND COALESCE((rules->>'TimeLimitSeconds')::INTEGER, 0) > 0
+					AND start_time + make_interval(secs => (rules->>'TimeLimitSeconds')::INTEGER) < NOW()`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list expired games: %w", err)
+	}
+	return gameIDs, nil
 }
@@ -0,0 +1,295 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Game event types. Every change to a game is stored as one of these in
+// the order it happened, see FoldGame.
+const (
+	GameEventCreated          = "created"
+	GameEventJoined           = "joined"
+	GameEventLeft             = "left"
+	GameEventRemoved          = "removed"
+	GameEventHostChanged      = "host_changed"
+	GameEventAnswerSet        = "answer_set"
+	GameEventTurn             = "turn"
+	GameEventQuestionAsked    = "question_asked"
+	GameEventQuestionAnswered = "question_answered"
+	GameEventGuessed          = "guessed"
+	GameEventEnded            = "ended"
+	GameEventTurnSkipped      = "turn_skipped"
+	GameEventAchievement      = "achievement_unlocked"
+)
+
+// GameEvent is a change to a game. The events of a game are never changed
+// once they are stored, except to follow a user being renamed.
+type GameEvent struct {
+	ID        int64         `db:"id"`
+	GameID    int64         `db:"game_id"`
+	Seq       int64         `db:"seq"` // position in the game's history, starting at 1
+	Type      string        `db:"type"`
+	Username  string        `db:"username"` // the user that made the change, or that it was made to
+	Data      GameEventData `db:"data"`
+	CreatedAt time.Time     `db:"created_at"`
+}
+
+// GameEventData is what changed, only the fields used by the event's type
+// are set.
+type GameEventData struct {
+	Private     bool       `json:",omitempty"` // created
+	InviteCode  string     `json:",omitempty"` // created
+	Category    string     `json:",omitempty"` // created
+	Rules       *Rules     `json:",omitempty"` // created
+	Answer      string     `json:",omitempty"` // answer_set, or the host's answer for question_answered
+	QuestionID  int64      `json:",omitempty"` // question_asked and question_answered
+	GuessID     int64      `json:",omitempty"` // guessed
+	Text        string     `json:",omitempty"` // the question or guess
+	Correct     bool       `json:",omitempty"` // guessed
+	By          string     `json:",omitempty"` // who removed the user, or the previous host
+	Reason      string     `json:",omitempty"` // removed
+	Banned      bool       `json:",omitempty"` // removed
+	Deadline    *time.Time `json:",omitempty"` // turn and question_asked
+	Outcome     string     `json:",omitempty"` // ended
+	Achievement string     `json:",omitempty"` // achievement_unlocked, the id of the achievement
+}
+
+// Value stores the data as JSON.
+func (d GameEventData) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+// Scan reads data stored as JSON.
+func (d *GameEventData) Scan(src interface{}) error {
+	var data GameEventData
+	switch v := src.(type) {
+	case nil:
+	case []byte:
+		if err := json.Unmarshal(v, &data); err != nil {
+			return err
+		}
+	case string:
+		if err := json.Unmarshal([]byte(v), &data); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot scan %T into game event data", src)
+	}
+	*d = data
+	return nil
+}
+
+// appendGameEvent adds the event to the end of its game's history and
+// applies it to the game, see projectGameEvent. The game is locked until
+// the transaction ends so the events of a game are numbered in the order
+// they were stored.
+func appendGameEvent(tx *sqlx.Tx, gameID int64, eventType, username string, data GameEventData) error {
+	if _, err := tx.Exec(`SELECT id FROM games WHERE id = $1 FOR UPDATE`, gameID); err != nil {
+		return fmt.Errorf("unable to lock game %d: %w", gameID, err)
+	}
+	e := GameEvent{GameID: gameID, Type: eventType, Username: username, Data: data}
+	err := tx.QueryRow(`INSERT INTO game_events (game_id, seq, type, username, data)
+					SELECT $1, COALESCE(MAX(seq), 0) + 1, $2, $3, $4 FROM game_events WHERE game_id = $1
+					RETURNING seq, created_at`,
+		gameID, eventType, username, data).Scan(&e.Seq, &e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("unable to record %s event of game %d: %w", eventType, gameID, err)
+	}
+	if err := projectGameEvent(tx, e); err != nil {
+		return fmt.Errorf("unable to apply %s event to game %d: %w", eventType, gameID, err)
+	}
+	return nil
+}
+
+// projectGameEvent applies the event to the game's row and to its
+// questions, guesses and removals, the same way applyGameEvent does when
+// folding the history. They are only changed here, so the history is the
+// source of truth and the tables are kept so games can be queried without
+// folding them.
+func projectGameEvent(tx *sqlx.Tx, e GameEvent) error {
+	var results sql.Result
+	var err error
+	switch e.Type {
+	case GameEventCreated:
+		rules := DefaultRules()
+		if e.Data.Rules != nil {
+			rules = e.Data.Rules.WithDefaults()
+		}
+		results, err = tx.Exec(`UPDATE games
+					SET host = $2, players = ARRAY[$2], start_time = $3, private = $4,
+					invite_code = NULLIF($5, ''), category = NULLIF($6, ''), rules = $7
+					WHERE id = $1`,
+			e.GameID, e.Username, e.CreatedAt, e.Data.Private, e.Data.InviteCode, e.Data.Category, rules)
+	case GameEventJoined:
+		results, err = tx.Exec(`UPDATE games SET players = array_append(players, $2) WHERE id = $1`, e.GameID, e.Username)
+	case GameEventLeft:
+		results, err = tx.Exec(`UPDATE games SET players = array_remove(players, $2) WHERE id = $1`, e.GameID, e.Username)
+	case GameEventRemoved:
+		_, err = tx.Exec(`INSERT INTO game_removals (game_id, username, removed_by, reason, banned, created_at)
+					VALUES ($1, $2, $3, $4, $5, $6)`, e.GameID, e.Username, e.Data.By, e.Data.Reason, e.Data.Banned, e.CreatedAt)
+		if err != nil {
+			return err
+		}
+		results, err = tx.Exec(`UPDATE games SET players = array_remove(players, $2) WHERE id = $1`, e.GameID, e.Username)
+	case GameEventHostChanged:
+		results, err = tx.Exec(`UPDATE games SET players = array_remove(players, host), host = $2 WHERE id = $1`, e.GameID, e.Username)
+	case GameEventAnswerSet:
+		results, err = tx.Exec(`UPDATE games SET answer = $2 WHERE id = $1`, e.GameID, e.Data.Answer)
+	case GameEventTurn:
+		results, err = tx.Exec(`UPDATE games SET turn_player = NULLIF($2, ''), turn_deadline = $3 WHERE id = $1`,
+			e.GameID, e.Username, e.Data.Deadline)
+	case GameEventQuestionAsked:
+		results, err = tx.Exec(`INSERT INTO questions (id, question, user_id, game_id, deadline, asked_at)
+					SELECT $2, $3, id, $1, $4, $5 FROM users WHERE username = $6`,
+			e.GameID, e.Data.QuestionID, e.Data.Text, e.Data.Deadline, e.CreatedAt, e.Username)
+	case GameEventQuestionAnswered:
+		results, err = tx.Exec(`UPDATE questions SET answer = $3 WHERE id = $2 AND game_id = $1`,
+			e.GameID, e.Data.QuestionID, e.Data.Answer)
+	case GameEventGuessed:
+		results, err = tx.Exec(`INSERT INTO guesses (id, guess, user_id, game_id, correct, guessed_at)
+					SELECT $2, $3, id, $1, $4, $5 FROM users WHERE username = $6`,
+			e.GameID, e.Data.GuessID, e.Data.Text, e.Data.Correct, e.CreatedAt, e.Username)
+	case GameEventEnded:
+		results, err = tx.Exec(`UPDATE games SET ended = true, end_time = $2, outcome = $3 WHERE id = $1`,
+			e.GameID, e.CreatedAt, e.Data.Outcome)
+	default:
+		// turn_skipped and achievement_unlocked do not change the game
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := results.RowsAffected(); err == nil && n == 0 {
+		return errors.New("nothing to apply it to")
+	}
+	return nil
+}
+
+const gameEventColumns = `id, game_id, seq, type, username, data, created_at`
+
+// GetGameEvents returns the history of the game with the given game id
+// after the given sequence number, oldest first. An afterSeq of 0 returns
+// the whole history.
+func (c *Client) GetGameEvents(gameID, afterSeq int64) ([]GameEvent, error) {
+	var events []GameEvent
+	err := c.db.Select(&events, `SELECT `+gameEventColumns+` FROM game_events
+					WHERE game_id = $1 AND seq > $2 ORDER BY seq`, gameID, afterSeq)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get events of game %d: %w", gameID, err)
+	}
+	return events, nil
+}
+
+// FoldGame rebuilds a game from its history. Folding the events up to any
+// point gives the game as it was at that point. Standings are not part of
+// the history and are left empty. Games started before their history was
+// recorded have no created event and cannot be rebuilt this way.
+func FoldGame(events []GameEvent) Game {
+	var game Game
+	for _, e := range events {
+		game = applyGameEvent(game, e)
+	}
+	return game
+}
+
+func applyGameEvent(game Game, e GameEvent) Game {
+	gameID := strconv.FormatInt(e.GameID, 10)
+	switch e.Type {
+	case GameEventCreated:
+		game = Game{
+			GameID:     e.GameID,
+			Host:       e.Username,
+			Players:    []string{e.Username},
+			StartTime:  e.CreatedAt,
+			Private:    e.Data.Private,
+			InviteCode: e.Data.InviteCode,
+			Category:   e.Data.Category,
+			Rules:      DefaultRules(),
+		}
+		if e.Data.Rules != nil {
+			game.Rules = e.Data.Rules.WithDefaults()
+		}
+	case GameEventJoined:
+		game.Players = append(game.Players, e.Username)
+	case GameEventLeft:
+		game.Players = without(game.Players, e.Username)
+	case GameEventRemoved:
+		game.Players = without(game.Players, e.Username)
+		game.Removals = append(game.Removals, Removal{
+			GameID:    e.GameID,
+			Username:  e.Username,
+			RemovedBy: e.Data.By,
+			Reason:    e.Data.Reason,
+			Banned:    e.Data.Banned,
+			CreatedAt: e.CreatedAt,
+		})
+	case GameEventHostChanged:
+		game.Players = without(game.Players, game.Host)
+		game.Host = e.Username
+	case GameEventAnswerSet:
+		game.Answer = e.Data.Answer
+	case GameEventTurn:
+		game.TurnPlayer = e.Username
+		game.TurnDeadline = time.Time{}
+		if e.Data.Deadline != nil {
+			game.TurnDeadline = *e.Data.Deadline
+		}
+	case GameEventQuestionAsked:
+		game.Questions = append(game.Questions, Question{
+			QuestionID:   strconv.FormatInt(e.Data.QuestionID, 10),
+			QuestionText: e.Data.Text,
+			UserID:       e.Username,
+			GameID:       gameID,
+			AskedAt:      e.CreatedAt,
+		})
+		game.QuestionCount++
+		game.PendingQuestion = true
+	case GameEventQuestionAnswered:
+		pending := false
+		for i, q := range game.Questions {
+			if q.QuestionID == strconv.FormatInt(e.Data.QuestionID, 10) {
+				game.Questions[i].Answer = e.Data.Answer
+			}
+			if game.Questions[i].Answer == "" {
+				pending = true
+			}
+		}
+		game.PendingQuestion = pending
+	case GameEventGuessed:
+		game.Guesses = append(game.Guesses, Guess{
+			GuessID:   strconv.FormatInt(e.Data.GuessID, 10),
+			GuessText: e.Data.Text,
+			UserID:    e.Username,
+			GameID:    gameID,
+			Correct:   e.Data.Correct,
+			GuessedAt: e.CreatedAt,
+		})
+		if !e.Data.Correct {
+			game.WrongGuesses++
+		}
+	case GameEventEnded:
+		game.Ended = true
+		game.EndTime = e.CreatedAt
+		game.Outcome = e.Data.Outcome
+	}
+	return game
+}
+
+// without returns the users without the given user.
+func without(users []string, username string) []string {
+	kept := make([]string, 0, len(users))
+	for _, u := range users {
+		if u != username {
+			kept = append(kept, u)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testClient connects to the database used by Setup, skipping the test if
+// it is not running.
+func testClient(t *testing.T) *Client {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", "localhost:5431", time.Second)
+	if err != nil {
+		t.Skipf("postgres is not running: %v", err)
+	}
+	conn.Close()
+	return Setup()
+}
+
+func TestFoldGameMatchesGetGameData(t *testing.T) {
+	c := testClient(t)
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	host, player := "host"+suffix, "player"+suffix
+	for _, username := range []string{host, player} {
+		if err := c.UpsertUsername(username, "password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// play a full game, changing everything the history records
+	rules := DefaultRules()
+	rules.RoundRobin, rules.TurnSeconds = true, 60
+	gameID, err := c.CreateGame(host, GameSettings{Category: "animals", Rules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []func() error{
+		func() error { return c.AddUserToGame(player, gameID) },
+		func() error { return c.SetAnswer(gameID, "a cat") },
+		func() error { return c.SetTurn(gameID, player, rules.TurnSeconds) },
+		func() error {
+			questionID, err := c.AddQuestion(gameID, player, "is it a pet?", 0)
+			if err != nil {
+				return err
+			}
+			return c.AnswerQuestion(gameID, questionID, "yes")
+		},
+		func() error { _, err := c.AddQuestion(gameID, player, "does it bark?", 0); return err },
+		func() error { return c.AddGuess(gameID, player, "a dog", false) },
+		func() error { return c.AddGuess(gameID, player, "a cat", true) },
+		func() error { return c.EndGame(gameID, OutcomeSolved, player) },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	want, err := c.GetGameData(gameID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := c.GetGameEvents(gameID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := FoldGame(events)
+
+	if got.GameID != want.GameID || got.Host != want.Host || got.Answer != want.Answer ||
+		!reflect.DeepEqual(got.Players, want.Players) ||
+		got.QuestionCount != want.QuestionCount || got.WrongGuesses != want.WrongGuesses ||
+		got.PendingQuestion != want.PendingQuestion ||
+		got.Ended != want.Ended || got.Outcome != want.Outcome ||
+		got.Private != want.Private || got.InviteCode != want.InviteCode || got.Category != want.Category ||
+		got.Rules != want.Rules || got.TurnPlayer != want.TurnPlayer {
+		t.Errorf("folded game %+v does not match the stored game %+v", got, want)
+	}
+	for _, times := range [][2]time.Time{
+		{got.StartTime, want.StartTime},
+		{got.EndTime, want.EndTime},
+		{got.TurnDeadline, want.TurnDeadline},
+	} {
+		if !times[0].Equal(times[1]) {
+			t.Errorf("folded time %v does not match the stored time %v", times[0], times[1])
+		}
+	}
+}
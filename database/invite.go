@@ -0,0 +1,100 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Invite is a link the host creates so others can join a private game.
+type Invite struct {
+	Token     string     `db:"token"`
+	GameID    int64      `db:"game_id"`
+	CreatedBy string     `db:"created_by"`
+	ExpiresAt *time.Time `db:"expires_at"` // invites without an expiry never expire
+	MaxUses   int        `db:"max_uses"`   // 0 allows unlimited uses
+	Uses      int        `db:"uses"`
+	Revoked   bool       `db:"revoked"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// LogValue logs the invite without its token.
+func (i Invite) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("game_id", i.GameID),
+		slog.String("created_by", i.CreatedBy),
+		slog.Int("uses", i.Uses),
+		slog.Bool("revoked", i.Revoked),
+	)
+}
+
+// ErrInvalidInvite is returned when an invite does not exist or can no
+// longer be used because it expired, ran out of uses or was revoked.
+var ErrInvalidInvite = errors.New("invite is not valid")
+
+// CreateInvite stores a new invite for a game.
+func (c *Client) CreateInvite(invite Invite) error {
+	query := `INSERT INTO game_invites (token, game_id, created_by, expires_at, max_uses)
+					VALUES ($1, $2, $3, $4, $5)`
+	_, err := c.db.Exec(query, invite.Token, invite.GameID, invite.CreatedBy, invite.ExpiresAt, invite.MaxUses)
+	if err != nil {
+		return fmt.Errorf("unable to create invite for game %d: %w", invite.GameID, err)
+	}
+	return nil
+}
+
+// GetGameInvites returns every invite created for the game, newest first.
+func (c *Client) GetGameInvites(gameID int64) ([]Invite, error) {
+	var invites []Invite
+	err := c.db.Select(&invites, `SELECT token, game_id, created_by, expires_at, max_uses, uses, revoked, created_at
+					FROM game_invites WHERE game_id = $1 ORDER BY created_at DESC`, gameID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get invites for game %d: %w", gameID, err)
+	}
+	return invites, nil
+}
+
+// JoinWithInvite adds the user to the players of the private game using
+// the invite. ErrBanned and ErrGameFull are returned as they are by
+// AddUserToGame, and ErrInvalidInvite if the invite cannot be used to join
+// the game. The use of the invite is only counted once the user joins.
+func (c *Client) JoinWithInvite(username string, gameID int64, token string) error {
+	return c.joinGame(username, gameID, token)
+}
+
+// useInvite counts a use of the invite, returning ErrInvalidInvite if it
+// cannot be used to join the game.
+func useInvite(tx *sqlx.Tx, gameID int64, token string) error {
+	query := `UPDATE game_invites SET uses = uses + 1
+					WHERE token = $1 AND game_id = $2 AND NOT revoked
+					AND (expires_at IS NULL OR expires_at > NOW())
+					AND (max_uses = 0 OR uses < max_uses)`
+	results, err := tx.Exec(query, token, gameID)
+	if err != nil {
+		return fmt.Errorf("unable to use invite for game %d: %w", gameID, err)
+	}
+	n, err := results.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to use invite for game %d: %w", gameID, err)
+	}
+	if n == 0 {
+		return ErrInvalidInvite
+	}
+	return nil
+}
+
+// RevokeInvite stops the invite from being used again.
+func (c *Client) RevokeInvite(gameID int64, token string) error {
+	query := `UPDATE game_invites SET revoked = true WHERE token = $1 AND game_id = $2`
+	results, err := c.db.Exec(query, token, gameID)
+	if err != nil {
+		return fmt.Errorf("unable to revoke invite for game %d: %w", gameID, err)
+	}
+	if n, err := results.RowsAffected(); err == nil && n == 0 {
+		return ErrInvalidInvite
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LobbyGame is a public game that can still be joined.
+type LobbyGame struct {
+	GameID     int64     `db:"id"`
+	Host       string    `db:"host"`
+	Players    int       `db:"player_count"`
+	MaxPlayers int       `db:"max_players"`
+	Answered   bool      `db:"answered"` // whether the host has set the answer
+	Category   string    `db:"category"`
+	StartTime  time.Time `db:"start_time"`
+}
+
+// Phase returns what stage the game is at.
+func (g LobbyGame) Phase() string {
+	if g.Answered {
+		return PhaseInProgress
+	}
+	return PhaseStarting
+}
+
+// Lobby sort orders.
+const (
+	SortNewest        = "newest"
+	SortOldest        = "oldest"
+	SortMostPlayers   = "most_players"
+	SortFewestPlayers = "fewest_players"
+)
+
+// LobbyCursor is the position of the last game on the previous page.
+type LobbyCursor struct {
+	StartTime time.Time
+	Players   int
+	GameID    int64
+}
+
+// LobbyFilter narrows down and orders the games returned by ListLobbyGames.
+// Empty fields do not filter.
+type LobbyFilter struct {
+	Phase    string
+	Category string
+	Host     string
+	Sort     string // one of the Sort constants, defaults to SortNewest
+	After    *LobbyCursor
+	Limit    int
+}
+
+// lobbySorts maps each sort order to its ORDER BY clause and the keyset
+// comparison used to continue after a cursor.
+var lobbySorts = map[string]struct {
+	orderBy string
+	after   string
+}{
+	SortNewest:        {"start_time DESC, id DESC", "(start_time, id) < ($%d, $%d)"},
+	SortOldest:        {"start_time ASC, id ASC", "(start_time, id) > ($%d, $%d)"},
+	SortMostPlayers:   {"player_count DESC, id DESC", "(player_count, id) < ($%d, $%d)"},
+	SortFewestPlayers: {"player_count ASC, id ASC", "(player_count, id) > ($%d, $%d)"},
+}
+
+// ValidLobbySort reports whether sort is a known lobby sort order.
+func ValidLobbySort(sort string) bool {
+	_, ok := lobbySorts[sort]
+	return ok
+}
+
+// ListLobbyGames returns public games that have not ended and still have
+// room for more players.
+func (c *Client) ListLobbyGames(filter LobbyFilter) ([]LobbyGame, error) {
+	if filter.Sort == "" {
+		filter.Sort = SortNewest
+	}
+	sort, ok := lobbySorts[filter.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown lobby sort %q", filter.Sort)
+	}
+
+	args := []interface{}{DefaultMaxPlayers}
+	where := []string{"player_count < max_players"}
+	arg := func(v interface{}) int {
+		args = append(args, v)
+		return len(args)
+	}
+	switch filter.Phase {
+	case "":
+	case PhaseStarting:
+		where = append(where, "NOT answered")
+	case PhaseInProgress:
+		where = append(where, "answered")
+	default:
+		return nil, fmt.Errorf("unknown lobby phase %q", filter.Phase)
+	}
+	if filter.Category != "" {
+		where = append(where, fmt.Sprintf("category = $%d", arg(filter.Category)))
+	}
+	if filter.Host != "" {
+		where = append(where, fmt.Sprintf("host = $%d", arg(filter.Host)))
+	}
+	if filter.After != nil {
+		var key interface{} = filter.After.StartTime
+		if filter.Sort == SortMostPlayers || filter.Sort == SortFewestPlayers {
+			key = filter.After.Players
+		}
+		where = append(where, fmt.Sprintf(sort.after, arg(key), arg(filter.After.GameID)))
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := fmt.Sprintf(`SELECT id, host, player_count, max_players, answered, category, start_time
+					FROM (
+						SELECT id, host, COALESCE(cardinality(players), 0) AS player_count,
+							COALESCE((rules->>'MaxPlayers')::INTEGER, $1) AS max_players,
+							COALESCE(answer, '') <> '' AS answered, COALESCE(category, '') AS category, start_time
+						FROM games WHERE NOT ended AND NOT private
+					) lobby
+					WHERE %s
+					ORDER BY %s
+					LIMIT %d`, strings.Join(where, " AND "), sort.orderBy, limit)
+	var games []LobbyGame
+	if err := c.db.Select(&games, query, args...); err != nil {
+		return nil, fmt.Errorf("unable to list lobby games: %w", err)
+	}
+	return games, nil
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxLimitSeconds caps every time limit in the rules at a day.
+const maxLimitSeconds = 24 * 60 * 60
+
+// DefaultMaxQuestions is the number of questions in a classic game.
+const DefaultMaxQuestions = 20
+
+// Answer vocabularies the host can answer questions with.
+const (
+	VocabularyYesNo    = "yes_no"   // yes or no
+	VocabularyExtended = "extended" // yes, no, sometimes or irrelevant
+)
+
+var vocabularies = map[string][]string{
+	VocabularyYesNo:    {"yes", "no"},
+	VocabularyExtended: {"yes", "no", "sometimes", "irrelevant"},
+}
+
+// Rules are chosen by the host when a game is created. They are stored
+// with the game as JSON. Zero values are replaced by the defaults when
+// rules are read, so games created before a rule existed play classic
+// twenty questions.
+type Rules struct {
+	MaxQuestions     int    // questions the players can ask between them
+	MaxPlayers       int    // players allowed in the game, including the host
+	GuessesPerPlayer int    // guesses each player can make, 0 for no limit
+	WrongGuessCosts  bool   // a wrong guess uses up one of the questions
+	Vocabulary       string // answers the host can give, see the Vocabulary constants
+	TimeLimitSeconds int    // how long the game can last, 0 for no limit
+	RoundRobin       bool   // players take turns asking questions in the order they joined
+	TurnSeconds      int    // how long a player has to ask on their turn, 0 for no limit
+	AnswerSeconds    int    // how long the host has to answer a question, 0 for no limit
+}
+
+// DefaultRules returns the rules of classic twenty questions.
+func DefaultRules() Rules {
+	return Rules{
+		MaxQuestions: DefaultMaxQuestions,
+		MaxPlayers:   DefaultMaxPlayers,
+		Vocabulary:   VocabularyYesNo,
+	}
+}
+
+// WithDefaults fills in any unset rule with its default.
+func (r Rules) WithDefaults() Rules {
+	d := DefaultRules()
+	if r.MaxQuestions == 0 {
+		r.MaxQuestions = d.MaxQuestions
+	}
+	if r.MaxPlayers == 0 {
+		r.MaxPlayers = d.MaxPlayers
+	}
+	if r.Vocabulary == "" {
+		r.Vocabulary = d.Vocabulary
+	}
+	return r
+}
+
+// Validate checks that the rules can be played.
+func (r Rules) Validate() error {
+	if r.MaxQuestions < 1 || r.MaxQuestions > 100 {
+		return errors.New("max questions must be between 1 and 100")
+	}
+	if r.MaxPlayers < 2 || r.MaxPlayers > 20 {
+		return errors.New("max players must be between 2 and 20")
+	}
+	if r.GuessesPerPlayer < 0 {
+		return errors.New("guesses per player cannot be negative")
+	}
+	if _, ok := vocabularies[r.Vocabulary]; !ok {
+		return fmt.Errorf("vocabulary must be %s or %s", VocabularyYesNo, VocabularyExtended)
+	}
+	for name, seconds := range map[string]int{"game": r.TimeLimitSeconds, "turn": r.TurnSeconds, "answer": r.AnswerSeconds} {
+		if seconds < 0 || seconds > maxLimitSeconds {
+			return fmt.Errorf("%s time limit must be between 0 and %d seconds", name, maxLimitSeconds)
+		}
+	}
+	if r.TurnSeconds > 0 && !r.RoundRobin {
+		return errors.New("turn time limits need round robin turns")
+	}
+	return nil
+}
+
+// AllowedAnswers returns the answers the host can give to a question.
+func (r Rules) AllowedAnswers() []string {
+	return vocabularies[r.WithDefaults().Vocabulary]
+}
+
+// NormalizeAnswer returns the answer in the form it is stored in, and
+// whether the vocabulary allows it.
+func (r Rules) NormalizeAnswer(answer string) (string, bool) {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	for _, allowed := range r.AllowedAnswers() {
+		if answer == allowed {
+			return answer, true
+		}
+	}
+	return answer, false
+}
+
+// Value stores the rules as JSON.
+func (r Rules) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan reads rules stored as JSON.
+func (r *Rules) Scan(src interface{}) error {
+	var rules Rules
+	switch v := src.(type) {
+	case nil:
+	case []byte:
+		if err := json.Unmarshal(v, &rules); err != nil {
+			return err
+		}
+	case string:
+		if err := json.Unmarshal([]byte(v), &rules); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot scan %T into rules", src)
+	}
+	*r = rules.WithDefaults()
+	return nil
+}
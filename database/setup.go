@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"net/url"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -12,14 +13,65 @@ import (
 // mock the database client in our tests and swap it out
 // with the real one in our main function.
 type Connection interface {
-	GetUserData(string) (string, error)
+	GetUserData(string) (User, error)
+	UpdateProfile(string, ProfileUpdate) (User, error)
+	TouchLastSeen(string) error
 	UpsertUsername(string, string) error
 	DeleteUsername(string) error
-	CreateGame(string) (int64, error)
+	RestoreUser(string, string, time.Duration) error
+	RenameUser(string, string) error
+	GetUsernameHistory(string) ([]UsernameChange, error)
+	AnonymizeDeletedUsers(time.Duration) (int, error)
+	GetUserGames(string) ([]Game, error)
+	GetUserQuestions(string) ([]Question, error)
+	GetUserGuesses(string) ([]Guess, error)
+	CreateGame(string, GameSettings) (int64, error)
 	AddUserToGame(string, int64) error
 	GetGameData(int64) (Game, error)
 	StopGame(int64) error
+	EndGame(int64, string, string) error
+	RemoveUserFromGame(string, int64) error
+	TransferHost(int64, string) error
+	RemoveFromGame(int64, string, string, string, bool) error
+	GetGameRemovals(int64) ([]Removal, error)
+	SaveStandings(Game, []Standing) (bool, error)
+	GetUserStats(string) (UserStats, error)
+	GetLeaderboard(LeaderboardFilter) ([]LeaderboardEntry, error)
+	GetRatings([]string) ([]UserRating, error)
+	SaveRatings(int64, []UserRating) error
+	GetRatingHistory(string) ([]RatingChange, error)
+	UnlockAchievements(string, int64, []string) ([]string, error)
+	GetUserAchievements(string) ([]Achievement, error)
+	GetGameStandings(int64) ([]Standing, error)
+	ListLobbyGames(LobbyFilter) ([]LobbyGame, error)
+	SetTurn(int64, string, int) error
+	SkipTurn(int64, string, string, int) error
+	ListExpiredTurns() ([]int64, error)
+	ExpireQuestions() ([]Question, error)
+	ListExpiredGames() ([]int64, error)
+	CountActiveGames() (int, error)
+	CreateInvite(Invite) error
+	GetGameInvites(int64) ([]Invite, error)
+	JoinWithInvite(string, int64, string) error
+	RevokeInvite(int64, string) error
+	GetGameQuestions(int64) ([]Question, error)
+	GetGameGuesses(int64) ([]Guess, error)
 	CheckUserValid(string, string) (bool, error)
+	IsAdmin(string) (bool, error)
+	SetAnswer(int64, string) error
+	AddQuestion(int64, string, string, int) (int64, error)
+	AnswerQuestion(int64, int64, string) error
+	AddGuess(int64, string, string, bool) error
+	ListUsers(UserFilter) ([]User, error)
+	GetUserIncludingDeleted(string) (User, error)
+	ListGames(GameFilter) ([]Game, error)
+	ResetPassword(string, string) error
+	BanUser(string, string) error
+	UnbanUser(string) error
+	RecordAudit(AuditEntry) error
+	ListAudit(AuditFilter) ([]AuditEntry, error)
+	PruneAudit(time.Duration) (int, error)
+	GetGameEvents(int64, int64) ([]GameEvent, error)
 }
 
 // Client is the real database client that satisfies the
@@ -89,6 +141,177 @@ func Setup() *Client {
 		correct BOOLEAN DEFAULT FALSE
 	);
 
+	CREATE TABLE IF NOT EXISTS game_removals (
+		id SERIAL PRIMARY KEY,
+		game_id INTEGER NOT NULL references games(id),
+		username VARCHAR(255) NOT NULL,
+		removed_by VARCHAR(255) NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		banned BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS game_standings (
+		game_id INTEGER NOT NULL references games(id),
+		username VARCHAR(255) NOT NULL,
+		rank INTEGER NOT NULL,
+		points INTEGER NOT NULL,
+		host BOOLEAN NOT NULL DEFAULT FALSE,
+		won BOOLEAN NOT NULL DEFAULT FALSE,
+		questions_asked INTEGER NOT NULL DEFAULT 0,
+		correct_guesses INTEGER NOT NULL DEFAULT 0,
+		wrong_guesses INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (game_id, username)
+	);
+
+	CREATE TABLE IF NOT EXISTS user_stats (
+		username VARCHAR(255) PRIMARY KEY,
+		games_played INTEGER NOT NULL DEFAULT 0,
+		games_hosted INTEGER NOT NULL DEFAULT 0,
+		games_won INTEGER NOT NULL DEFAULT 0,
+		games_solved INTEGER NOT NULL DEFAULT 0,
+		questions_to_solve INTEGER NOT NULL DEFAULT 0,
+		guesses INTEGER NOT NULL DEFAULT 0,
+		correct_guesses INTEGER NOT NULL DEFAULT 0,
+		current_streak INTEGER NOT NULL DEFAULT 0,
+		best_streak INTEGER NOT NULL DEFAULT 0,
+		points INTEGER NOT NULL DEFAULT 0,
+		last_played TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS leaderboard (
+		period VARCHAR(16) NOT NULL,
+		category VARCHAR(255) NOT NULL DEFAULT '',
+		username VARCHAR(255) NOT NULL,
+		points INTEGER NOT NULL DEFAULT 0,
+		wins INTEGER NOT NULL DEFAULT 0,
+		games INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (period, category, username)
+	);
+	CREATE INDEX IF NOT EXISTS leaderboard_points_idx ON leaderboard (period, category, points DESC);
+
+	CREATE TABLE IF NOT EXISTS rating_history (
+		id SERIAL PRIMARY KEY,
+		game_id INTEGER NOT NULL references games(id),
+		username VARCHAR(255) NOT NULL,
+		previous_rating DOUBLE PRECISION NOT NULL,
+		rating DOUBLE PRECISION NOT NULL,
+		rating_deviation DOUBLE PRECISION NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS rating_history_user_idx ON rating_history (username, created_at);
+
+	CREATE TABLE IF NOT EXISTS user_achievements (
+		username VARCHAR(255) NOT NULL,
+		achievement_id VARCHAR(64) NOT NULL,
+		game_id INTEGER NOT NULL references games(id),
+		unlocked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (username, achievement_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS username_history (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL references users(id),
+		old_username VARCHAR(255) NOT NULL,
+		new_username VARCHAR(255) NOT NULL,
+		changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS game_invites (
+		token VARCHAR(64) PRIMARY KEY,
+		game_id INTEGER NOT NULL references games(id),
+		created_by VARCHAR(255) NOT NULL,
+		expires_at TIMESTAMP,
+		max_uses INTEGER NOT NULL DEFAULT 0,
+		uses INTEGER NOT NULL DEFAULT 0,
+		revoked BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id SERIAL PRIMARY KEY,
+		actor VARCHAR(255) NOT NULL,
+		action VARCHAR(64) NOT NULL,
+		target VARCHAR(255) NOT NULL DEFAULT '',
+		detail TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	-- every change to a game in the order it happened, the games table and
+	-- the game's questions, guesses and removals are a projection of it so
+	-- games can be queried without folding them
+	CREATE TABLE IF NOT EXISTS game_events (
+		id BIGSERIAL PRIMARY KEY,
+		game_id INTEGER NOT NULL references games(id),
+		seq INTEGER NOT NULL,
+		type VARCHAR(32) NOT NULL,
+		username VARCHAR(255) NOT NULL DEFAULT '',
+		data JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (game_id, seq)
+	);
+
+	-- game history is append only, usernames in it follow renames but
+	-- nothing else about an event can change
+	CREATE OR REPLACE FUNCTION game_events_append_only() RETURNS TRIGGER AS $$
+	BEGIN
+		IF TG_OP = 'DELETE' OR NEW.game_id <> OLD.game_id OR NEW.seq <> OLD.seq
+			OR NEW.type <> OLD.type OR NEW.created_at <> OLD.created_at
+			OR NEW.data - 'By' <> OLD.data - 'By' THEN
+			RAISE EXCEPTION 'game_events entries cannot be changed';
+		END IF;
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS game_events_append_only ON game_events;
+	CREATE TRIGGER game_events_append_only BEFORE UPDATE OR DELETE ON game_events
+		FOR EACH ROW EXECUTE FUNCTION game_events_append_only();
+
+	-- the audit log is append only, entries are removed by PruneAudit once
+	-- they are older than the retention period but are never changed
+	CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_log entries cannot be changed';
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+	CREATE TRIGGER audit_log_append_only BEFORE UPDATE ON audit_log
+		FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+	CREATE INDEX IF NOT EXISTS audit_log_created_at ON audit_log (created_at);
+
+	ALTER TABLE questions ADD COLUMN IF NOT EXISTS answer VARCHAR(255);
+	ALTER TABLE questions ADD COLUMN IF NOT EXISTS asked_at TIMESTAMP NOT NULL DEFAULT NOW();
+	ALTER TABLE guesses ADD COLUMN IF NOT EXISTS guessed_at TIMESTAMP NOT NULL DEFAULT NOW();
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS admin BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS rating DOUBLE PRECISION NOT NULL DEFAULT 1500;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS rating_deviation DOUBLE PRECISION NOT NULL DEFAULT 350;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS rated_at TIMESTAMP;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(50) NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar VARCHAR(32) NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS bio VARCHAR(280) NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS language VARCHAR(35) NOT NULL DEFAULT 'en';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS profile_public BOOLEAN NOT NULL DEFAULT TRUE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS show_stats BOOLEAN NOT NULL DEFAULT TRUE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS show_last_seen BOOLEAN NOT NULL DEFAULT TRUE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen TIMESTAMP;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS banned_at TIMESTAMP;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS ban_reason VARCHAR(280) NOT NULL DEFAULT '';
+	ALTER TABLE games ADD COLUMN IF NOT EXISTS outcome VARCHAR(32);
+	ALTER TABLE games ADD COLUMN IF NOT EXISTS private BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE games ADD COLUMN IF NOT EXISTS invite_code VARCHAR(64);
+	ALTER TABLE games ADD COLUMN IF NOT EXISTS category VARCHAR(64);
+	ALTER TABLE games ADD COLUMN IF NOT EXISTS rules JSONB NOT NULL DEFAULT '{}';
+	ALTER TABLE games ADD COLUMN IF NOT EXISTS turn_player VARCHAR(255);
+	ALTER TABLE games ADD COLUMN IF NOT EXISTS turn_deadline TIMESTAMP;
+	ALTER TABLE games ADD COLUMN IF NOT EXISTS scored BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE games ADD COLUMN IF NOT EXISTS rated BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE questions ADD COLUMN IF NOT EXISTS deadline TIMESTAMP;
+	ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS request_id VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS ip VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS forwarded_for VARCHAR(64) NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS games_lobby_idx ON games (start_time, id) WHERE NOT ended AND NOT private;
 `
 	db.MustExec(tableQuery)
 
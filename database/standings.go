@@ -0,0 +1,66 @@
+package database
+
+import (
+	"fmt"
+)
+
+// Standing is a player's final score in a game.
+type Standing struct {
+	GameID         int64  `db:"game_id"`
+	Username       string `db:"username"`
+	Rank           int    `db:"rank"` // 1 is first, players with the same points share a rank
+	Points         int    `db:"points"`
+	Host           bool   `db:"host"`
+	Won            bool   `db:"won"`
+	QuestionsAsked int    `db:"questions_asked"`
+	CorrectGuesses int    `db:"correct_guesses"`
+	WrongGuesses   int    `db:"wrong_guesses"`
+}
+
+// SaveStandings stores the final standings of the ended game and adds
+// them to each player's stats and the leaderboards. Only the first call for
+// a game is recorded so scoring a game twice is harmless, it reports
+// whether this call scored the game.
+func (c *Client) SaveStandings(game Game, standings []Standing) (bool, error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("unable to save standings for game %d: %w", game.GameID, err)
+	}
+	defer tx.Rollback()
+
+	results, err := tx.Exec(`UPDATE games SET scored = true WHERE id = $1 AND ended AND NOT scored`, game.GameID)
+	if err != nil {
+		return false, fmt.Errorf("unable to mark game %d as scored: %w", game.GameID, err)
+	}
+	if n, err := results.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	for _, s := range standings {
+		_, err := tx.Exec(`INSERT INTO game_standings
+						(game_id, username, rank, points, host, won, questions_asked, correct_guesses, wrong_guesses)
+						VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			game.GameID, s.Username, s.Rank, s.Points, s.Host, s.Won, s.QuestionsAsked, s.CorrectGuesses, s.WrongGuesses)
+		if err != nil {
+			return false, fmt.Errorf("unable to save standing of %s in game %d: %w", s.Username, game.GameID, err)
+		}
+		if err := recordStats(tx, game, s); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("unable to save standings for game %d: %w", game.GameID, err)
+	}
+	return true, nil
+}
+
+// GetGameStandings returns the final standings of the game, best first.
+// Games that have not been scored have no standings.
+func (c *Client) GetGameStandings(gameID int64) ([]Standing, error) {
+	var standings []Standing
+	err := c.db.Select(&standings, `SELECT game_id, username, rank, points, host, won, questions_asked, correct_guesses, wrong_guesses
+					FROM game_standings WHERE game_id = $1 ORDER BY rank, username`, gameID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get standings for game %d: %w", gameID, err)
+	}
+	return standings, nil
+}
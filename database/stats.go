@@ -0,0 +1,190 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// UserStats are a user's totals across every game they have finished.
+// They are kept up to date by SaveStandings when a game ends.
+type UserStats struct {
+	Username         string     `db:"username"`
+	GamesPlayed      int        `db:"games_played"` // including games they hosted
+	GamesHosted      int        `db:"games_hosted"`
+	GamesWon         int        `db:"games_won"`
+	GamesSolved      int        `db:"games_solved"`       // games won by guessing the answer
+	QuestionsToSolve int        `db:"questions_to_solve"` // questions used across the games they solved
+	Guesses          int        `db:"guesses"`
+	CorrectGuesses   int        `db:"correct_guesses"`
+	CurrentStreak    int        `db:"current_streak"` // games won in a row
+	BestStreak       int        `db:"best_streak"`
+	Points           int        `db:"points"`
+	LastPlayed       *time.Time `db:"last_played"`
+}
+
+// AverageQuestionsToSolve returns how many questions the user needs on
+// average to guess the answer.
+func (s UserStats) AverageQuestionsToSolve() float64 {
+	if s.GamesSolved == 0 {
+		return 0
+	}
+	return float64(s.QuestionsToSolve) / float64(s.GamesSolved)
+}
+
+// GuessAccuracy returns the fraction of the user's guesses that were correct.
+func (s UserStats) GuessAccuracy() float64 {
+	if s.Guesses == 0 {
+		return 0
+	}
+	return float64(s.CorrectGuesses) / float64(s.Guesses)
+}
+
+// GetUserStats returns the stats of the user. Users that have not finished
+// a game yet have empty stats.
+func (c *Client) GetUserStats(username string) (UserStats, error) {
+	var stats UserStats
+	err := c.db.Get(&stats, `SELECT u.username,
+					COALESCE(s.games_played, 0) AS games_played, COALESCE(s.games_hosted, 0) AS games_hosted,
+					COALESCE(s.games_won, 0) AS games_won, COALESCE(s.games_solved, 0) AS games_solved,
+					COALESCE(s.questions_to_solve, 0) AS questions_to_solve,
+					COALESCE(s.guesses, 0) AS guesses, COALESCE(s.correct_guesses, 0) AS correct_guesses,
+					COALESCE(s.current_streak, 0) AS current_streak, COALESCE(s.best_streak, 0) AS best_streak,
+					COALESCE(s.points, 0) AS points, s.last_played
+				FROM users u LEFT JOIN user_stats s ON s.username = u.username
+				WHERE u.username = $1 AND u.deleted_at IS NULL`, username)
+	if err == sql.ErrNoRows {
+		return stats, ErrUserNotFound
+	}
+	if err != nil {
+		return stats, fmt.Errorf("unable to get stats for %s: %w", username, err)
+	}
+	return stats, nil
+}
+
+// Leaderboard periods. Weekly leaderboards are named after the ISO week,
+// see WeekPeriod.
+const (
+	PeriodAllTime = "all"
+	PeriodWeekly  = "weekly" // the current week
+)
+
+// WeekPeriod returns the weekly leaderboard period that t falls in,
+// e.g. 2026-W42.
+func WeekPeriod(t time.Time) string {
+	year, week := t.UTC().ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// LeaderboardFilter picks the leaderboard returned by GetLeaderboard.
+type LeaderboardFilter struct {
+	Period   string // PeriodAllTime or a week from WeekPeriod
+	Category string // empty for every category
+	ByRating bool   // rank by skill rating instead of points, only for all time
+	Limit    int
+}
+
+// LeaderboardEntry is a user's place on a leaderboard.
+type LeaderboardEntry struct {
+	Rank      int     `db:"rank"`
+	Username  string  `db:"username"`
+	Points    int     `db:"points"`
+	Wins      int     `db:"wins"`
+	Games     int     `db:"games"`
+	Rating    float64 `db:"rating"`
+	Deviation float64 `db:"rating_deviation"`
+}
+
+// GetLeaderboard returns the users with the most points on the leaderboard,
+// best first. Users with the same points share a rank. Ranking by rating
+// uses the rating the user is very likely to be above, so players with few
+// games do not top the leaderboard on a lucky win.
+func (c *Client) GetLeaderboard(filter LeaderboardFilter) ([]LeaderboardEntry, error) {
+	if filter.Period == "" {
+		filter.Period = PeriodAllTime
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	query := `SELECT RANK() OVER (ORDER BY l.points DESC) AS rank, l.username, l.points, l.wins, l.games,
+					COALESCE(u.rating, 1500) AS rating, COALESCE(u.rating_deviation, 350) AS rating_deviation
+				FROM leaderboard l LEFT JOIN users u ON u.username = l.username
+				WHERE l.period = $1 AND l.category = $2 AND u.deleted_at IS NULL
+				ORDER BY l.points DESC, l.wins DESC, l.username
+				LIMIT $3`
+	if filter.ByRating {
+		query = `SELECT RANK() OVER (ORDER BY u.rating - 2 * u.rating_deviation DESC) AS rank, u.username,
+					COALESCE(l.points, 0) AS points, COALESCE(l.wins, 0) AS wins, COALESCE(l.games, 0) AS games,
+					u.rating, u.rating_deviation
+				FROM users u LEFT JOIN leaderboard l ON l.username = u.username AND l.period = $1 AND l.category = $2
+				WHERE u.rated_at IS NOT NULL AND u.deleted_at IS NULL
+				ORDER BY u.rating - 2 * u.rating_deviation DESC, u.username
+				LIMIT $3`
+	}
+	var entries []LeaderboardEntry
+	err := c.db.Select(&entries, query, filter.Period, filter.Category, limit)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %s leaderboard: %w", filter.Period, err)
+	}
+	return entries, nil
+}
+
+// recordStats adds a player's result in the game to their stats and to the
+// all time and weekly leaderboards, overall and for the game's category.
+func recordStats(tx *sqlx.Tx, game Game, s Standing) error {
+	var hosted, won, solved, questions int
+	if s.Host {
+		hosted = 1
+	}
+	if s.Won {
+		won = 1
+		if !s.Host {
+			solved = 1
+			questions = int(game.QuestionsUsed())
+		}
+	}
+	_, err := tx.Exec(`INSERT INTO user_stats AS u (username, games_played, games_hosted, games_won, games_solved,
+						questions_to_solve, guesses, correct_guesses, current_streak, best_streak, points, last_played)
+					VALUES ($1, 1, $2, $3, $4, $5, $6, $7, $3, $3, $8, NOW())
+					ON CONFLICT (username) DO UPDATE SET
+						games_played = u.games_played + 1,
+						games_hosted = u.games_hosted + $2,
+						games_won = u.games_won + $3,
+						games_solved = u.games_solved + $4,
+						questions_to_solve = u.questions_to_solve + $5,
+						guesses = u.guesses + $6,
+						correct_guesses = u.correct_guesses + $7,
+						current_streak = CASE WHEN $3 = 1 THEN u.current_streak + 1 ELSE 0 END,
+						best_streak = GREATEST(u.best_streak, CASE WHEN $3 = 1 THEN u.current_streak + 1 ELSE 0 END),
+						points = u.points + $8,
+						last_played = NOW()`,
+		s.Username, hosted, won, solved, questions, s.CorrectGuesses+s.WrongGuesses, s.CorrectGuesses, s.Points)
+	if err != nil {
+		return fmt.Errorf("unable to update stats of %s: %w", s.Username, err)
+	}
+
+	end := game.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	categories := []string{""}
+	if game.Category != "" {
+		categories = append(categories, game.Category)
+	}
+	for _, period := range []string{PeriodAllTime, WeekPeriod(end)} {
+		for _, category := range categories {
+			_, err := tx.Exec(`INSERT INTO leaderboard AS l (period, category, username, points, wins, games)
+							VALUES ($1, $2, $3, $4, $5, 1)
+							ON CONFLICT (period, category, username) DO UPDATE SET
+								points = l.points + $4, wins = l.wins + $5, games = l.games + 1`,
+				period, category, s.Username, s.Points, won)
+			if err != nil {
+				return fmt.Errorf("unable to update %s leaderboard for %s: %w", period, s.Username, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is looked up on every use so tests can swap the tracer provider.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/soypete/golang-cli-game/database")
+}
+
+// tracedConnection records a span for every call to the connection, as a
+// child of the span in its context. Spans are named after the method
+// rather than the SQL so the values passed in, like passwords and answers,
+// are never recorded.
+type tracedConnection struct {
+	ctx  context.Context
+	next Connection
+}
+
+// WithTracing returns a connection that traces its calls as part of the
+// ctx's trace. Connections that are already traced are moved to ctx.
+func WithTracing(ctx context.Context, conn Connection) Connection {
+	if t, ok := conn.(tracedConnection); ok {
+		conn = t.next
+	}
+	return tracedConnection{ctx: ctx, next: conn}
+}
+
+// trace starts the span of a call. The returned function ends it,
+// recording the call's error.
+func (t tracedConnection) trace(operation string) func(*error) {
+	_, span := tracer().Start(t.ctx, "database."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", operation),
+		))
+	return func(err *error) {
+		if *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
+
+func (t tracedConnection) GetUserData(username string) (_ User, err error) {
+	defer t.trace("GetUserData")(&err)
+	return t.next.GetUserData(username)
+}
+
+func (t tracedConnection) UpdateProfile(username string, update ProfileUpdate) (_ User, err error) {
+	defer t.trace("UpdateProfile")(&err)
+	return t.next.UpdateProfile(username, update)
+}
+
+func (t tracedConnection) TouchLastSeen(username string) (err error) {
+	defer t.trace("TouchLastSeen")(&err)
+	return t.next.TouchLastSeen(username)
+}
+
+func (t tracedConnection) UpsertUsername(username, password string) (err error) {
+	defer t.trace("UpsertUsername")(&err)
+	return t.next.UpsertUsername(username, password)
+}
+
+func (t tracedConnection) DeleteUsername(username string) (err error) {
+	defer t.trace("DeleteUsername")(&err)
+	return t.next.DeleteUsername(username)
+}
+
+func (t tracedConnection) RestoreUser(username, password string, window time.Duration) (err error) {
+	defer t.trace("RestoreUser")(&err)
+	return t.next.RestoreUser(username, password, window)
+}
+
+func (t tracedConnection) RenameUser(oldUsername, newUsername string) (err error) {
+	defer t.trace("RenameUser")(&err)
+	return t.next.RenameUser(oldUsername, newUsername)
+}
+
+func (t tracedConnection) GetUsernameHistory(username string) (_ []UsernameChange, err error) {
+	defer t.trace("GetUsernameHistory")(&err)
+	return t.next.GetUsernameHistory(username)
+}
+
+func (t tracedConnection) AnonymizeDeletedUsers(window time.Duration) (_ int, err error) {
+	defer t.trace("AnonymizeDeletedUsers")(&err)
+	return t.next.AnonymizeDeletedUsers(window)
+}
+
+func (t tracedConnection) GetUserGames(username string) (_ []Game, err error) {
+	defer t.trace("GetUserGames")(&err)
+	return t.next.GetUserGames(username)
+}
+
+func (t tracedConnection) GetUserQuestions(username string) (_ []Question, err error) {
+	defer t.trace("GetUserQuestions")(&err)
+	return t.next.GetUserQuestions(username)
+}
+
+func (t tracedConnection) GetUserGuesses(username string) (_ []Guess, err error) {
+	defer t.trace("GetUserGuesses")(&err)
+	return t.next.GetUserGuesses(username)
+}
+
+func (t tracedConnection) CreateGame(username string, settings GameSettings) (_ int64, err error) {
+	defer t.trace("CreateGame")(&err)
+	return t.next.CreateGame(username, settings)
+}
+
+func (t tracedConnection) AddUserToGame(username string, gameID int64) (err error) {
+	defer t.trace("AddUserToGame")(&err)
+	return t.next.AddUserToGame(username, gameID)
+}
+
+func (t tracedConnection) GetGameData(gameID int64) (_ Game, err error) {
+	defer t.trace("GetGameData")(&err)
+	return t.next.GetGameData(gameID)
+}
+
+func (t tracedConnection) StopGame(gameID int64) (err error) {
+	defer t.trace("StopGame")(&err)
+	return t.next.StopGame(gameID)
+}
+
+func (t tracedConnection) EndGame(gameID int64, outcome, winner string) (err error) {
+	defer t.trace("EndGame")(&err)
+	return t.next.EndGame(gameID, outcome, winner)
+}
+
+func (t tracedConnection) RemoveUserFromGame(username string, gameID int64) (err error) {
+	defer t.trace("RemoveUserFromGame")(&err)
+	return t.next.RemoveUserFromGame(username, gameID)
+}
+
+func (t tracedConnection) TransferHost(gameID int64, newHost string) (err error) {
+	defer t.trace("TransferHost")(&err)
+	return t.next.TransferHost(gameID, newHost)
+}
+
+func (t tracedConnection) RemoveFromGame(gameID int64, username, removedBy, reason string, ban bool) (err error) {
+	defer t.trace("RemoveFromGame")(&err)
+	return t.next.RemoveFromGame(gameID, username, removedBy, reason, ban)
+}
+
+func (t tracedConnection) GetGameRemovals(gameID int64) (_ []Removal, err error) {
+	defer t.trace("GetGameRemovals")(&err)
+	return t.next.GetGameRemovals(gameID)
+}
+
+func (t tracedConnection) SaveStandings(game Game, standings []Standing) (_ bool, err error) {
+	defer t.trace("SaveStandings")(&err)
+	return t.next.SaveStandings(game, standings)
+}
+
+func (t tracedConnection) GetUserStats(username string) (_ UserStats, err error) {
+	defer t.trace("GetUserStats")(&err)
+	return t.next.GetUserStats(username)
+}
+
+func (t tracedConnection) GetLeaderboard(filter LeaderboardFilter) (_ []LeaderboardEntry, err error) {
+	defer t.trace("GetLeaderboard")(&err)
+	return t.next.GetLeaderboard(filter)
+}
+
+func (t tracedConnection) GetRatings(usernames []string) (_ []UserRating, err error) {
+	defer t.trace("GetRatings")(&err)
+	return t.next.GetRatings(usernames)
+}
+
+func (t tracedConnection) SaveRatings(gameID int64, ratings []UserRating) (err error) {
+	defer t.trace("SaveRatings")(&err)
+	return t.next.SaveRatings(gameID, ratings)
+}
+
+func (t tracedConnection) GetRatingHistory(username string) (_ []RatingChange, err error) {
+	defer t.trace("GetRatingHistory")(&err)
+	return t.next.GetRatingHistory(username)
+}
+
+func (t tracedConnection) UnlockAchievements(username string, gameID int64, ids []string) (_ []string, err error) {
+	defer t.trace("UnlockAchievements")(&err)
+	return t.next.UnlockAchievements(username, gameID, ids)
+}
+
+func (t tracedConnection) GetUserAchievements(username string) (_ []Achievement, err error) {
+	defer t.trace("GetUserAchievements")(&err)
+	return t.next.GetUserAchievements(username)
+}
+
+func (t tracedConnection) GetGameStandings(gameID int64) (_ []Standing, err error) {
+	defer t.trace("GetGameStandings")(&err)
+	return t.next.GetGameStandings(gameID)
+}
+
+func (t tracedConnection) ListLobbyGames(filter LobbyFilter) (_ []LobbyGame, err error) {
+	defer t.trace("ListLobbyGames")(&err)
+	return t.next.ListLobbyGames(filter)
+}
+
+func (t tracedConnection) SetTurn(gameID int64, username string, seconds int) (err error) {
+	defer t.trace("SetTurn")(&err)
+	return t.next.SetTurn(gameID, username, seconds)
+}
+
+func (t tracedConnection) SkipTurn(gameID int64, username, next string, seconds int) (err error) {
+	defer t.trace("SkipTurn")(&err)
+	return t.next.SkipTurn(gameID, username, next, seconds)
+}
+
+func (t tracedConnection) ListExpiredTurns() (_ []int64, err error) {
+	defer t.trace("ListExpiredTurns")(&err)
+	return t.next.ListExpiredTurns()
+}
+
+func (t tracedConnection) ExpireQuestions() (_ []Question, err error) {
+	defer t.trace("ExpireQuestions")(&err)
+	return t.next.ExpireQuestions()
+}
+
+func (t tracedConnection) ListExpiredGames() (_ []int64, err error) {
+	defer t.trace("ListExpiredGames")(&err)
+	return t.next.ListExpiredGames()
+}
+
+func (t tracedConnection) CountActiveGames() (_ int, err error) {
+	defer t.trace("CountActiveGames")(&err)
+	return t.next.CountActiveGames()
+}
+
+func (t tracedConnection) CreateInvite(invite Invite) (err error) {
+	defer t.trace("CreateInvite")(&err)
+	return t.next.CreateInvite(invite)
+}
+
+func (t tracedConnection) GetGameInvites(gameID int64) (_ []Invite, err error) {
+	defer t.trace("GetGameInvites")(&err)
+	return t.next.GetGameInvites(gameID)
+}
+
+func (t tracedConnection) JoinWithInvite(username string, gameID int64, token string) (err error) {
+	defer t.trace("JoinWithInvite")(&err)
+	return t.next.JoinWithInvite(username, gameID, token)
+}
+
+func (t tracedConnection) RevokeInvite(gameID int64, token string) (err error) {
+	defer t.trace("RevokeInvite")(&err)
+	return t.next.RevokeInvite(gameID, token)
+}
+
+func (t tracedConnection) GetGameQuestions(gameID int64) (_ []Question, err error) {
+	defer t.trace("GetGameQuestions")(&err)
+	return t.next.GetGameQuestions(gameID)
+}
+
+func (t tracedConnection) GetGameGuesses(gameID int64) (_ []Guess, err error) {
+	defer t.trace("GetGameGuesses")(&err)
+	return t.next.GetGameGuesses(gameID)
+}
+
+func (t tracedConnection) CheckUserValid(username, password string) (_ bool, err error) {
+	defer t.trace("CheckUserValid")(&err)
+	return t.next.CheckUserValid(username, password)
+}
+
+func (t tracedConnection) IsAdmin(username string) (_ bool, err error) {
+	defer t.trace("IsAdmin")(&err)
+	return t.next.IsAdmin(username)
+}
+
+func (t tracedConnection) SetAnswer(gameID int64, answer string) (err error) {
+	defer t.trace("SetAnswer")(&err)
+	return t.next.SetAnswer(gameID, answer)
+}
+
+func (t tracedConnection) AddQuestion(gameID int64, username, question string, answerSeconds int) (_ int64, err error) {
+	defer t.trace("AddQuestion")(&err)
+	return t.next.AddQuestion(gameID, username, question, answerSeconds)
+}
+
+func (t tracedConnection) AnswerQuestion(gameID, questionID int64, answer string) (err error) {
+	defer t.trace("AnswerQuestion")(&err)
+	return t.next.AnswerQuestion(gameID, questionID, answer)
+}
+
+func (t tracedConnection) AddGuess(gameID int64, username, guess string, correct bool) (err error) {
+	defer t.trace("AddGuess")(&err)
+	return t.next.AddGuess(gameID, username, guess, correct)
+}
+
+func (t tracedConnection) ListUsers(filter UserFilter) (_ []User, err error) {
+	defer t.trace("ListUsers")(&err)
+	return t.next.ListUsers(filter)
+}
+
+func (t tracedConnection) GetUserIncludingDeleted(username string) (_ User, err error) {
+	defer t.trace("GetUserIncludingDeleted")(&err)
+	return t.next.GetUserIncludingDeleted(username)
+}
+
+func (t tracedConnection) ListGames(filter GameFilter) (_ []Game, err error) {
+	defer t.trace("ListGames")(&err)
+	return t.next.ListGames(filter)
+}
+
+func (t tracedConnection) ResetPassword(username, password string) (err error) {
+	defer t.trace("ResetPassword")(&err)
+	return t.next.ResetPassword(username, password)
+}
+
+func (t tracedConnection) BanUser(username, reason string) (err error) {
+	defer t.trace("BanUser")(&err)
+	return t.next.BanUser(username, reason)
+}
+
+func (t tracedConnection) UnbanUser(username string) (err error) {
+	defer t.trace("UnbanUser")(&err)
+	return t.next.UnbanUser(username)
+}
+
+func (t tracedConnection) RecordAudit(entry AuditEntry) (err error) {
+	defer t.trace("RecordAudit")(&err)
+	return t.next.RecordAudit(entry)
+}
+
+func (t tracedConnection) ListAudit(filter AuditFilter) (_ []AuditEntry, err error) {
+	defer t.trace("ListAudit")(&err)
+	return t.next.ListAudit(filter)
+}
+
+func (t tracedConnection) GetGameEvents(gameID, afterSeq int64) (_ []GameEvent, err error) {
+	defer t.trace("GetGameEvents")(&err)
+	return t.next.GetGameEvents(gameID, afterSeq)
+}
+
+func (t tracedConnection) PruneAudit(retention time.Duration) (_ int, err error) {
+	defer t.trace("PruneAudit")(&err)
+	return t.next.PruneAudit(retention)
+}
@@ -1,46 +1,165 @@
 package database
 
-import "fmt"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
-// GetUserData returns the username from the database.
-// TODO: This is a placeholder function for now it will return
-// all the user data from the database.
-func (c *Client) GetUserData(username string) (string, error) {
-	var userData string
-	err := c.db.QueryRow("SELECT username FROM users WHERE username = $1;", username).Scan(&userData)
+	"github.com/lib/pq"
+)
+
+// ErrUserNotFound is returned when a user does not exist.
+var ErrUserNotFound = errors.New("user not found")
+
+// User is a user's profile.
+type User struct {
+	Username    string `db:"username"`
+	DisplayName string `db:"display_name"`
+	Avatar      string `db:"avatar"` // an emoji shown next to their name
+	Bio         string `db:"bio"`
+	Language    string `db:"language"` // preferred language, e.g. en or pt-BR
+	Privacy
+	Admin     bool       `db:"admin"`
+	LastSeen  *time.Time `db:"last_seen"` // nil until they make an authenticated request
+	CreatedAt time.Time  `db:"created_at"`
+	BannedAt  *time.Time `db:"banned_at"` // nil unless an admin banned them
+	BanReason string     `db:"ban_reason"`
+}
+
+// Privacy controls what other users can see of a profile.
+type Privacy struct {
+	Public       bool `db:"profile_public"` // private profiles only show the username
+	ShowStats    bool `db:"show_stats"`
+	ShowLastSeen bool `db:"show_last_seen"`
+}
+
+// ProfileUpdate is a change to a user's profile. Fields left nil are not
+// changed.
+type ProfileUpdate struct {
+	DisplayName  *string
+	Avatar       *string
+	Bio          *string
+	Language     *string
+	Public       *bool
+	ShowStats    *bool
+	ShowLastSeen *bool
+}
+
+const userColumns = `username, display_name, avatar, bio, language, profile_public, show_stats, show_last_seen,
+					admin, last_seen, created_at, banned_at, ban_reason`
+
+// GetUserData returns the user's profile.
+func (c *Client) GetUserData(username string) (User, error) {
+	var user User
+	err := c.db.Get(&user, "SELECT "+userColumns+" FROM users WHERE username = $1 AND deleted_at IS NULL;", username)
+	if err == sql.ErrNoRows {
+		return user, ErrUserNotFound
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to get username %s from db: %w", username, err)
+		return user, fmt.Errorf("failed to get user %s from db: %w", username, err)
 	}
-	return userData, nil
+	return user, nil
 }
 
-// UpsertUsername inserts a new username into the database.
+// UpdateProfile changes the user's profile and returns the updated profile.
+func (c *Client) UpdateProfile(username string, update ProfileUpdate) (User, error) {
+	var set []string
+	args := []interface{}{username}
+	field := func(column string, v interface{}) {
+		args = append(args, v)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if update.DisplayName != nil {
+		field("display_name", *update.DisplayName)
+	}
+	if update.Avatar != nil {
+		field("avatar", *update.Avatar)
+	}
+	if update.Bio != nil {
+		field("bio", *update.Bio)
+	}
+	if update.Language != nil {
+		field("language", *update.Language)
+	}
+	if update.Public != nil {
+		field("profile_public", *update.Public)
+	}
+	if update.ShowStats != nil {
+		field("show_stats", *update.ShowStats)
+	}
+	if update.ShowLastSeen != nil {
+		field("show_last_seen", *update.ShowLastSeen)
+	}
+	if len(set) == 0 {
+		return c.GetUserData(username)
+	}
+	var user User
+	err := c.db.Get(&user, "UPDATE users SET "+strings.Join(set, ", ")+
+		" WHERE username = $1 AND deleted_at IS NULL RETURNING "+userColumns, args...)
+	if err == sql.ErrNoRows {
+		return user, ErrUserNotFound
+	}
+	if err != nil {
+		return user, fmt.Errorf("failed to update profile of %s: %w", username, err)
+	}
+	return user, nil
+}
+
+// TouchLastSeen records that the user was just seen. It is called on every
+// authenticated request so only updates once a minute.
+func (c *Client) TouchLastSeen(username string) error {
+	_, err := c.db.Exec(`UPDATE users SET last_seen = NOW()
+					WHERE username = $1 AND (last_seen IS NULL OR last_seen < NOW() - INTERVAL '1 minute')`, username)
+	if err != nil {
+		return fmt.Errorf("failed to update last seen of %s: %w", username, err)
+	}
+	return nil
+}
+
+// UpsertUsername inserts a new user into the database. ErrUsernameTaken is
+// returned if the username is already in use, including by deleted users
+// that can still be restored.
 func (c *Client) UpsertUsername(username, password string) error {
-	registerUser := `INSERT INTO users (username) VALUES ($1)
+	registerUser := `INSERT INTO users (username, password) VALUES ($1, $2)
 										ON CONFLICT (username) DO NOTHING;`
-	_, err := c.db.Exec(registerUser, username)
+	results, err := c.db.Exec(registerUser, username, password)
 	if err != nil {
 		return fmt.Errorf("failed to register user %s: %w", username, err)
 	}
+	if n, err := results.RowsAffected(); err == nil && n == 0 {
+		return ErrUsernameTaken
+	}
 	return nil
 }
 
-// DeleteUsername deletes a username from the database.
+// DeleteUsername deletes the user. The account is kept for an undo window
+// so it can be restored with RestoreUser, after which
+// AnonymizeDeletedUsers removes everything that identifies them.
 func (c *Client) DeleteUsername(username string) error {
-	deleteUser := `DELETE FROM users WHERE username = $1;`
-	_, err := c.db.Exec(deleteUser, username)
+	deleteUser := `UPDATE users SET deleted_at = NOW() WHERE username = $1 AND deleted_at IS NULL;`
+	results, err := c.db.Exec(deleteUser, username)
 	if err != nil {
 		return fmt.Errorf("failed to delete user %s: %w", username, err)
 	}
+	if n, err := results.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
 	return nil
 }
 
 // CheckUserValid checks if the user is valid my checking that it
-// exists in the database and that the password matches.
+// exists in the database and that the password matches. Users that do not
+// exist are not valid, errors are only returned when the check fails.
 func (c *Client) CheckUserValid(username, password string) (bool, error) {
-	query := `SELECT username, password FROM users WHERE username = $1 ;`
+	query := `SELECT username, password, banned_at IS NOT NULL FROM users WHERE username = $1 AND deleted_at IS NULL;`
 	var user, pass string
-	err := c.db.QueryRow(query, username).Scan(&user, &pass)
+	var banned bool
+	err := c.db.QueryRow(query, username).Scan(&user, &pass, &banned)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
 	if err != nil {
 		return false, fmt.Errorf("failed to get user %s: %w", username, err)
 	}
@@ -49,5 +168,97 @@ func (c *Client) CheckUserValid(username, password string) (bool, error) {
 	if password != pass {
 		return false, nil
 	}
+	// only tell users they are banned once they prove who they are
+	if banned {
+		return false, ErrUserBanned
+	}
 	return true, nil
 }
+
+// IsAdmin checks if the user has the global admin role.
+func (c *Client) IsAdmin(username string) (bool, error) {
+	var admin bool
+	err := c.db.QueryRow("SELECT admin FROM users WHERE username = $1;", username).Scan(&admin)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get role of user %s: %w", username, err)
+	}
+	return admin, nil
+}
+
+// UserRating is a user's skill rating. New users start with the default
+// rating and deviation from the rating package.
+type UserRating struct {
+	Username  string     `db:"username"`
+	Rating    float64    `db:"rating"`
+	Deviation float64    `db:"rating_deviation"`
+	RatedAt   *time.Time `db:"rated_at"` // nil until they finish a rated game
+}
+
+// RatingChange records how a game changed a user's rating.
+type RatingChange struct {
+	GameID         int64     `db:"game_id"`
+	Username       string    `db:"username"`
+	PreviousRating float64   `db:"previous_rating"`
+	Rating         float64   `db:"rating"`
+	Deviation      float64   `db:"rating_deviation"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+// GetRatings returns the ratings of the users. Users that do not exist are
+// left out.
+func (c *Client) GetRatings(usernames []string) ([]UserRating, error) {
+	var ratings []UserRating
+	err := c.db.Select(&ratings, `SELECT username, rating, rating_deviation, rated_at
+					FROM users WHERE username = ANY($1)`, pq.Array(usernames))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get ratings: %w", err)
+	}
+	return ratings, nil
+}
+
+// SaveRatings stores the users' ratings after the game and adds them to
+// their rating history. Only the first call for a game is recorded.
+func (c *Client) SaveRatings(gameID int64, ratings []UserRating) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to save ratings for game %d: %w", gameID, err)
+	}
+	defer tx.Rollback()
+
+	results, err := tx.Exec(`UPDATE games SET rated = true WHERE id = $1 AND NOT rated`, gameID)
+	if err != nil {
+		return fmt.Errorf("unable to mark game %d as rated: %w", gameID, err)
+	}
+	if n, err := results.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+	for _, r := range ratings {
+		_, err := tx.Exec(`INSERT INTO rating_history (game_id, username, previous_rating, rating, rating_deviation)
+						SELECT $1, username, rating, $3, $4 FROM users WHERE username = $2`,
+			gameID, r.Username, r.Rating, r.Deviation)
+		if err != nil {
+			return fmt.Errorf("unable to record rating history of %s: %w", r.Username, err)
+		}
+		_, err = tx.Exec(`UPDATE users SET rating = $2, rating_deviation = $3, rated_at = NOW() WHERE username = $1`,
+			r.Username, r.Rating, r.Deviation)
+		if err != nil {
+			return fmt.Errorf("unable to update rating of %s: %w", r.Username, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetRatingHistory returns how the user's rating changed game by game,
+// oldest first.
+func (c *Client) GetRatingHistory(username string) ([]RatingChange, error) {
+	var history []RatingChange
+	err := c.db.Select(&history, `SELECT game_id, username, previous_rating, rating, rating_deviation, created_at
+					FROM rating_history WHERE username = $1 ORDER BY created_at, id`, username)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get rating history of %s: %w", username, err)
+	}
+	return history, nil
+}
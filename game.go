@@ -9,16 +9,81 @@
 package main
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
+	"os"
+	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/soypete/golang-cli-game/logging"
 	"github.com/soypete/golang-cli-game/server"
+	"github.com/soypete/golang-cli-game/tracing"
 )
 
 func main() {
+	// LOG_FORMAT=json LOG_LEVEL=info LOG_LEVELS=matchmaking=debug
+	logConfig, err := logging.ConfigFromEnv()
+	if err != nil {
+		slog.Error("invalid log config", "err", err)
+		os.Exit(1)
+	}
+	logging.Setup(logConfig)
+
+	// spans are only exported when a collector is set, e.g.
+	// OTEL_EXPORTER_OTLP_ENDPOINT=http://localhost:4318
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Endpoint: os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+	})
+	if err != nil {
+		slog.Error("unable to set up tracing", "err", err)
+		os.Exit(1)
+	}
+	defer shutdown(context.Background())
 
 	gameState := server.NewState()
 
+	// the admin routes are bound to localhost unless ADMIN_ADDR is set,
+	// ADMIN_ALLOWLIST=10.0.0.0/8 lets more hosts read /debug and /metrics
+	// without credentials.
+	if addr := os.Getenv("ADMIN_ADDR"); addr != "" {
+		gameState.AdminAddr = addr
+	}
+	if list := os.Getenv("ADMIN_ALLOWLIST"); list != "" {
+		allowlist, err := server.ParseAllowlist(list)
+		if err != nil {
+			slog.Error("invalid admin allowlist", "err", err)
+			os.Exit(1)
+		}
+		gameState.AdminAllowlist = append(gameState.AdminAllowlist, allowlist...)
+	}
+	// TRUSTED_PROXIES=10.0.0.5 believes the client address the load
+	// balancer forwards, requests are otherwise keyed on who connected.
+	if list := os.Getenv("TRUSTED_PROXIES"); list != "" {
+		proxies, err := server.ParseAllowlist(list)
+		if err != nil {
+			slog.Error("invalid trusted proxies", "err", err)
+			os.Exit(1)
+		}
+		gameState.TrustedProxies = proxies
+	}
+	// AUDIT_RETENTION=2160h keeps the audit log for 90 days, 0 keeps it forever
+	if retention := os.Getenv("AUDIT_RETENTION"); retention != "" {
+		d, err := time.ParseDuration(retention)
+		if err != nil {
+			slog.Error("invalid audit retention", "err", err)
+			os.Exit(1)
+		}
+		gameState.AuditRetention = d
+	}
+	go gameState.RunAuditRetention(context.Background(), 24*time.Hour)
+
+	go func() {
+		if err := http.ListenAndServe(gameState.AdminAddr, gameState.AdminRouter); err != nil {
+			slog.Error("admin server stopped", "err", err)
+		}
+	}()
+
 	// setup chi server
 	// curl http://localhost:3000
 	http.ListenAndServe(gameState.Port, gameState.Router)
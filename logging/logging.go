@@ -0,0 +1,203 @@
+// logging is a module that sets up structured logging with log/slog.
+// Every record gets the request id and user of the context it is logged
+// with, each component can log at its own level, and secrets such as
+// passwords, tokens and game answers are always redacted.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Keys of the attributes added to records.
+const (
+	ComponentKey = "component"
+	RequestIDKey = "request_id"
+	UserKey      = "user"
+)
+
+// Formats of the log output.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted replaces the values of secret attributes.
+const Redacted = "[REDACTED]"
+
+// Config says how logs are written.
+type Config struct {
+	Format string     // json or text, defaults to text
+	Level  slog.Level // level of components without their own level
+	// Components sets the level of individual components, e.g. to debug
+	// the matchmaker without debugging everything else.
+	Components map[string]slog.Level
+}
+
+// ConfigFromEnv reads the config from LOG_FORMAT, LOG_LEVEL and
+// LOG_LEVELS, which sets component levels as a comma separated list,
+// e.g. LOG_LEVELS=matchmaking=debug,http=warn.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Format:     strings.ToLower(os.Getenv("LOG_FORMAT")),
+		Components: make(map[string]slog.Level),
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := cfg.Level.UnmarshalText([]byte(v)); err != nil {
+			return cfg, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+		}
+	}
+	for _, component := range strings.Split(os.Getenv("LOG_LEVELS"), ",") {
+		if strings.TrimSpace(component) == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(component, "=")
+		if !ok {
+			return cfg, fmt.Errorf("invalid LOG_LEVELS entry %q, must be component=level", component)
+		}
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+			return cfg, fmt.Errorf("invalid level for component %s: %w", name, err)
+		}
+		cfg.Components[strings.TrimSpace(name)] = l
+	}
+	return cfg, nil
+}
+
+// New returns a logger that writes to w.
+func New(w io.Writer, cfg Config) *slog.Logger {
+	// the handler has to let through the most verbose level of any
+	// component, the context handler then filters by component
+	lowest := cfg.Level
+	for _, level := range cfg.Components {
+		if level < lowest {
+			lowest = level
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lowest, ReplaceAttr: redact}
+	var h slog.Handler
+	if cfg.Format == FormatJSON {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(&contextHandler{
+		next:       h,
+		level:      cfg.Level,
+		base:       cfg.Level,
+		components: cfg.Components,
+	})
+}
+
+// Setup makes a logger writing to stderr the default logger, which is
+// also used by the standard library's log package.
+func Setup(cfg Config) {
+	slog.SetDefault(New(os.Stderr, cfg))
+}
+
+// Component returns the default logger for a part of the program.
+func Component(name string) *slog.Logger {
+	return slog.Default().With(ComponentKey, name)
+}
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	userKey
+	userSlotKey
+)
+
+// WithRequestID returns a context whose logs include the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// WithUser returns a context whose logs include the user. The user is
+// also set in the slot added by WithUserSlot, if there is one.
+func WithUser(ctx context.Context, username string) context.Context {
+	if slot, ok := ctx.Value(userSlotKey).(*string); ok {
+		*slot = username
+	}
+	return context.WithValue(ctx, userKey, username)
+}
+
+// WithUserSlot returns a context with room for a user that is only known
+// later on, e.g. once a request has been authenticated. Logs written with
+// the returned context include the user once WithUser has been called on
+// it or a context derived from it.
+func WithUserSlot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, userSlotKey, new(string))
+}
+
+// contextHandler adds the request id and user from the context to every
+// record and filters records by the level of their component.
+type contextHandler struct {
+	next       slog.Handler
+	level      slog.Level // level of this logger's component
+	base       slog.Level // level of components without their own level
+	components map[string]slog.Level
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level && h.next.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctx.Value(requestIDKey).(string); ok && id != "" {
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	if user, ok := ctx.Value(userKey).(string); ok && user != "" {
+		r.AddAttrs(slog.String(UserKey, user))
+	} else if slot, ok := ctx.Value(userSlotKey).(*string); ok && *slot != "" {
+		r.AddAttrs(slog.String(UserKey, *slot))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	for _, a := range attrs {
+		if a.Key != ComponentKey {
+			continue
+		}
+		c.level = c.base
+		if level, ok := c.components[a.Value.String()]; ok {
+			c.level = level
+		}
+	}
+	c.next = h.next.WithAttrs(attrs)
+	return &c
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.next = h.next.WithGroup(name)
+	return &c
+}
+
+// secretKeys are redacted wherever they appear in a key, exactKeys only
+// when they are the whole key since e.g. an answer to a question is not
+// secret but the answer of a game is.
+var (
+	secretKeys = []string{"password", "token", "secret", "authorization", "cookie"}
+	exactKeys  = map[string]bool{"answer": true, "invite_code": true, "invitecode": true}
+)
+
+// redact replaces the values of secret attributes.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	if exactKeys[key] {
+		return slog.String(a.Key, Redacted)
+	}
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+	return a
+}
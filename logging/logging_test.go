@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestUserSlot(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, Config{Format: FormatJSON})
+	ctx := WithUserSlot(context.Background())
+	// the user is set on a context derived from the one the slot is in
+	WithUser(ctx, "captainnobody1")
+	logger.InfoContext(ctx, "request")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("output is not JSON: %v: %s", err, buf.String())
+	}
+	if record[UserKey] != "captainnobody1" {
+		t.Errorf("user missing from %v", record)
+	}
+}
+
+func TestContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, Config{Format: FormatJSON})
+	ctx := WithUser(WithRequestID(context.Background(), "req-1"), "captainnobody1")
+	logger.InfoContext(ctx, "joined game", "game_id", 3)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("output is not JSON: %v: %s", err, buf.String())
+	}
+	if record[RequestIDKey] != "req-1" || record[UserKey] != "captainnobody1" {
+		t.Errorf("request id and user missing from %v", record)
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, Config{Format: FormatText})
+	logger.Info("registered",
+		"password", "hunter2",
+		"new_password", "hunter3",
+		"invite_token", "abc123",
+		"answer", "gopher",
+		slog.Group("req", "Authorization", "Basic Zm9vOmJhcg=="),
+		"question_answer", "yes",
+	)
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "hunter3", "abc123", "gopher", "Zm9vOmJhcg=="} {
+		if strings.Contains(out, secret) {
+			t.Errorf("%s was not redacted: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "question_answer=yes") {
+		t.Errorf("only secret answers should be redacted: %s", out)
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, Config{
+		Level:      slog.LevelWarn,
+		Components: map[string]slog.Level{"matchmaking": slog.LevelDebug},
+	})
+	logger.With(ComponentKey, "http").Info("hidden")
+	logger.With(ComponentKey, "matchmaking").Debug("shown")
+	logger.Warn("also shown")
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("http info should be filtered at warn: %s", out)
+	}
+	if !strings.Contains(out, "shown") || !strings.Contains(out, "also shown") {
+		t.Errorf("expected matchmaking debug and warn logs: %s", out)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("LOG_FORMAT", "JSON")
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("LOG_LEVELS", "matchmaking=warn, http=error")
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Format != FormatJSON || cfg.Level != slog.LevelDebug {
+		t.Errorf("got format %s level %s", cfg.Format, cfg.Level)
+	}
+	if cfg.Components["matchmaking"] != slog.LevelWarn || cfg.Components["http"] != slog.LevelError {
+		t.Errorf("got component levels %v", cfg.Components)
+	}
+
+	t.Setenv("LOG_LEVELS", "matchmaking")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("expected an error for a component without a level")
+	}
+}
@@ -0,0 +1,223 @@
+// matchmaking is a module that groups players waiting for a quick match
+// into new games.
+package matchmaking
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/soypete/golang-cli-game/logging"
+)
+
+// Ticket is a player waiting in the queue along with their preferences.
+type Ticket struct {
+	Username   string
+	Category   string // empty if the player does not mind
+	SkillBand  string // empty if the player does not mind
+	EnqueuedAt time.Time
+}
+
+// Match is a group of players that will play a game together.
+type Match struct {
+	Host      string
+	Players   []string // every player in the match, including the host
+	Category  string
+	SkillBand string
+}
+
+// CreateFunc creates the game for a match and returns its id.
+type CreateFunc func(Match) (int64, error)
+
+// Status is a player's place in the queue.
+type Status struct {
+	Username  string
+	Queued    bool
+	Position  int // 1 is next in line
+	Waiting   string
+	Category  string
+	SkillBand string
+	GameID    int64 // set once the player has been matched
+}
+
+// QueueStats describe everyone waiting in the queue.
+type QueueStats struct {
+	Waiting    int
+	ByCategory map[string]int
+	Matches    int // matches made since the queue started
+	OldestWait string
+}
+
+// ErrAlreadyQueued is returned when a player joins the queue twice.
+var ErrAlreadyQueued = errors.New("already in the matchmaking queue")
+
+// assignments are kept this long so players can find out which game they
+// were matched into.
+const assignmentTTL = time.Hour
+
+type assignment struct {
+	gameID    int64
+	matchedAt time.Time
+}
+
+// Queue holds the players waiting for a match. The worker started by Run
+// periodically asks the strategy for matches and creates their games.
+type Queue struct {
+	mu       sync.Mutex
+	waiting  []Ticket
+	assigned map[string]assignment
+	matches  int
+	strategy Strategy
+	create   CreateFunc
+	now      func() time.Time
+}
+
+// NewQueue returns an empty queue that groups players with the strategy
+// and creates games with create.
+func NewQueue(strategy Strategy, create CreateFunc) *Queue {
+	return &Queue{
+		assigned: make(map[string]assignment),
+		strategy: strategy,
+		create:   create,
+		now:      time.Now,
+	}
+}
+
+// Join adds the player to the back of the queue.
+func (q *Queue) Join(t Ticket) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, w := range q.waiting {
+		if w.Username == t.Username {
+			return ErrAlreadyQueued
+		}
+	}
+	delete(q.assigned, t.Username)
+	t.EnqueuedAt = q.now()
+	q.waiting = append(q.waiting, t)
+	return nil
+}
+
+// Leave removes the player from the queue. It reports whether they were
+// waiting.
+func (q *Queue) Leave(username string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, w := range q.waiting {
+		if w.Username == username {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the player's place in the queue, or the game they were
+// matched into.
+func (q *Queue) Status(username string) Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	status := Status{Username: username}
+	for i, w := range q.waiting {
+		if w.Username == username {
+			status.Queued = true
+			status.Position = i + 1
+			status.Waiting = q.now().Sub(w.EnqueuedAt).Round(time.Second).String()
+			status.Category = w.Category
+			status.SkillBand = w.SkillBand
+			return status
+		}
+	}
+	if a, ok := q.assigned[username]; ok {
+		status.GameID = a.gameID
+	}
+	return status
+}
+
+// Stats returns an overview of the queue.
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := QueueStats{
+		Waiting:    len(q.waiting),
+		ByCategory: make(map[string]int),
+		Matches:    q.matches,
+	}
+	for _, w := range q.waiting {
+		stats.ByCategory[w.Category]++
+	}
+	if len(q.waiting) > 0 {
+		stats.OldestWait = q.now().Sub(q.waiting[0].EnqueuedAt).Round(time.Second).String()
+	}
+	return stats
+}
+
+// Run matches players every interval until the context is cancelled.
+func (q *Queue) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			q.tick()
+		}
+	}
+}
+
+// tick runs one round of matchmaking.
+func (q *Queue) tick() {
+	q.mu.Lock()
+	now := q.now()
+	for username, a := range q.assigned {
+		if now.Sub(a.matchedAt) > assignmentTTL {
+			delete(q.assigned, username)
+		}
+	}
+	matches := q.strategy.Match(append([]Ticket(nil), q.waiting...), now)
+	matched := make(map[string]bool)
+	for _, m := range matches {
+		for _, p := range m.Players {
+			matched[p] = true
+		}
+	}
+	var pending []Ticket
+	removed := make(map[string]Ticket)
+	for _, w := range q.waiting {
+		if matched[w.Username] {
+			removed[w.Username] = w
+			continue
+		}
+		pending = append(pending, w)
+	}
+	q.waiting = pending
+	q.mu.Unlock()
+
+	// games are created without holding the lock so players can keep
+	// joining and checking their status.
+	for _, m := range matches {
+		gameID, err := q.create(m)
+		q.mu.Lock()
+		if err != nil {
+			logging.Component("matchmaking").Error("unable to create matchmaking game", "players", m.Players, "err", err)
+			// put the players back at the front of the queue so they
+			// do not lose their place
+			var requeue []Ticket
+			for _, p := range m.Players {
+				if t, ok := removed[p]; ok {
+					requeue = append(requeue, t)
+				}
+			}
+			q.waiting = append(requeue, q.waiting...)
+			q.mu.Unlock()
+			continue
+		}
+		q.matches++
+		for _, p := range m.Players {
+			q.assigned[p] = assignment{gameID: gameID, matchedAt: now}
+		}
+		q.mu.Unlock()
+	}
+}
@@ -0,0 +1,113 @@
+package matchmaking
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func tickets(start time.Time, category string, names ...string) []Ticket {
+	var ts []Ticket
+	for i, name := range names {
+		ts = append(ts, Ticket{Username: name, Category: category, EnqueuedAt: start.Add(time.Duration(i) * time.Second)})
+	}
+	return ts
+}
+
+func TestGroupStrategy(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	strategy := GroupStrategy{MinPlayers: 3, MaxPlayers: 4, Timeout: time.Minute}
+
+	tests := []struct {
+		name    string
+		waiting []Ticket
+		now     time.Time
+		want    []Match
+	}{
+		{
+			name:    "not enough players",
+			waiting: tickets(start, "", "a", "b"),
+			now:     start.Add(time.Second),
+		},
+		{
+			name:    "enough players",
+			waiting: tickets(start, "", "a", "b", "c"),
+			now:     start.Add(time.Second),
+			want:    []Match{{Host: "a", Players: []string{"a", "b", "c"}}},
+		},
+		{
+			name:    "full games are split",
+			waiting: tickets(start, "", "a", "b", "c", "d", "e"),
+			now:     start.Add(time.Second),
+			want:    []Match{{Host: "a", Players: []string{"a", "b", "c", "d"}}},
+		},
+		{
+			name:    "categories are not mixed",
+			waiting: append(tickets(start, "animals", "a", "b"), tickets(start.Add(time.Second), "food", "c")...),
+			now:     start.Add(time.Second),
+		},
+		{
+			name:    "timeout matches two players",
+			waiting: append(tickets(start, "animals", "a", "b"), tickets(start, "food", "c")...),
+			now:     start.Add(time.Minute),
+			want:    []Match{{Host: "a", Players: []string{"a", "b"}, Category: "animals"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strategy.Match(tt.waiting, tt.now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wrong matches: got %+v want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueue(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	var created []Match
+	fail := false
+	q := NewQueue(GroupStrategy{MinPlayers: 2, MaxPlayers: 5, Timeout: time.Minute}, func(m Match) (int64, error) {
+		if fail {
+			return 0, errors.New("db is down")
+		}
+		created = append(created, m)
+		return int64(len(created)), nil
+	})
+	q.now = func() time.Time { return now }
+
+	if err := q.Join(Ticket{Username: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Join(Ticket{Username: "a"}); !errors.Is(err, ErrAlreadyQueued) {
+		t.Errorf("joined twice: got %v want %v", err, ErrAlreadyQueued)
+	}
+	q.Join(Ticket{Username: "b"})
+	if status := q.Status("b"); !status.Queued || status.Position != 2 {
+		t.Errorf("wrong status: %+v", status)
+	}
+
+	fail = true
+	q.tick()
+	if status := q.Status("a"); !status.Queued || status.Position != 1 {
+		t.Errorf("players were not requeued after a failure: %+v", status)
+	}
+
+	fail = false
+	q.tick()
+	if len(created) != 1 || created[0].Host != "a" {
+		t.Fatalf("wrong games created: %+v", created)
+	}
+	if status := q.Status("b"); status.Queued || status.GameID != 1 {
+		t.Errorf("wrong status after match: %+v", status)
+	}
+	if stats := q.Stats(); stats.Waiting != 0 || stats.Matches != 1 {
+		t.Errorf("wrong stats: %+v", stats)
+	}
+
+	q.Join(Ticket{Username: "c"})
+	if !q.Leave("c") || q.Leave("c") {
+		t.Error("leave did not remove the player exactly once")
+	}
+}
@@ -0,0 +1,80 @@
+package matchmaking
+
+import (
+	"sort"
+	"time"
+)
+
+// Strategy groups the tickets waiting in the queue into matches. Tickets
+// are passed in the order they joined the queue. Any ticket not returned
+// in a match keeps waiting.
+type Strategy interface {
+	Match(waiting []Ticket, now time.Time) []Match
+}
+
+// GroupStrategy matches players that asked for the same category and
+// skill band. A game is made as soon as MinPlayers are waiting, and any
+// group whose oldest ticket has waited longer than Timeout is matched with
+// as few as two players so nobody waits forever.
+type GroupStrategy struct {
+	MinPlayers int
+	MaxPlayers int
+	Timeout    time.Duration
+}
+
+// DefaultStrategy starts games of three to five players, or two once a
+// player has waited a minute.
+var DefaultStrategy = GroupStrategy{
+	MinPlayers: 3,
+	MaxPlayers: 5,
+	Timeout:    time.Minute,
+}
+
+type groupKey struct {
+	category  string
+	skillBand string
+}
+
+// Match implements Strategy.
+func (g GroupStrategy) Match(waiting []Ticket, now time.Time) []Match {
+	groups := make(map[groupKey][]Ticket)
+	var keys []groupKey
+	for _, t := range waiting {
+		k := groupKey{t.Category, t.SkillBand}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], t)
+	}
+	// keep the output stable so the longest waiting group is matched first
+	sort.SliceStable(keys, func(i, j int) bool {
+		return groups[keys[i]][0].EnqueuedAt.Before(groups[keys[j]][0].EnqueuedAt)
+	})
+
+	var matches []Match
+	for _, k := range keys {
+		tickets := groups[k]
+		for len(tickets) >= g.MaxPlayers {
+			matches = append(matches, newMatch(tickets[:g.MaxPlayers]))
+			tickets = tickets[g.MaxPlayers:]
+		}
+		timedOut := len(tickets) > 0 && now.Sub(tickets[0].EnqueuedAt) >= g.Timeout
+		if len(tickets) >= g.MinPlayers || (timedOut && len(tickets) >= 2) {
+			matches = append(matches, newMatch(tickets))
+		}
+	}
+	return matches
+}
+
+// newMatch makes the player that has waited the longest the host.
+func newMatch(tickets []Ticket) Match {
+	m := Match{
+		Host:      tickets[0].Username,
+		Category:  tickets[0].Category,
+		SkillBand: tickets[0].SkillBand,
+	}
+	for _, t := range tickets {
+		m.Players = append(m.Players, t.Username)
+	}
+	return m
+}
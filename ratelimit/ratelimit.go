@@ -0,0 +1,190 @@
+// ratelimit is a module that limits how often clients can do things,
+// using a token bucket per key, and locks out keys that keep failing to
+// log in.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is the rate tokens are added to a bucket and how many it can
+// hold. A zero limit allows everything.
+type Limit struct {
+	Rate  float64 // tokens added per second
+	Burst int     // most tokens a bucket can hold
+}
+
+// Per returns a limit of n events every interval, all of which can be used
+// at once.
+func Per(n int, interval time.Duration) Limit {
+	return Limit{Rate: float64(n) / interval.Seconds(), Burst: n}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter has a token bucket per key, e.g. per IP address or user.
+type Limiter struct {
+	limit Limit
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewLimiter returns a limiter where every key gets the limit.
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the key's bucket. If the bucket is empty it
+// returns false and how long until a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil || l.limit.Rate <= 0 || l.limit.Burst <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// Prune forgets buckets that have been full for at least idle, so keys
+// that stop making requests do not use memory forever.
+func (l *Limiter) Prune(idle time.Duration) {
+	if l == nil || l.limit.Rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	refill := time.Duration(float64(l.limit.Burst) / l.limit.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill+idle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Lockout locks keys out after too many failures in a row. Every failure
+// past the threshold doubles how long the key is locked out for, up to a
+// maximum.
+type Lockout struct {
+	threshold int
+	base, max time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures map[string]*failures
+}
+
+type failures struct {
+	count int
+	until time.Time
+	last  time.Time
+}
+
+// NewLockout returns a lockout that locks a key out for base after
+// threshold failures, doubling for every failure after that up to max.
+func NewLockout(threshold int, base, max time.Duration) *Lockout {
+	return &Lockout{
+		threshold: threshold,
+		base:      base,
+		max:       max,
+		now:       time.Now,
+		failures:  make(map[string]*failures),
+	}
+}
+
+// Locked returns how much longer the key is locked out for.
+func (l *Lockout) Locked(key string) (bool, time.Duration) {
+	if l == nil {
+		return false, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.failures[key]
+	if !ok {
+		return false, 0
+	}
+	if wait := f.until.Sub(l.now()); wait > 0 {
+		return true, wait
+	}
+	return false, 0
+}
+
+// Fail records a failure and returns how long the key is now locked out
+// for, which is zero until it reaches the threshold.
+func (l *Lockout) Fail(key string) time.Duration {
+	if l == nil || l.threshold <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	f, ok := l.failures[key]
+	if !ok {
+		f = &failures{}
+		l.failures[key] = f
+	}
+	f.count++
+	f.last = now
+	if f.count < l.threshold {
+		return 0
+	}
+	wait := l.base
+	for i := l.threshold; i < f.count && wait < l.max; i++ {
+		wait *= 2
+	}
+	if wait > l.max {
+		wait = l.max
+	}
+	f.until = now.Add(wait)
+	return wait
+}
+
+// Succeed clears the key's failures.
+func (l *Lockout) Succeed(key string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	delete(l.failures, key)
+	l.mu.Unlock()
+}
+
+// Prune forgets keys that are not locked out and have not failed for at
+// least idle.
+func (l *Lockout) Prune(idle time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for key, f := range l.failures {
+		if now.After(f.until) && now.Sub(f.last) >= idle {
+			delete(l.failures, key)
+		}
+	}
+}
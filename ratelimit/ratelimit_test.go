@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestLimiter(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	l := NewLimiter(Per(2, time.Second))
+	l.now = c.now
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("ip"); !ok {
+			t.Fatalf("request %d should be allowed by the burst", i)
+		}
+	}
+	ok, wait := l.Allow("ip")
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("got %v %s want throttled for 500ms", ok, wait)
+	}
+	if ok, _ := l.Allow("other ip"); !ok {
+		t.Error("keys should have their own buckets")
+	}
+	c.t = c.t.Add(500 * time.Millisecond)
+	if ok, _ := l.Allow("ip"); !ok {
+		t.Error("a token should have been added")
+	}
+}
+
+func TestLimiterZeroAllowsEverything(t *testing.T) {
+	var nilLimiter *Limiter
+	for _, l := range []*Limiter{nilLimiter, NewLimiter(Limit{})} {
+		for i := 0; i < 100; i++ {
+			if ok, _ := l.Allow("ip"); !ok {
+				t.Fatal("a zero limit should allow everything")
+			}
+		}
+	}
+}
+
+func TestLimiterPrune(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	l := NewLimiter(Per(1, time.Second))
+	l.now = c.now
+	l.Allow("ip")
+	l.Prune(time.Minute)
+	if len(l.buckets) != 1 {
+		t.Fatal("bucket should be kept while refilling")
+	}
+	c.t = c.t.Add(time.Minute + time.Second)
+	l.Prune(time.Minute)
+	if len(l.buckets) != 0 {
+		t.Error("idle bucket should be pruned")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	l := NewLockout(3, time.Second, 5*time.Second)
+	l.now = c.now
+
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := l.Fail("user"); got != w {
+			t.Errorf("failure %d locked out for %s want %s", i+1, got, w)
+		}
+	}
+	if locked, wait := l.Locked("user"); !locked || wait != 5*time.Second {
+		t.Errorf("got %v %s want locked for 5s", locked, wait)
+	}
+	c.t = c.t.Add(5 * time.Second)
+	if locked, _ := l.Locked("user"); locked {
+		t.Error("lockout should have expired")
+	}
+	l.Succeed("user")
+	if got := l.Fail("user"); got != 0 {
+		t.Errorf("success should reset failures, got lockout of %s", got)
+	}
+}
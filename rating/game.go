@@ -0,0 +1,34 @@
+package rating
+
+// Placing is where a player finished in a game. Lower ranks are better and
+// players with the same rank drew.
+type Placing struct {
+	Player string
+	Rank   int
+	Rating Rating
+}
+
+// RateGame returns everyone's new rating after a game. A game with several
+// players is treated as each player having played everyone else, beating
+// those ranked below them and losing to those ranked above.
+func RateGame(placings []Placing) map[string]Rating {
+	ratings := make(map[string]Rating, len(placings))
+	for i, p := range placings {
+		var results []Result
+		for j, o := range placings {
+			if i == j {
+				continue
+			}
+			score := 0.5
+			switch {
+			case p.Rank < o.Rank:
+				score = 1
+			case p.Rank > o.Rank:
+				score = 0
+			}
+			results = append(results, Result{Opponent: o.Rating, Score: score})
+		}
+		ratings[p.Player] = p.Rating.Update(results)
+	}
+	return ratings
+}
@@ -0,0 +1,107 @@
+// rating is a module that works out player skill ratings with the Glicko
+// rating system. Every player has a rating, which goes up when they beat
+// players and down when they lose, and a deviation, which is how sure we
+// are of the rating. New and inactive players have a high deviation so
+// their rating moves quickly until it settles.
+package rating
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// DefaultRating is the rating of a player that has not played yet.
+	DefaultRating = 1500
+	// DefaultDeviation is the deviation of a player that has not played yet,
+	// and the most uncertain a rating can be.
+	DefaultDeviation = 350
+	// MinDeviation stops ratings from becoming so certain they stop moving.
+	MinDeviation = 30
+	// inactivityGrowth is how much deviation a rating regains for every
+	// period the player does not play, chosen so a settled rating is back
+	// to the default deviation after about a year without playing.
+	inactivityGrowth = 34.6
+	// Period is how long a rating period lasts for inactivity.
+	Period = 7 * 24 * time.Hour
+)
+
+// q is the Glicko scaling constant, ln(10)/400.
+var q = math.Ln10 / 400
+
+// Rating is a player's skill.
+type Rating struct {
+	Rating    float64
+	Deviation float64
+}
+
+// New returns the rating of a player that has not played yet.
+func New() Rating {
+	return Rating{Rating: DefaultRating, Deviation: DefaultDeviation}
+}
+
+// Conservative returns a rating the player is very likely to be above,
+// which keeps players with few games from topping leaderboards.
+func (r Rating) Conservative() float64 {
+	return r.Rating - 2*r.Deviation
+}
+
+// Decay returns the rating after the player has not played for idle, which
+// makes the rating less certain.
+func (r Rating) Decay(idle time.Duration) Rating {
+	periods := float64(idle / Period)
+	if periods <= 0 {
+		return r
+	}
+	r.Deviation = math.Min(math.Sqrt(r.Deviation*r.Deviation+inactivityGrowth*inactivityGrowth*periods), DefaultDeviation)
+	return r
+}
+
+// Result is the outcome of playing against one opponent. Score is 1 for a
+// win, 0.5 for a draw and 0 for a loss.
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+// g reduces the impact of a game against an opponent with an uncertain
+// rating.
+func g(deviation float64) float64 {
+	return 1 / math.Sqrt(1+3*q*q*deviation*deviation/(math.Pi*math.Pi))
+}
+
+// Expected returns the score r is expected to get against the opponent.
+func (r Rating) Expected(opponent Rating) float64 {
+	return 1 / (1 + math.Pow(10, -g(opponent.Deviation)*(r.Rating-opponent.Rating)/400))
+}
+
+// Update returns the rating after the results of a rating period. With no
+// results the rating is unchanged.
+func (r Rating) Update(results []Result) Rating {
+	if len(results) == 0 {
+		return r
+	}
+	var dInv, delta float64
+	for _, res := range results {
+		gd := g(res.Opponent.Deviation)
+		e := r.Expected(res.Opponent)
+		dInv += q * q * gd * gd * e * (1 - e)
+		delta += gd * (res.Score - e)
+	}
+	denominator := 1/(r.Deviation*r.Deviation) + dInv
+	return Rating{
+		Rating:    r.Rating + q/denominator*delta,
+		Deviation: math.Max(math.Sqrt(1/denominator), MinDeviation),
+	}
+}
+
+// bandWidth is the rating range grouped into each skill band.
+const bandWidth = 200
+
+// Band returns the skill band the rating falls in, e.g. 1400-1599, so
+// players can be matched with others of similar skill.
+func Band(r Rating) string {
+	low := int(math.Floor(r.Rating/bandWidth)) * bandWidth
+	return fmt.Sprintf("%d-%d", low, low+bandWidth-1)
+}
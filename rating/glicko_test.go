@@ -0,0 +1,68 @@
+package rating
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestUpdate checks the example from Glickman's paper describing the
+// Glicko system.
+func TestUpdate(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200}
+	got := player.Update([]Result{
+		{Opponent: Rating{1400, 30}, Score: 1},
+		{Opponent: Rating{1550, 100}, Score: 0},
+		{Opponent: Rating{1700, 300}, Score: 0},
+	})
+	if math.Abs(got.Rating-1464) > 1 || math.Abs(got.Deviation-151.4) > 1 {
+		t.Errorf("got %+v want rating 1464 and deviation 151.4", got)
+	}
+	if same := player.Update(nil); same != player {
+		t.Errorf("rating changed without results: %+v", same)
+	}
+}
+
+func TestDecay(t *testing.T) {
+	r := Rating{Rating: 1600, Deviation: 50}
+	if got := r.Decay(time.Hour); got != r {
+		t.Errorf("decayed within a period: %+v", got)
+	}
+	if got := r.Decay(10 * Period); got.Deviation <= 50 || got.Rating != 1600 {
+		t.Errorf("deviation did not grow: %+v", got)
+	}
+	if got := r.Decay(1000 * Period); got.Deviation != DefaultDeviation {
+		t.Errorf("deviation grew past the default: %+v", got)
+	}
+}
+
+func TestRateGame(t *testing.T) {
+	got := RateGame([]Placing{
+		{Player: "winner", Rank: 1, Rating: New()},
+		{Player: "second", Rank: 2, Rating: New()},
+		{Player: "tied", Rank: 2, Rating: New()},
+		{Player: "last", Rank: 4, Rating: Rating{Rating: 1800, Deviation: 60}},
+	})
+	if got["winner"].Rating <= DefaultRating || got["winner"].Deviation >= DefaultDeviation {
+		t.Errorf("winner should gain rating and certainty: %+v", got["winner"])
+	}
+	if got["second"] != got["tied"] {
+		t.Errorf("tied players should move the same: %+v and %+v", got["second"], got["tied"])
+	}
+	if got["last"].Rating >= 1800 {
+		t.Errorf("last place should lose rating: %+v", got["last"])
+	}
+	// a settled rating moves less than a new one
+	if 1800-got["last"].Rating >= got["winner"].Rating-DefaultRating {
+		t.Errorf("settled rating moved too much: %+v", got["last"])
+	}
+}
+
+func TestBand(t *testing.T) {
+	tests := map[float64]string{1500: "1400-1599", 1399.9: "1200-1399", 1600: "1600-1799", -10: "-200--1"}
+	for r, want := range tests {
+		if got := Band(Rating{Rating: r}); got != want {
+			t.Errorf("Band(%v) = %s want %s", r, got, want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// undoWindow is how long a deleted account can be restored before it is
+// anonymized.
+const undoWindow = 7 * 24 * time.Hour
+
+// usernamePattern is what new usernames must look like. Names starting
+// with deleted- are kept for anonymized accounts.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// validUsername checks a new username.
+func validUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return errors.New("usernames must be 3 to 32 letters, numbers, dots, dashes or underscores")
+	}
+	if strings.HasPrefix(strings.ToLower(username), "deleted-") {
+		return errors.New("usernames cannot start with deleted-")
+	}
+	return nil
+}
+
+// UserExport is everything stored about a user, returned by
+// /register/{username}/export.
+type UserExport struct {
+	ExportedAt      time.Time
+	Profile         database.User
+	UsernameHistory []database.UsernameChange
+	Stats           database.UserStats
+	RatingHistory   []database.RatingChange
+	Achievements    []database.Achievement
+	Games           []database.Game
+	Questions       []database.Question
+	Guesses         []database.Guess
+}
+
+// /register/{username}/restore
+// undoes deleting the account if it was deleted less than a week ago.
+// The deleted account's password must be used since it can no longer log in.
+func (s State) restoreUser(w http.ResponseWriter, r *http.Request) {
+	username, password, ok := r.BasicAuth()
+	if !ok || username != chi.URLParam(r, "username") {
+		http.Error(w, http.StatusText(http.StatusUnauthorized)+", Authorization header must be the deleted username:password", http.StatusUnauthorized)
+		return
+	}
+	if s.loginLocked(w, r, username) {
+		return
+	}
+	err := s.db.RestoreUser(username, password, undoWindow)
+	if err == nil || errors.Is(err, database.ErrUserNotFound) {
+		s.limiter.loginResult(s.context(), r, username, err == nil)
+	}
+	if errors.Is(err, database.ErrUserNotFound) {
+		s.auditLogin(r, username, false, "restore")
+		http.Error(w, http.StatusText(http.StatusNotFound)+", no deleted account to restore", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to restore user")
+		return
+	}
+	s.recordAudit(r, username, auditRestore, username, "")
+	w.Write([]byte(fmt.Sprintf("user %s restored", username)))
+}
+
+// /register/{username}/rename?to=...
+// changes the username, keeping the user's games and history.
+func (s State) renameUser(w http.ResponseWriter, r *http.Request) {
+	username, err := getAndValidateUsername(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	newUsername := strings.TrimSpace(r.URL.Query().Get("to"))
+	if err := validUsername(newUsername); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = s.db.RenameUser(username, newUsername)
+	if errors.Is(err, database.ErrUsernameTaken) {
+		http.Error(w, http.StatusText(http.StatusConflict)+", "+newUsername+" is taken", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to rename user")
+		return
+	}
+	s.recordAudit(r, username, auditRename, username, "to "+newUsername)
+	w.Write([]byte(fmt.Sprintf("user %s is now %s, log in with the new username", username, newUsername)))
+}
+
+// /register/{username}/export
+// downloads everything stored about the user as a JSON archive.
+func (s State) exportUser(w http.ResponseWriter, r *http.Request) {
+	username, err := getAndValidateUsername(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	export := UserExport{ExportedAt: time.Now()}
+	if export.Profile, err = s.db.GetUserData(username); err != nil {
+		s.handle500Err(w, " unable to get user")
+		return
+	}
+	if export.UsernameHistory, err = s.db.GetUsernameHistory(username); err != nil {
+		s.handle500Err(w, " unable to get username history")
+		return
+	}
+	if export.Stats, err = s.db.GetUserStats(username); err != nil {
+		s.handle500Err(w, " unable to get user stats")
+		return
+	}
+	if export.RatingHistory, err = s.db.GetRatingHistory(username); err != nil {
+		s.handle500Err(w, " unable to get rating history")
+		return
+	}
+	if export.Achievements, err = s.db.GetUserAchievements(username); err != nil {
+		s.handle500Err(w, " unable to get achievements")
+		return
+	}
+	if export.Games, err = s.db.GetUserGames(username); err != nil {
+		s.handle500Err(w, " unable to get games")
+		return
+	}
+	if export.Questions, err = s.db.GetUserQuestions(username); err != nil {
+		s.handle500Err(w, " unable to get questions")
+		return
+	}
+	if export.Guesses, err = s.db.GetUserGuesses(username); err != nil {
+		s.handle500Err(w, " unable to get guesses")
+		return
+	}
+	// the export must not give away other hosts' secrets
+	for i, game := range export.Games {
+		if game.Host != username {
+			export.Games[i].InviteCode = ""
+			if !game.Ended {
+				export.Games[i].Answer = ""
+			}
+		}
+	}
+
+	exportJson, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		s.handle500Err(w, " unable to marshal export")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-export.json"`, username))
+	w.Write(exportJson)
+}
+
+// runAccountCleanup anonymizes accounts whose undo window has passed every
+// interval until the context is cancelled.
+func (s State) runAccountCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		runCtx, span := tracer().Start(ctx, "worker.accountCleanup")
+		n, err := s.withContext(runCtx).db.AnonymizeDeletedUsers(undoWindow)
+		if err != nil {
+			logging.Component(logAccount).ErrorContext(runCtx, "unable to anonymize deleted users", "err", err)
+		}
+		if n > 0 {
+			logging.Component(logAccount).InfoContext(runCtx, "anonymized deleted users", "count", n)
+		}
+		span.SetAttributes(attribute.Int("users.anonymized", n))
+		span.End()
+		s.workers.ran("accountCleanup", interval, start)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
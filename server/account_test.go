@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// deletedDB has no deleted accounts within the undo window.
+type deletedDB struct {
+	passDB
+}
+
+func (db *deletedDB) RestoreUser(username, password string, window time.Duration) error {
+	return database.ErrUserNotFound
+}
+
+func (db *deletedDB) DeleteUsername(username string) error {
+	return database.ErrUserNotFound
+}
+
+func accountRouter(s State) *chi.Mux {
+	r := chi.NewRouter()
+	r.Route("/register/{username}", func(r chi.Router) {
+		r.Delete("/delete", s.deleteUsername)
+		r.Get("/restore", s.restoreUser)
+		r.Get("/rename", s.renameUser)
+		r.Get("/export", s.exportUser)
+	})
+	return r
+}
+
+func TestAccountLifecycle(t *testing.T) {
+	tests := []struct {
+		name   string
+		db     database.Connection
+		method string
+		path   string
+		want   int
+	}{
+		{"delete", new(passDB), "DELETE", "/register/captainnobody1/delete", http.StatusOK},
+		{"delete twice", new(deletedDB), "DELETE", "/register/captainnobody1/delete", http.StatusNotFound},
+		{"delete someone else", new(passDB), "DELETE", "/register/player2/delete", http.StatusBadRequest},
+		{"restore", new(passDB), "GET", "/register/captainnobody1/restore", http.StatusOK},
+		{"restore too late", new(deletedDB), "GET", "/register/captainnobody1/restore", http.StatusNotFound},
+		{"restore someone else", new(passDB), "GET", "/register/player2/restore", http.StatusUnauthorized},
+		{"restore db error", new(failDB), "GET", "/register/captainnobody1/restore", http.StatusInternalServerError},
+		{"rename", new(passDB), "GET", "/register/captainnobody1/rename?to=captain_somebody", http.StatusOK},
+		{"rename taken", new(passDB), "GET", "/register/captainnobody1/rename?to=player2", http.StatusConflict},
+		{"rename invalid", new(passDB), "GET", "/register/captainnobody1/rename?to=a", http.StatusBadRequest},
+		{"rename reserved", new(passDB), "GET", "/register/captainnobody1/rename?to=deleted-12", http.StatusBadRequest},
+		{"rename someone else", new(passDB), "GET", "/register/player2/rename?to=captain", http.StatusBadRequest},
+		{"rename db error", new(failDB), "GET", "/register/captainnobody1/rename?to=captain", http.StatusInternalServerError},
+		{"export db error", new(failDB), "GET", "/register/captainnobody1/export", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", getAuthHeader())
+			accountRouter(State{db: tt.db}).ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestExportUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/register/captainnobody1/export", nil)
+	req.Header.Set("Authorization", getAuthHeader())
+	accountRouter(State{db: new(passDB)}).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, "captainnobody1-export.json") {
+		t.Errorf("wrong content disposition %q", got)
+	}
+	var export UserExport
+	if err := json.Unmarshal(w.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Profile.Username != "captainnobody1" || len(export.UsernameHistory) != 1 ||
+		len(export.Questions) != 1 || len(export.Guesses) != 1 || len(export.Achievements) != 2 {
+		t.Errorf("export is missing data: %s", w.Body.String())
+	}
+	games := export.Games
+	if len(games) != 3 {
+		t.Fatalf("got %d games want 3", len(games))
+	}
+	if games[0].Answer != "gopher" || games[0].InviteCode != "mine" {
+		t.Errorf("own game was redacted: %+v", games[0])
+	}
+	if games[1].Answer != "" || games[1].InviteCode != "" {
+		t.Errorf("another host's secrets were exported: %+v", games[1])
+	}
+	if games[2].Answer != "cat" {
+		t.Errorf("answer of ended game was redacted: %+v", games[2])
+	}
+}
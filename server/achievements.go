@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/achievements"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
+)
+
+// UnlockedAchievement is an achievement the user has along with when and
+// where they unlocked it.
+type UnlockedAchievement struct {
+	ID          string
+	Name        string
+	Description string
+	GameID      int64
+	UnlockedAt  time.Time
+}
+
+// boolMetric turns a condition into a metric value.
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// achievementMetrics describes the player's part in the ended game for
+// the achievement conditions. The stats must already include the game.
+func achievementMetrics(game database.Game, standing database.Standing, stats database.UserStats, fuzzyWin bool) map[string]float64 {
+	var players int
+	for _, p := range game.Players {
+		if p != game.Host {
+			players++
+		}
+	}
+	solved := standing.Won && !standing.Host
+	return map[string]float64{
+		achievements.MetricWon:           boolMetric(standing.Won),
+		achievements.MetricSolved:        boolMetric(solved),
+		achievements.MetricHostStumped:   boolMetric(standing.Won && standing.Host && game.Outcome == database.OutcomeStumped),
+		achievements.MetricFuzzyWin:      boolMetric(solved && fuzzyWin),
+		achievements.MetricQuestionsUsed: float64(game.QuestionsUsed()),
+		achievements.MetricWrongGuesses:  float64(standing.WrongGuesses),
+		achievements.MetricPlayers:       float64(players),
+		achievements.MetricPoints:        float64(standing.Points),
+		achievements.MetricStreak:        float64(stats.CurrentStreak),
+		achievements.MetricGamesPlayed:   float64(stats.GamesPlayed),
+		achievements.MetricGamesWon:      float64(stats.GamesWon),
+	}
+}
+
+// awardAchievements unlocks the achievements everyone in the ended game
+// earned and announces new ones on the game's event stream.
+func (s State) awardAchievements(game database.Game, standings []database.Standing, guesses []database.Guess) {
+	fuzzyWin := false
+	for _, g := range guesses {
+		if g.Correct {
+			_, fuzzyWin = matchAnswer(g.GuessText, game.Answer)
+			break
+		}
+	}
+	for _, standing := range standings {
+		stats, err := s.db.GetUserStats(standing.Username)
+		if err != nil && !errors.Is(err, database.ErrUserNotFound) {
+			logging.Component(logGame).ErrorContext(s.context(), "unable to get stats for achievements", "game_id", game.GameID, "username", standing.Username, "err", err)
+			continue
+		}
+		earned := achievements.Earned(achievements.Default, achievementMetrics(game, standing, stats, fuzzyWin))
+		if len(earned) == 0 {
+			continue
+		}
+		ids := make([]string, 0, len(earned))
+		for _, d := range earned {
+			ids = append(ids, d.ID)
+		}
+		// unlocked achievements are broadcast from the game's history
+		if _, err := s.db.UnlockAchievements(standing.Username, game.GameID, ids); err != nil {
+			logging.Component(logGame).ErrorContext(s.context(), "unable to unlock achievements", "game_id", game.GameID, "username", standing.Username, "err", err)
+		}
+	}
+}
+
+// userAchievements returns the achievements the user has unlocked that
+// are still defined.
+func (s State) userAchievements(username string) ([]UnlockedAchievement, error) {
+	unlocks, err := s.db.GetUserAchievements(username)
+	if err != nil {
+		return nil, err
+	}
+	var unlocked []UnlockedAchievement
+	for _, u := range unlocks {
+		d, ok := achievements.Find(achievements.Default, u.AchievementID)
+		if !ok {
+			continue
+		}
+		unlocked = append(unlocked, UnlockedAchievement{
+			ID:          d.ID,
+			Name:        d.Name,
+			Description: d.Description,
+			GameID:      u.GameID,
+			UnlockedAt:  u.UnlockedAt,
+		})
+	}
+	return unlocked, nil
+}
+
+// /users/{username}/achievements
+// returns the achievements the user has unlocked.
+func (s State) getUserAchievements(w http.ResponseWriter, r *http.Request) {
+	if !s.statsVisible(w, r) {
+		return
+	}
+	unlocked, err := s.userAchievements(chi.URLParam(r, "username"))
+	if err != nil {
+		s.handle500Err(w, " unable to get achievements")
+		return
+	}
+	achievementsJson, err := json.Marshal(unlocked)
+	if err != nil {
+		s.handle500Err(w, " unable to marshal achievements")
+		return
+	}
+	w.Write(achievementsJson)
+}
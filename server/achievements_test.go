@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+func TestMatchAnswer(t *testing.T) {
+	tests := []struct {
+		guess, answer string
+		correct       bool
+		fuzzy         bool
+	}{
+		{"Gopher", "gopher", true, false},
+		{"  golden   retriever ", "Golden Retriever", true, false},
+		{"gopehr", "gopher", false, false},
+		{"gophr", "gopher", true, true},
+		{"golden retreiver", "golden retriever", true, true},
+		{"cat", "dog", false, false},
+		{"cats", "cat", false, false},
+		{"anything", "", false, false},
+	}
+	for _, tt := range tests {
+		correct, fuzzy := matchAnswer(tt.guess, tt.answer)
+		if correct != tt.correct || fuzzy != tt.fuzzy {
+			t.Errorf("matchAnswer(%q, %q) = %v, %v want %v, %v", tt.guess, tt.answer, correct, fuzzy, tt.correct, tt.fuzzy)
+		}
+	}
+}
+
+// unlockDB records the achievements unlocked in a game's history.
+type unlockDB struct {
+	passDB
+	history []database.GameEvent
+}
+
+func (db *unlockDB) UnlockAchievements(username string, gameID int64, ids []string) ([]string, error) {
+	for _, id := range ids {
+		db.history = append(db.history, database.GameEvent{
+			GameID:   gameID,
+			Type:     database.GameEventAchievement,
+			Username: username,
+			Data:     database.GameEventData{Achievement: id},
+		})
+	}
+	return ids, nil
+}
+
+func TestAwardAchievements(t *testing.T) {
+	db := new(unlockDB)
+	s := State{db: db}
+
+	game := database.Game{GameID: 1, Host: "host", Players: []string{"host", "player2"}, Answer: "gopher", QuestionCount: 3, Outcome: database.OutcomeSolved}
+	standings := []database.Standing{
+		{Username: "player2", Rank: 1, Won: true, CorrectGuesses: 1},
+		{Username: "host", Rank: 2, Host: true},
+	}
+	s.awardAchievements(game, standings, []database.Guess{{UserID: "player2", GuessText: "gophr", Correct: true}})
+
+	// unlocked achievements are broadcast from the game's history
+	got := make(map[string]bool)
+	for _, h := range db.history {
+		for _, e := range eventsFromHistory(h) {
+			if e.Type != EventAchievement || e.Username != "player2" {
+				t.Errorf("unexpected event %+v", e)
+			}
+			got[e.Message] = true
+		}
+	}
+	for _, want := range []string{
+		"First Win: Win a game as a player or the host",
+		"Quick Solver: Guess the answer in under 5 questions",
+		"Flawless: Guess the answer without a single wrong guess",
+		"Close Enough: Win with a guess that was only nearly right",
+	} {
+		if !got[want] {
+			t.Errorf("missing achievement %q in %v", want, got)
+		}
+	}
+}
+
+func TestUserAchievements(t *testing.T) {
+	s := State{db: new(passDB)}
+	r := chi.NewRouter()
+	r.Get("/users/{username}/achievements", s.getUserAchievements)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/users/player2/achievements", nil)
+	req.Header.Set("Authorization", getAuthHeader())
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	var got []UnlockedAchievement
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	// achievements that are no longer defined are left out
+	if len(got) != 1 || got[0].Name != "First Win" {
+		t.Errorf("wrong achievements: %+v", got)
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/soypete/golang-cli-game/logging"
+)
+
+// DefaultAdminAllowlist lets requests from the host itself read /debug and
+// /metrics without credentials.
+var DefaultAdminAllowlist = []netip.Prefix{
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("::1/128"),
+}
+
+// ParseAllowlist parses a comma separated list of IP addresses and CIDR
+// ranges, e.g. 10.0.0.0/8,192.168.1.5.
+func ParseAllowlist(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowlist address %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowlist range %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// adminRoutes sets up the router for pprof, expvar, metrics and other
+// admin only routes. It is served on its own address so it can be kept
+// off the public network.
+func (s *State) adminRoutes(reg *prometheus.Registry) *chi.Mux {
+	r := chi.NewRouter()
+	// RealIP is left out on purpose: the allowlist must check the address
+	// that connected, not one sent in a header anyone can set.
+	r.Use(middleware.RequestID)
+	r.Use(requestLogger)
+	r.Use(middleware.Recoverer)
+
+	r.Group(func(r chi.Router) {
+		r.Use(s.adminOnly)
+		// add pprof and expvars at /debug/
+		r.Mount("/debug", middleware.Profiler())
+		// add prometheus endpoint at /metrics. Collectors are shown in the
+		// reverse order they are registered.
+		r.Mount("/metrics", promhttp.HandlerFor(
+			reg,
+			promhttp.HandlerOpts{
+				// Opt into OpenMetrics to support exemplars.
+				EnableOpenMetrics: true,
+			},
+		))
+	})
+	// moderating users and games changes them, so even allowlisted
+	// addresses have to log in as an admin
+	r.With(s.adminLogin).Route("/admin", s.adminAPIRoutes)
+	return r
+}
+
+// allowlisted reports whether the request comes from an address on the
+// admin allowlist.
+func (s *State) allowlisted(r *http.Request) bool {
+	addr, ok := peerAddr(r)
+	return ok && containsAddr(s.AdminAllowlist, addr)
+}
+
+// adminOnly only passes on requests from allowlisted addresses or from
+// users with the global admin role.
+func (s *State) adminOnly(next http.Handler) http.Handler {
+	login := s.adminLogin(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.allowlisted(r) {
+			next.ServeHTTP(w, r.WithContext(withAdminActor(r.Context(), clientIP(r))))
+			return
+		}
+		login.ServeHTTP(w, r)
+	})
+}
+
+// adminLogin only passes on requests from users with the global admin
+// role.
+func (s *State) adminLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := s.withContext(r.Context())
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized)+", admin credentials are required", http.StatusUnauthorized)
+			return
+		}
+		if !st.checkLogin(w, r, username, password, "admin") {
+			return
+		}
+		admin, err := st.db.IsAdmin(username)
+		if err != nil {
+			st.handle500Err(w, " unable to get user role")
+			return
+		}
+		ctx := logging.WithUser(r.Context(), username)
+		if !admin {
+			logging.Component(logHTTP).WarnContext(ctx, "admin route denied", "path", r.URL.Path)
+			st.auditLogin(r, username, false, "admin, not an admin")
+			http.Error(w, http.StatusText(http.StatusForbidden)+", only admins can use this route", http.StatusForbidden)
+			return
+		}
+		st.auditLogin(r, username, true, "admin")
+		next.ServeHTTP(w, r.WithContext(withAdminActor(ctx, username)))
+	})
+}
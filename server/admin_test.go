@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// adminDB makes every user an admin.
+type adminDB struct {
+	passDB
+}
+
+func (db *adminDB) IsAdmin(username string) (bool, error) {
+	return true, nil
+}
+
+func TestParseAllowlist(t *testing.T) {
+	prefixes, err := ParseAllowlist("10.0.0.0/8, 192.168.1.5,,::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.5/32", "::1/128"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("got %v want %v", prefixes, want)
+	}
+	for i, p := range prefixes {
+		if p.String() != want[i] {
+			t.Errorf("got %s want %s", p, want[i])
+		}
+	}
+	if _, err := ParseAllowlist("10.0.0.0/33"); err == nil {
+		t.Error("expected an error for an invalid range")
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	tests := []struct {
+		name       string
+		db         database.Connection
+		remoteAddr string
+		auth       bool
+		forwarded  string
+		want       int
+	}{
+		{"allowlisted", new(failDB), "127.0.0.1:5000", false, "", http.StatusOK},
+		{"allowlisted range", new(failDB), "10.1.2.3:5000", false, "", http.StatusOK},
+		{"no credentials", new(passDB), "203.0.113.9:5000", false, "", http.StatusUnauthorized},
+		{"forwarded header is ignored", new(passDB), "203.0.113.9:5000", false, "127.0.0.1", http.StatusUnauthorized},
+		{"not an admin", new(passDB), "203.0.113.9:5000", true, "", http.StatusForbidden},
+		{"admin", new(adminDB), "203.0.113.9:5000", true, "", http.StatusOK},
+		{"db error", new(failDB), "203.0.113.9:5000", true, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &State{
+				db:             tt.db,
+				AdminAllowlist: append([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, DefaultAdminAllowlist...),
+			}
+			router := s.adminRoutes(prometheus.NewRegistry())
+			req := httptest.NewRequest("GET", "/metrics", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.auth {
+				req.Header.Set("Authorization", getAuthHeader())
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("got status %d want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminAPIRequiresLogin(t *testing.T) {
+	tests := []struct {
+		name   string
+		auth   bool
+		want   int
+		unbans int
+	}{
+		{"allowlisted without credentials", false, http.StatusUnauthorized, 0},
+		{"allowlisted admin", true, http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &auditDB{Connection: new(adminDB)}
+			s := &State{db: db, AdminAllowlist: DefaultAdminAllowlist}
+			router := s.adminRoutes(prometheus.NewRegistry())
+			req := httptest.NewRequest("POST", "/admin/users/player2/unban", nil)
+			req.RemoteAddr = "127.0.0.1:5000"
+			if tt.auth {
+				req.Header.Set("Authorization", getAuthHeader())
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("got status %d want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			unbans := 0
+			for _, entry := range db.entries {
+				if entry.Action != auditUnban {
+					continue
+				}
+				unbans++
+				if entry.Actor != "captainnobody1" {
+					t.Errorf("got actor %q want the admin", entry.Actor)
+				}
+			}
+			if unbans != tt.unbans {
+				t.Errorf("got %d unbans recorded want %d", unbans, tt.unbans)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"strings"
+)
+
+// lettersPerTypo is how many letters of the answer it takes to allow a
+// typo in a guess, so short answers must be spelled exactly.
+const lettersPerTypo = 5
+
+// normalizeGuess lowercases the text and collapses runs of spaces.
+func normalizeGuess(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// matchAnswer reports whether the guess is the answer. Case and spacing
+// are ignored and longer answers allow a typo for every few letters, in
+// which case the match is fuzzy.
+func matchAnswer(guess, answer string) (correct, fuzzy bool) {
+	guess, answer = normalizeGuess(guess), normalizeGuess(answer)
+	if answer == "" {
+		return false, false
+	}
+	if guess == answer {
+		return true, false
+	}
+	typos := len([]rune(answer)) / lettersPerTypo
+	if typos > 0 && levenshtein(guess, answer) <= typos {
+		return true, true
+	}
+	return false, false
+}
+
+// levenshtein returns the number of single letter insertions, deletions
+// or substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
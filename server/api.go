@@ -2,68 +2,110 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
 )
 
-// requires auth token to access the db
+// requires auth token to access the db, users can only get their own
+// profile this way.
 func (s State) getUsername(w http.ResponseWriter, r *http.Request) {
 	username, err := getAndValidateUsername(w, r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	// TODO: return all values from db
 	userData, err := s.db.GetUserData(username)
 	if err != nil {
-		handle500Err(w, " unable to get user")
+		s.handle500Err(w, " unable to get user")
 		return
 	}
-	counter200Code.Add(1)
-	w.Write([]byte(userData))
+	s.writeProfile(w, userData, username)
 }
 
 // does not require header
 func (s State) updateUsername(w http.ResponseWriter, r *http.Request) {
-
 	username := chi.URLParam(r, "username")
 	if username == "" {
 		http.Error(w, "username needs to be provided", http.StatusBadRequest)
-		counter400Code.Add(1)
+		return
 	}
+	s.register(w, r, username, r.URL.Query().Get("password"))
+}
 
-	password := r.URL.Query().Get("password")
-	if password == "" {
-		log.Println("password parameter is empty")
-		password = genPassword()
+// /register?username=...&password=...
+// does not require header, a username is generated if one is not given.
+func (s State) registerUser(w http.ResponseWriter, r *http.Request) {
+	s.register(w, r, r.URL.Query().Get("username"), r.URL.Query().Get("password"))
+}
+
+// register creates the user and writes back their credentials. Passwords
+// that are not given are generated, as are usernames.
+func (s State) register(w http.ResponseWriter, r *http.Request, username, password string) {
+	if username != "" {
+		if err := validUsername(username); err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest)+", "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	generated := password == ""
+	if generated {
+		var err error
+		password, err = s.genPassword()
+		if err != nil {
+			s.handle500Err(w, " unable to generate password")
+			return
+		}
+	} else if err := s.passwordPolicy().Check(password); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	err := s.db.UpsertUsername(username, password)
+	var err error
+	if username == "" {
+		username, err = s.genUsername(password)
+	} else {
+		err = s.db.UpsertUsername(username, password)
+	}
+	if errors.Is(err, database.ErrUsernameTaken) {
+		http.Error(w, http.StatusText(http.StatusConflict)+", username is taken", http.StatusConflict)
+		return
+	}
 	if err != nil {
-		handle500Err(w, " unable to update user")
+		s.handle500Err(w, " unable to update user")
 		return
 	}
-	counter200Code.Add(1)
-	w.Write([]byte("user %s registered with password %s ")) //TODO: return updated username
+	s.recordAudit(r, username, auditRegister, username, "")
+	if generated {
+		w.Write([]byte(fmt.Sprintf("user %s registered with password %s", username, password)))
+		return
+	}
+	w.Write([]byte(fmt.Sprintf("user %s registered", username)))
 }
 
 func (s State) deleteUsername(w http.ResponseWriter, r *http.Request) {
 	username, err := getAndValidateUsername(w, r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
-		counter400Code.Add(1)
 		return
 	}
 	err = s.db.DeleteUsername(username)
+	if errors.Is(err, database.ErrUserNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", user already deleted", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		handle500Err(w, " unable to delete user")
+		s.handle500Err(w, " unable to delete user")
 		return
 	}
-	counter200Code.Add(1)
-	w.Write([]byte("user deleted")) // TODO: return deleted username
+	s.recordAudit(r, username, auditDelete, username, "")
+	w.Write([]byte(fmt.Sprintf("user %s deleted, it can be restored for %s at /register/%s/restore", username, undoWindow, username)))
 }
 
 // TODO: dont allow them to start a game without a userID
@@ -75,14 +117,26 @@ func (s State) startGame(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
+	settings, err := gameSettingsFromRequest(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// private games can only be joined with the invite code in the share link
+	if settings.Private {
+		settings.InviteCode, err = genToken(16)
+		if err != nil {
+			s.handle500Err(w, " unable to create invite code")
+			return
+		}
+	}
 	//  return gameID and an error
-	GameID, err := s.db.CreateGame(username)
+	GameID, err := s.db.CreateGame(username, settings)
 	if err != nil {
-		handle500Err(w, " unable to start game")
+		s.handle500Err(w, " unable to start game")
 		return
 	}
-	respTest := fmt.Sprintf("Game started with id %d.\n share this link so others can join %s\n", GameID, s.makeGamePath(GameID))
-	counter200Code.Add(1)
+	respTest := fmt.Sprintf("Game started with id %d.\n share this link so others can join %s\n", GameID, s.makeJoinPath(GameID, "code", settings.InviteCode))
 	w.WriteHeader(http.StatusCreated) // Created
 	w.Write([]byte(respTest))
 }
@@ -99,18 +153,51 @@ func (s State) joinGame(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	err = s.db.AddUserToGame(username, gameID)
+	gameData, err := s.db.GetGameData(gameID)
+	if err != nil {
+		s.handle500Err(w, " unable to get game")
+		return
+	}
+	var token string
+	if gameData.Private {
+		var ok bool
+		if token, ok = inviteToken(w, r, gameData); !ok {
+			return
+		}
+	}
+	if token != "" {
+		err = s.db.JoinWithInvite(username, gameID, token)
+	} else {
+		err = s.db.AddUserToGame(username, gameID)
+	}
+	if errors.Is(err, database.ErrInvalidInvite) {
+		http.Error(w, http.StatusText(http.StatusForbidden)+", the invite has expired or been revoked", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, database.ErrBanned) {
+		http.Error(w, http.StatusText(http.StatusForbidden)+", you have been banned from this game", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, database.ErrGameFull) {
+		http.Error(w, http.StatusText(http.StatusConflict)+", the game is full", http.StatusConflict)
+		return
+	}
 	if err != nil {
-		handle500Err(w, " unable to join game")
+		s.handle500Err(w, " unable to join game")
 		return
 	}
-	counter200Code.Add(1)
+	// the first player to join a round robin game that already started gets the turn
+	if gameData.Answer != "" && gameData.TurnPlayer == "" {
+		if err := s.startTurn(gameData, username); err != nil {
+			logging.Component(logGame).ErrorContext(s.context(), "unable to start turn", "game_id", gameID, "err", err)
+		}
+	}
 	respText := fmt.Sprintf("User %s joined game %d", username, gameID)
 	w.Write([]byte(respText))
 }
 
 func (s State) getGameState(w http.ResponseWriter, r *http.Request) {
-	_, err := usernameFromHeader(w, r)
+	username, err := usernameFromHeader(w, r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
@@ -122,20 +209,36 @@ func (s State) getGameState(w http.ResponseWriter, r *http.Request) {
 	}
 	gameData, err := s.db.GetGameData(gameID)
 	if err != nil {
-		handle500Err(w, " unable to get game")
+		s.handle500Err(w, " unable to get game")
+		return
+	}
+	// only the host knows the answer until the game is over
+	if gameData.Host != username && !gameData.Ended {
+		gameData.Answer = ""
+	}
+	if gameData.Host != username {
+		gameData.InviteCode = ""
+	}
+	gameData.Removals, err = s.db.GetGameRemovals(gameID)
+	if err != nil {
+		s.handle500Err(w, " unable to get removed players")
+		return
+	}
+	gameData.Standings, err = s.gameStandings(gameData)
+	if err != nil {
+		s.handle500Err(w, " unable to get game standings")
 		return
 	}
 	gameJson, err := json.Marshal(gameData)
 	if err != nil {
-		handle500Err(w, " unable to marshal game data")
+		s.handle500Err(w, " unable to marshal game data")
 		return
 	}
-	counter200Code.Add(1)
 	w.Write([]byte(gameJson))
 }
 
 func (s State) stopGame(w http.ResponseWriter, r *http.Request) {
-	_, err := usernameFromHeader(w, r)
+	username, err := usernameFromHeader(w, r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
@@ -145,17 +248,23 @@ func (s State) stopGame(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	err = s.db.StopGame(gameID)
+	err = s.endGame(gameID, database.OutcomeStopped, "")
+	if errors.Is(err, database.ErrGameEnded) {
+		http.Error(w, http.StatusText(http.StatusConflict)+", the game has already ended", http.StatusConflict)
+		return
+	}
 	if err != nil {
-		handle500Err(w, "unable to delete game")
+		s.handle500Err(w, "unable to delete game")
 		return
 	}
-	counter200Code.Add(1)
+	s.recordAudit(r, username, auditStopGame, strconv.FormatInt(gameID, 10), "")
 	w.Write([]byte("game deleted"))
 }
 
-// /game/{gameID}/play?answer=...
-func (s State) playGame(w http.ResponseWriter, r *http.Request) {
+// /game/{gameID}/summary
+// the host can view the summary at any time, the rest of the
+// players can only view it once the game has ended.
+func (s State) getSummary(w http.ResponseWriter, r *http.Request) {
 	username, err := usernameFromHeader(w, r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
@@ -166,8 +275,336 @@ func (s State) playGame(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	answer := r.URL.Query().Get("answer")
-	// send answer to db
-	// TODO: add more db functions
-	fmt.Println(username, answer, gameID)
+	gameData, err := s.db.GetGameData(gameID)
+	if err != nil {
+		s.handle500Err(w, " unable to get game")
+		return
+	}
+	if !canViewSummary(gameData, username) {
+		http.Error(w, http.StatusText(http.StatusForbidden)+", only the host can view the summary before the game ends", http.StatusForbidden)
+		return
+	}
+	questions, err := s.db.GetGameQuestions(gameID)
+	if err != nil {
+		s.handle500Err(w, " unable to get game questions")
+		return
+	}
+	guesses, err := s.db.GetGameGuesses(gameID)
+	if err != nil {
+		s.handle500Err(w, " unable to get game guesses")
+		return
+	}
+	summary := buildSummary(gameData, questions, guesses)
+	summary.Standings, err = s.gameStandings(gameData)
+	if err != nil {
+		s.handle500Err(w, " unable to get game standings")
+		return
+	}
+	summaryJson, err := json.Marshal(summary)
+	if err != nil {
+		s.handle500Err(w, " unable to marshal game summary")
+		return
+	}
+	w.Write(summaryJson)
+}
+
+// /game/{gameID}/leave
+// when the host leaves, hosting passes to the next player if no questions
+// have been asked yet. Otherwise the game ends.
+func (s State) leaveGame(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	game := access.Game
+	if game.Ended {
+		http.Error(w, http.StatusText(http.StatusConflict)+", the game has ended", http.StatusConflict)
+		return
+	}
+	if access.Role != RoleHost {
+		err := s.db.RemoveUserFromGame(access.Username, game.GameID)
+		if err != nil {
+			s.handle500Err(w, " unable to leave game")
+			return
+		}
+		s.passTurnOnRemoval(game, access.Username)
+		w.Write([]byte(fmt.Sprintf("User %s left game %d", access.Username, game.GameID)))
+		return
+	}
+
+	newHost := nextHost(game)
+	if newHost == "" {
+		// the host stays in the game's history as its host, so leaving is
+		// only announced to the players
+		s.publish(Event{Type: EventLeft, GameID: game.GameID, Username: access.Username})
+		err := s.endGame(game.GameID, database.OutcomeHostLeft, "")
+		if err != nil {
+			s.endGameErr(w, err)
+			return
+		}
+		w.Write([]byte(fmt.Sprintf("host %s left, game %d has ended", access.Username, game.GameID)))
+		return
+	}
+	err := s.db.TransferHost(game.GameID, newHost)
+	if err != nil {
+		s.handle500Err(w, " unable to transfer host")
+		return
+	}
+	// the host never takes a turn
+	s.passTurnOnRemoval(game, newHost)
+	w.Write([]byte(fmt.Sprintf("host %s left, %s is now the host of game %d", access.Username, newHost, game.GameID)))
+}
+
+// /game/{gameID}/kick?username=...&reason=...
+func (s State) kickPlayer(w http.ResponseWriter, r *http.Request) {
+	s.removePlayer(w, r, false)
+}
+
+// /game/{gameID}/ban?username=...&reason=...
+// banned players cannot rejoin the game.
+func (s State) banPlayer(w http.ResponseWriter, r *http.Request) {
+	s.removePlayer(w, r, true)
+}
+
+func (s State) removePlayer(w http.ResponseWriter, r *http.Request, ban bool) {
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	game := access.Game
+	target := strings.TrimSpace(r.URL.Query().Get("username"))
+	if target == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", username parameter cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if target == game.Host {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", the host cannot be removed from the game", http.StatusBadRequest)
+		return
+	}
+	// players can be banned before they join, but only players can be kicked
+	if !ban && gameRole(game, target) != RolePlayer {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+target+" is not a player in this game", http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+	err := s.db.RemoveFromGame(game.GameID, target, access.Username, reason, ban)
+	if err != nil {
+		s.handle500Err(w, " unable to remove player")
+		return
+	}
+	verb, action := "kicked", auditKick
+	if ban {
+		verb, action = "banned", auditBanPlayer
+	}
+	detail := fmt.Sprintf("game %d", game.GameID)
+	if reason != "" {
+		detail += ": " + reason
+	}
+	s.recordAudit(r, access.Username, action, target, detail)
+	s.passTurnOnRemoval(game, target)
+	w.Write([]byte(fmt.Sprintf("User %s %s from game %d", target, verb, game.GameID)))
+}
+
+// nextHost picks the player that takes over when the host leaves. Once
+// questions have been asked every player has been working towards the
+// answer, so nobody can take it over and an empty string is returned.
+func nextHost(game database.Game) string {
+	if game.QuestionCount > 0 {
+		return ""
+	}
+	for _, player := range game.Players {
+		if player != game.Host {
+			return player
+		}
+	}
+	return ""
+}
+
+// /game/{gameID}/play?answer=...
+// the host sets the secret answer before any questions are asked.
+func (s State) playGame(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	answer := strings.TrimSpace(r.URL.Query().Get("answer"))
+	if answer == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", answer parameter cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if access.Game.Ended || access.Game.QuestionCount > 0 {
+		http.Error(w, http.StatusText(http.StatusConflict)+", the answer cannot be changed once questions have been asked", http.StatusConflict)
+		return
+	}
+	err := s.db.SetAnswer(access.Game.GameID, answer)
+	if err != nil {
+		s.handle500Err(w, " unable to set answer")
+		return
+	}
+	// setting the answer for the first time starts the game
+	if access.Game.Answer == "" {
+		if err := s.startTurn(access.Game, nextTurn(access.Game, "")); err != nil {
+			logging.Component(logGame).ErrorContext(s.context(), "unable to start turn", "game_id", access.Game.GameID, "err", err)
+		}
+	}
+	w.Write([]byte(fmt.Sprintf("answer set for game %d", access.Game.GameID)))
+}
+
+// /game/{gameID}/ask?question=...
+func (s State) askQuestion(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAccess(w, r)
+	if !ok || !requireInProgress(w, access.Game) {
+		return
+	}
+	question := strings.TrimSpace(r.URL.Query().Get("question"))
+	if question == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", question parameter cannot be empty", http.StatusBadRequest)
+		return
+	}
+	game := access.Game
+	if game.QuestionsLeft() == 0 {
+		http.Error(w, http.StatusText(http.StatusConflict)+", there are no questions left, make a guess", http.StatusConflict)
+		return
+	}
+	if game.Rules.RoundRobin {
+		if game.TurnPlayer != access.Username {
+			http.Error(w, http.StatusText(http.StatusConflict)+", it is not your turn to ask a question", http.StatusConflict)
+			return
+		}
+		if game.PendingQuestion {
+			http.Error(w, http.StatusText(http.StatusConflict)+", the host has not answered your last question", http.StatusConflict)
+			return
+		}
+	}
+	questionID, err := s.db.AddQuestion(game.GameID, access.Username, question, game.Rules.AnswerSeconds)
+	if err != nil {
+		s.handle500Err(w, " unable to ask question")
+		return
+	}
+	// the turn stays with the player, without a time limit, until the host answers
+	if game.Rules.RoundRobin {
+		if err := s.db.SetTurn(game.GameID, access.Username, 0); err != nil {
+			s.handle500Err(w, " unable to update turn")
+			return
+		}
+	}
+	w.Write([]byte(fmt.Sprintf("question %d asked", questionID)))
+}
+
+// /game/{gameID}/answer?questionID=...&answer=...
+func (s State) answerQuestion(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAccess(w, r)
+	if !ok || !requireInProgress(w, access.Game) {
+		return
+	}
+	questionID, err := strconv.ParseInt(r.URL.Query().Get("questionID"), 10, 64)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", questionID parameter must be an integer", http.StatusBadRequest)
+		return
+	}
+	answer, ok := access.Game.Rules.NormalizeAnswer(r.URL.Query().Get("answer"))
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", answer parameter must be one of "+strings.Join(access.Game.Rules.AllowedAnswers(), ", "), http.StatusBadRequest)
+		return
+	}
+	err = s.db.AnswerQuestion(access.Game.GameID, questionID, answer)
+	if err != nil {
+		s.handle500Err(w, " unable to answer question")
+		return
+	}
+	if access.Game.Rules.RoundRobin {
+		if err := s.advanceTurn(access.Game.GameID); err != nil {
+			s.handle500Err(w, " unable to pass the turn")
+			return
+		}
+	}
+	// the players still get to guess once the last question is answered
+	if access.Game.QuestionsLeft() == 0 {
+		w.Write([]byte(fmt.Sprintf("question %d answered, there are no questions left but the players can still guess", questionID)))
+		return
+	}
+	w.Write([]byte(fmt.Sprintf("question %d answered", questionID)))
+}
+
+// /game/{gameID}/guess?guess=...
+// a correct guess ends the game.
+func (s State) makeGuess(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAccess(w, r)
+	if !ok || !requireInProgress(w, access.Game) {
+		return
+	}
+	guess := strings.TrimSpace(r.URL.Query().Get("guess"))
+	if guess == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", guess parameter cannot be empty", http.StatusBadRequest)
+		return
+	}
+	game := access.Game
+	var guesses []database.Guess
+	if game.Rules.GuessesPerPlayer > 0 {
+		var err error
+		guesses, err = s.db.GetGameGuesses(game.GameID)
+		if err != nil {
+			s.handle500Err(w, " unable to get guesses")
+			return
+		}
+		if guessesBy(guesses, access.Username) >= game.Rules.GuessesPerPlayer {
+			http.Error(w, http.StatusText(http.StatusConflict)+", you have no guesses left", http.StatusConflict)
+			return
+		}
+	}
+	correct, fuzzy := matchAnswer(guess, game.Answer)
+	err := s.db.AddGuess(game.GameID, access.Username, guess, correct)
+	if err != nil {
+		s.handle500Err(w, " unable to make guess")
+		return
+	}
+	observeGuess(correct, fuzzy)
+	if !correct {
+		game.WrongGuesses++
+		guesses = append(guesses, database.Guess{UserID: access.Username, GuessText: guess})
+		if stumped(game, guesses) {
+			if err := s.endGame(game.GameID, database.OutcomeStumped, game.Host); err != nil {
+				s.endGameErr(w, err)
+				return
+			}
+			w.Write([]byte(fmt.Sprintf("%s is not the answer, nobody can guess any more so %s wins", guess, game.Host)))
+			return
+		}
+		w.Write([]byte(fmt.Sprintf("%s is not the answer", guess)))
+		return
+	}
+	err = s.endGame(game.GameID, database.OutcomeSolved, access.Username)
+	if err != nil {
+		s.endGameErr(w, err)
+		return
+	}
+	if fuzzy {
+		w.Write([]byte(fmt.Sprintf("%s is close enough to %s! %s won the game", guess, game.Answer, access.Username)))
+		return
+	}
+	w.Write([]byte(fmt.Sprintf("%s is correct! %s won the game", guess, access.Username)))
+}
+
+// endGameErr writes the response for a game that could not be ended,
+// another request may have ended it first.
+func (s State) endGameErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, database.ErrGameEnded) {
+		http.Error(w, http.StatusText(http.StatusConflict)+", the game has ended", http.StatusConflict)
+		return
+	}
+	s.handle500Err(w, " unable to end game")
+}
+
+// requireInProgress checks that the host has set an answer and the game
+// has not ended.
+func requireInProgress(w http.ResponseWriter, game database.Game) bool {
+	if game.Ended {
+		http.Error(w, http.StatusText(http.StatusConflict)+", the game has ended", http.StatusConflict)
+		return false
+	}
+	if game.Answer == "" {
+		http.Error(w, http.StatusText(http.StatusConflict)+", the host has not set an answer yet", http.StatusConflict)
+		return false
+	}
+	return true
 }
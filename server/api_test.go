@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/soypete/golang-cli-game/database"
@@ -19,8 +20,55 @@ func getAuthHeader() string {
 	return fmt.Sprintf("Basic %s", ed)
 }
 
-func (db *passDB) GetUserData(username string) (string, error) {
-	return "captainnobody1", nil
+func (db *passDB) GetUserData(username string) (database.User, error) {
+	return database.User{
+		Username:    username,
+		DisplayName: "Captain Nobody",
+		Avatar:      "🦫",
+		Language:    "en",
+		Privacy:     database.Privacy{Public: true, ShowStats: true, ShowLastSeen: true},
+	}, nil
+}
+func (db *passDB) UpdateProfile(username string, update database.ProfileUpdate) (database.User, error) {
+	user, _ := db.GetUserData(username)
+	if update.DisplayName != nil {
+		user.DisplayName = *update.DisplayName
+	}
+	if update.Public != nil {
+		user.Public = *update.Public
+	}
+	return user, nil
+}
+func (db *passDB) TouchLastSeen(username string) error {
+	return nil
+}
+func (db *passDB) RestoreUser(username, password string, window time.Duration) error {
+	return nil
+}
+func (db *passDB) RenameUser(oldUsername, newUsername string) error {
+	if newUsername == "player2" {
+		return database.ErrUsernameTaken
+	}
+	return nil
+}
+func (db *passDB) GetUsernameHistory(username string) ([]database.UsernameChange, error) {
+	return []database.UsernameChange{{OldUsername: "captain", NewUsername: username}}, nil
+}
+func (db *passDB) AnonymizeDeletedUsers(window time.Duration) (int, error) {
+	return 0, nil
+}
+func (db *passDB) GetUserGames(username string) ([]database.Game, error) {
+	return []database.Game{
+		{GameID: 1, Host: username, Answer: "gopher", InviteCode: "mine"},
+		{GameID: 2, Host: "player2", Players: []string{"player2", username}, Answer: "secret", InviteCode: "theirs"},
+		{GameID: 3, Host: "player2", Players: []string{"player2", username}, Answer: "cat", Ended: true},
+	}, nil
+}
+func (db *passDB) GetUserQuestions(username string) ([]database.Question, error) {
+	return []database.Question{{QuestionText: "is it an animal", UserID: username, GameID: "2"}}, nil
+}
+func (db *passDB) GetUserGuesses(username string) ([]database.Guess, error) {
+	return []database.Guess{{GuessText: "cat", UserID: username, GameID: "3", Correct: true}}, nil
 }
 
 func (db *passDB) UpsertUsername(username, password string) error {
@@ -29,7 +77,7 @@ func (db *passDB) UpsertUsername(username, password string) error {
 func (db *passDB) DeleteUsername(username string) error {
 	return nil
 }
-func (db *passDB) CreateGame(username string) (int64, error) {
+func (db *passDB) CreateGame(username string, settings database.GameSettings) (int64, error) {
 	return 1234, nil
 }
 func (db *passDB) AddUserToGame(username string, gameID int64) error {
@@ -37,20 +85,201 @@ func (db *passDB) AddUserToGame(username string, gameID int64) error {
 }
 func (db *passDB) GetGameData(gameID int64) (database.Game, error) {
 	return database.Game{
-		GameID: 321,
+		GameID:  321,
+		Host:    "captainnobody1",
+		Players: []string{"captainnobody1", "player2"},
 	}, nil
 }
 func (db *passDB) StopGame(gameID int64) error {
 	return nil
 }
+func (db *passDB) EndGame(gameID int64, outcome, winner string) error {
+	return nil
+}
+func (db *passDB) RemoveUserFromGame(username string, gameID int64) error {
+	return nil
+}
+func (db *passDB) TransferHost(gameID int64, newHost string) error {
+	return nil
+}
+func (db *passDB) RemoveFromGame(gameID int64, username, removedBy, reason string, ban bool) error {
+	return nil
+}
+func (db *passDB) GetGameRemovals(gameID int64) ([]database.Removal, error) {
+	return nil, nil
+}
+func (db *passDB) SaveStandings(game database.Game, standings []database.Standing) (bool, error) {
+	return true, nil
+}
+func (db *passDB) GetGameStandings(gameID int64) ([]database.Standing, error) {
+	return nil, nil
+}
+func (db *passDB) GetUserStats(username string) (database.UserStats, error) {
+	return database.UserStats{Username: username, GamesPlayed: 4, GamesSolved: 2, QuestionsToSolve: 15, Guesses: 4, CorrectGuesses: 3}, nil
+}
+func (db *passDB) GetLeaderboard(filter database.LeaderboardFilter) ([]database.LeaderboardEntry, error) {
+	return []database.LeaderboardEntry{
+		{Rank: 1, Username: "captainnobody1", Points: 300, Wins: 3, Games: 4},
+		{Rank: 2, Username: "player2", Points: 120, Wins: 1, Games: 4},
+	}, nil
+}
+func (db *passDB) GetRatings(usernames []string) ([]database.UserRating, error) {
+	ratedAt := time.Now().Add(-time.Hour)
+	return []database.UserRating{{Username: "captainnobody1", Rating: 1720, Deviation: 80, RatedAt: &ratedAt}}, nil
+}
+func (db *passDB) SaveRatings(gameID int64, ratings []database.UserRating) error {
+	return nil
+}
+func (db *passDB) GetRatingHistory(username string) ([]database.RatingChange, error) {
+	return []database.RatingChange{{GameID: 1234, Username: username, PreviousRating: 1500, Rating: 1720, Deviation: 80}}, nil
+}
+func (db *passDB) UnlockAchievements(username string, gameID int64, ids []string) ([]string, error) {
+	return ids, nil
+}
+func (db *passDB) GetUserAchievements(username string) ([]database.Achievement, error) {
+	return []database.Achievement{
+		{Username: username, AchievementID: "first_win", GameID: 1234},
+		{Username: username, AchievementID: "retired", GameID: 1234},
+	}, nil
+}
+func (db *passDB) CreateInvite(invite database.Invite) error {
+	return nil
+}
+func (db *passDB) GetGameInvites(gameID int64) ([]database.Invite, error) {
+	return []database.Invite{{Token: "abc", GameID: gameID, CreatedBy: "captainnobody1"}}, nil
+}
+func (db *passDB) JoinWithInvite(username string, gameID int64, token string) error {
+	return nil
+}
+func (db *passDB) RevokeInvite(gameID int64, token string) error {
+	return nil
+}
+func (db *passDB) ListLobbyGames(filter database.LobbyFilter) ([]database.LobbyGame, error) {
+	games := []database.LobbyGame{
+		{GameID: 3, Host: "captainnobody1", Players: 1, MaxPlayers: 5, Category: "animals", StartTime: time.Now()},
+		{GameID: 2, Host: "player2", Players: 4, MaxPlayers: 5, Answered: true, StartTime: time.Now()},
+		{GameID: 1, Host: "player3", Players: 2, MaxPlayers: 5, StartTime: time.Now()},
+	}
+	if filter.Limit < len(games) {
+		games = games[:filter.Limit]
+	}
+	return games, nil
+}
+func (db *passDB) SetTurn(gameID int64, username string, seconds int) error {
+	return nil
+}
+func (db *passDB) SkipTurn(gameID int64, username, next string, seconds int) error {
+	return nil
+}
+func (db *passDB) ListExpiredTurns() ([]int64, error) {
+	return nil, nil
+}
+func (db *passDB) ExpireQuestions() ([]database.Question, error) {
+	return nil, nil
+}
+func (db *passDB) CountActiveGames() (int, error) {
+	return 1, nil
+}
+func (db *passDB) ListExpiredGames() ([]int64, error) {
+	return nil, nil
+}
+func (db *passDB) GetGameQuestions(gameID int64) ([]database.Question, error) {
+	return []database.Question{{QuestionID: "1", QuestionText: "is it an animal?", Answer: "yes", UserID: "player2"}}, nil
+}
+func (db *passDB) GetGameGuesses(gameID int64) ([]database.Guess, error) {
+	return []database.Guess{{GuessID: "1", GuessText: "a gopher", UserID: "player2", Correct: true}}, nil
+}
 func (db *passDB) CheckUserValid(string, string) (bool, error) {
 	return true, nil
 }
+func (db *passDB) IsAdmin(username string) (bool, error) {
+	return false, nil
+}
+func (db *passDB) SetAnswer(gameID int64, answer string) error {
+	return nil
+}
+func (db *passDB) AddQuestion(gameID int64, username, question string, answerSeconds int) (int64, error) {
+	return 1, nil
+}
+func (db *passDB) AnswerQuestion(gameID, questionID int64, answer string) error {
+	return nil
+}
+func (db *passDB) AddGuess(gameID int64, username, guess string, correct bool) error {
+	return nil
+}
+func (db *passDB) ListUsers(filter database.UserFilter) ([]database.User, error) {
+	return []database.User{{Username: "captainnobody1"}, {Username: "player2"}}, nil
+}
+func (db *passDB) GetUserIncludingDeleted(username string) (database.User, error) {
+	return db.GetUserData(username)
+}
+func (db *passDB) ListGames(filter database.GameFilter) ([]database.Game, error) {
+	return []database.Game{{GameID: 1, Host: "captainnobody1", Players: []string{"captainnobody1", "player2"}}}, nil
+}
+func (db *passDB) ResetPassword(username, password string) error {
+	return nil
+}
+func (db *passDB) BanUser(username, reason string) error {
+	return nil
+}
+func (db *passDB) UnbanUser(username string) error {
+	return nil
+}
+func (db *passDB) RecordAudit(entry database.AuditEntry) error {
+	return nil
+}
+func (db *passDB) ListAudit(filter database.AuditFilter) ([]database.AuditEntry, error) {
+	return []database.AuditEntry{{ID: 1, Actor: "captainnobody1", Action: "game.stop", Target: "321"}}, nil
+}
+func (db *passDB) PruneAudit(retention time.Duration) (int, error) {
+	return 0, nil
+}
+func (db *passDB) GetGameEvents(gameID, afterSeq int64) ([]database.GameEvent, error) {
+	history := []database.GameEvent{
+		{GameID: gameID, Seq: 1, Type: database.GameEventCreated, Username: "captainnobody1"},
+		{GameID: gameID, Seq: 2, Type: database.GameEventJoined, Username: "player2"},
+		{GameID: gameID, Seq: 3, Type: database.GameEventAnswerSet, Username: "captainnobody1", Data: database.GameEventData{Answer: "a cat"}},
+	}
+	var after []database.GameEvent
+	for _, e := range history {
+		if e.Seq > afterSeq {
+			after = append(after, e)
+		}
+	}
+	return after, nil
+}
 
 type failDB struct{}
 
-func (db *failDB) GetUserData(username string) (string, error) {
-	return "", fmt.Errorf("failed to get username %s from db", username)
+func (db *failDB) GetUserData(username string) (database.User, error) {
+	return database.User{}, fmt.Errorf("failed to get username %s from db", username)
+}
+func (db *failDB) UpdateProfile(username string, update database.ProfileUpdate) (database.User, error) {
+	return database.User{}, fmt.Errorf("failed to update profile of %s in db", username)
+}
+func (db *failDB) TouchLastSeen(username string) error {
+	return fmt.Errorf("failed to update last seen of %s in db", username)
+}
+func (db *failDB) RestoreUser(username, password string, window time.Duration) error {
+	return fmt.Errorf("failed to restore %s in db", username)
+}
+func (db *failDB) RenameUser(oldUsername, newUsername string) error {
+	return fmt.Errorf("failed to rename %s in db", oldUsername)
+}
+func (db *failDB) GetUsernameHistory(username string) ([]database.UsernameChange, error) {
+	return nil, fmt.Errorf("failed to get username history of %s from db", username)
+}
+func (db *failDB) AnonymizeDeletedUsers(window time.Duration) (int, error) {
+	return 0, fmt.Errorf("failed to anonymize deleted users in db")
+}
+func (db *failDB) GetUserGames(username string) ([]database.Game, error) {
+	return nil, fmt.Errorf("failed to get games of %s from db", username)
+}
+func (db *failDB) GetUserQuestions(username string) ([]database.Question, error) {
+	return nil, fmt.Errorf("failed to get questions of %s from db", username)
+}
+func (db *failDB) GetUserGuesses(username string) ([]database.Guess, error) {
+	return nil, fmt.Errorf("failed to get guesses of %s from db", username)
 }
 func (db *failDB) UpsertUsername(username, password string) error {
 	return fmt.Errorf("failed to update username %s from db", username)
@@ -58,7 +287,7 @@ func (db *failDB) UpsertUsername(username, password string) error {
 func (db *failDB) DeleteUsername(username string) error {
 	return fmt.Errorf("failed to delete username %s from db", username)
 }
-func (db *failDB) CreateGame(username string) (int64, error) {
+func (db *failDB) CreateGame(username string, settings database.GameSettings) (int64, error) {
 	return 0, fmt.Errorf("failed to start game for username %s from db", username)
 }
 func (db *failDB) AddUserToGame(username string, gameID int64) error {
@@ -70,9 +299,135 @@ func (db *failDB) GetGameData(gameID int64) (database.Game, error) {
 func (db *failDB) StopGame(gameID int64) error {
 	return fmt.Errorf("failed to stop game %d from db", gameID)
 }
+func (db *failDB) EndGame(gameID int64, outcome, winner string) error {
+	return fmt.Errorf("failed to end game %d from db", gameID)
+}
+func (db *failDB) RemoveUserFromGame(username string, gameID int64) error {
+	return fmt.Errorf("failed to remove user %s from game %d from db", username, gameID)
+}
+func (db *failDB) TransferHost(gameID int64, newHost string) error {
+	return fmt.Errorf("failed to transfer host of game %d from db", gameID)
+}
+func (db *failDB) RemoveFromGame(gameID int64, username, removedBy, reason string, ban bool) error {
+	return fmt.Errorf("failed to remove user %s from game %d from db", username, gameID)
+}
+func (db *failDB) GetGameRemovals(gameID int64) ([]database.Removal, error) {
+	return nil, fmt.Errorf("failed to get removals for game %d from db", gameID)
+}
+func (db *failDB) SaveStandings(game database.Game, standings []database.Standing) (bool, error) {
+	return false, fmt.Errorf("failed to save standings for game %d to db", game.GameID)
+}
+func (db *failDB) GetGameStandings(gameID int64) ([]database.Standing, error) {
+	return nil, fmt.Errorf("failed to get standings for game %d from db", gameID)
+}
+func (db *failDB) GetUserStats(username string) (database.UserStats, error) {
+	return database.UserStats{}, fmt.Errorf("failed to get stats for %s from db", username)
+}
+func (db *failDB) GetLeaderboard(filter database.LeaderboardFilter) ([]database.LeaderboardEntry, error) {
+	return nil, fmt.Errorf("failed to get leaderboard from db")
+}
+func (db *failDB) GetRatings(usernames []string) ([]database.UserRating, error) {
+	return nil, fmt.Errorf("failed to get ratings from db")
+}
+func (db *failDB) SaveRatings(gameID int64, ratings []database.UserRating) error {
+	return fmt.Errorf("failed to save ratings for game %d to db", gameID)
+}
+func (db *failDB) GetRatingHistory(username string) ([]database.RatingChange, error) {
+	return nil, fmt.Errorf("failed to get rating history for %s from db", username)
+}
+func (db *failDB) UnlockAchievements(username string, gameID int64, ids []string) ([]string, error) {
+	return nil, fmt.Errorf("failed to unlock achievements for %s in db", username)
+}
+func (db *failDB) GetUserAchievements(username string) ([]database.Achievement, error) {
+	return nil, fmt.Errorf("failed to get achievements for %s from db", username)
+}
+func (db *failDB) CreateInvite(invite database.Invite) error {
+	return fmt.Errorf("failed to create invite for game %d from db", invite.GameID)
+}
+func (db *failDB) GetGameInvites(gameID int64) ([]database.Invite, error) {
+	return nil, fmt.Errorf("failed to get invites for game %d from db", gameID)
+}
+func (db *failDB) JoinWithInvite(username string, gameID int64, token string) error {
+	return fmt.Errorf("failed to add user %s to game %d with invite from db", username, gameID)
+}
+func (db *failDB) RevokeInvite(gameID int64, token string) error {
+	return fmt.Errorf("failed to revoke invite for game %d from db", gameID)
+}
+func (db *failDB) ListLobbyGames(filter database.LobbyFilter) ([]database.LobbyGame, error) {
+	return nil, fmt.Errorf("failed to list lobby games from db")
+}
+func (db *failDB) SetTurn(gameID int64, username string, seconds int) error {
+	return fmt.Errorf("failed to set turn for game %d from db", gameID)
+}
+func (db *failDB) SkipTurn(gameID int64, username, next string, seconds int) error {
+	return fmt.Errorf("failed to skip turn for game %d from db", gameID)
+}
+func (db *failDB) ListExpiredTurns() ([]int64, error) {
+	return nil, fmt.Errorf("failed to list expired turns from db")
+}
+func (db *failDB) ExpireQuestions() ([]database.Question, error) {
+	return nil, fmt.Errorf("failed to expire questions from db")
+}
+func (db *failDB) CountActiveGames() (int, error) {
+	return 0, fmt.Errorf("failed to count active games in db")
+}
+func (db *failDB) ListExpiredGames() ([]int64, error) {
+	return nil, fmt.Errorf("failed to list expired games from db")
+}
+func (db *failDB) GetGameQuestions(gameID int64) ([]database.Question, error) {
+	return nil, fmt.Errorf("failed to get questions for game %d from db", gameID)
+}
+func (db *failDB) GetGameGuesses(gameID int64) ([]database.Guess, error) {
+	return nil, fmt.Errorf("failed to get guesses for game %d from db", gameID)
+}
 func (db *failDB) CheckUserValid(string, string) (bool, error) {
 	return false, nil
 }
+func (db *failDB) IsAdmin(username string) (bool, error) {
+	return false, fmt.Errorf("failed to get role of user %s from db", username)
+}
+func (db *failDB) SetAnswer(gameID int64, answer string) error {
+	return fmt.Errorf("failed to set answer for game %d from db", gameID)
+}
+func (db *failDB) AddQuestion(gameID int64, username, question string, answerSeconds int) (int64, error) {
+	return 0, fmt.Errorf("failed to add question to game %d from db", gameID)
+}
+func (db *failDB) AnswerQuestion(gameID, questionID int64, answer string) error {
+	return fmt.Errorf("failed to answer question %d from db", questionID)
+}
+func (db *failDB) AddGuess(gameID int64, username, guess string, correct bool) error {
+	return fmt.Errorf("failed to add guess to game %d from db", gameID)
+}
+func (db *failDB) ListUsers(filter database.UserFilter) ([]database.User, error) {
+	return nil, fmt.Errorf("failed to list users from db")
+}
+func (db *failDB) GetUserIncludingDeleted(username string) (database.User, error) {
+	return database.User{}, fmt.Errorf("failed to get user %s from db", username)
+}
+func (db *failDB) ListGames(filter database.GameFilter) ([]database.Game, error) {
+	return nil, fmt.Errorf("failed to list games from db")
+}
+func (db *failDB) ResetPassword(username, password string) error {
+	return fmt.Errorf("failed to reset password of %s in db", username)
+}
+func (db *failDB) BanUser(username, reason string) error {
+	return fmt.Errorf("failed to ban %s in db", username)
+}
+func (db *failDB) UnbanUser(username string) error {
+	return fmt.Errorf("failed to unban %s in db", username)
+}
+func (db *failDB) RecordAudit(entry database.AuditEntry) error {
+	return fmt.Errorf("failed to record audit entry in db")
+}
+func (db *failDB) ListAudit(filter database.AuditFilter) ([]database.AuditEntry, error) {
+	return nil, fmt.Errorf("failed to list audit log from db")
+}
+func (db *failDB) PruneAudit(retention time.Duration) (int, error) {
+	return 0, fmt.Errorf("failed to prune audit log in db")
+}
+func (db *failDB) GetGameEvents(gameID, afterSeq int64) ([]database.GameEvent, error) {
+	return nil, fmt.Errorf("failed to get events of game %d from db", gameID)
+}
 
 func setupTestRouter(s State, t *testing.T) *chi.Mux {
 	r := chi.NewRouter()
@@ -92,11 +447,10 @@ func setupTestRouter(s State, t *testing.T) *chi.Mux {
 		// // subroutes for game
 		r.Route("/{gameID}", func(r chi.Router) {
 			r.Get("/join", s.joinGame) // GET /game/123/join
-			// 	r.Get("/leave", s.leaveGame) // GET /game/123/leave
 			// 	// starting = no answer submitted, in progess = asking questions, finished = guest guessed or game stopped
 			r.Get("/status", s.getGameState) // GET /game/123/status
-			// 	// only the host can get thummary
-			// 	r.Get("/summary", s.getSummary) // GET /game/123/summary
+			// only the host can get the summary until the game ends
+			r.Get("/summary", s.getSummary) // GET /game/123/summary
 			// 	// only the host can stop the game
 			r.Get("/stop", s.stopGame) // GET /game/123/stop
 		})
@@ -124,6 +478,9 @@ func TestUserEndpoints(t *testing.T) {
 	t.Run("join game: No gameID", testFailJoinNoGameID)
 	t.Run("join game: No Header", testFailJoinGameNoHeader)
 	t.Run("join game:Fail", testFailJoinGameDB)
+	t.Run("game summary: Pass host", testPassGetSummary)
+	t.Run("game summary: Fail not host", testFailGetSummaryNotHost)
+	t.Run("game summary: Fail", testFailGetSummaryDB)
 }
 func testPassGetUserName(t *testing.T) {
 	sPass := State{
@@ -419,3 +776,69 @@ func testFailJoinGameDB(t *testing.T) {
 			status, http.StatusInternalServerError)
 	}
 }
+
+func testPassGetSummary(t *testing.T) {
+	sPass := State{
+		db: new(passDB),
+	}
+	sPass.Router = setupTestRouter(sPass, t)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/game/321/summary", nil)
+	req.Header.Set("Authorization", getAuthHeader())
+	sPass.Router.ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+}
+
+func testFailGetSummaryNotHost(t *testing.T) {
+	sPass := State{
+		db: new(passDB),
+	}
+	sPass.Router = setupTestRouter(sPass, t)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/game/321/summary", nil)
+	req.SetBasicAuth("player2", "password")
+	sPass.Router.ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusForbidden)
+	}
+}
+
+func testFailGetSummaryDB(t *testing.T) {
+	sFail := State{
+		db: new(failDB),
+	}
+	sFail.Router = setupTestRouter(sFail, t)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/game/321/summary", nil)
+	req.Header.Set("Authorization", getAuthHeader())
+	sFail.Router.ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusInternalServerError)
+	}
+}
+
+func TestNextHost(t *testing.T) {
+	game := database.Game{
+		Host:    "host",
+		Players: []string{"host", "p1", "p2"},
+	}
+	if got := nextHost(game); got != "p1" {
+		t.Errorf("wrong next host before questions: got %q want %q", got, "p1")
+	}
+	game.QuestionCount = 1
+	if got := nextHost(game); got != "" {
+		t.Errorf("wrong next host after questions: got %q want none", got)
+	}
+	alone := database.Game{Host: "host", Players: []string{"host"}}
+	if got := nextHost(alone); got != "" {
+		t.Errorf("wrong next host with no players: got %q want none", got)
+	}
+}
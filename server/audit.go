@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// actions recorded in the audit log, admin actions are listed with the
+// admin API.
+const (
+	auditRegister     = "user.register"
+	auditLoginSuccess = "login.success"
+	auditLoginFailure = "login.failure"
+	auditDelete       = "user.delete"
+	auditRestore      = "user.restore"
+	auditRename       = "user.rename"
+	auditStopGame     = "game.stop"
+	auditKick         = "game.kick"
+	auditBanPlayer    = "game.ban"
+)
+
+// DefaultAuditRetention is how long audit log entries are kept.
+const DefaultAuditRetention = 365 * 24 * time.Hour
+
+// loginSessionGap is how long a user has to stop making requests before
+// their next request is recorded as a new login. Every request is
+// authenticated so recording all of them would bury everything else.
+const loginSessionGap = 30 * time.Minute
+
+// recordAudit adds the action to the audit log along with the request ID,
+// the address that connected and the client address a trusted proxy
+// forwarded it for. The action has already happened so a failure
+// to record it is logged rather than returned to the user.
+func (s State) recordAudit(r *http.Request, actor, action, target, detail string) {
+	err := s.db.RecordAudit(database.AuditEntry{
+		Actor:        actor,
+		Action:       action,
+		Target:       target,
+		Detail:       detail,
+		RequestID:    middleware.GetReqID(r.Context()),
+		IP:           peerIP(r),
+		ForwardedFor: forwardedFor(r),
+	})
+	if err != nil {
+		logging.Component(logAudit).ErrorContext(s.context(), "unable to record audit log entry", "action", action, "target", target, "err", err)
+	}
+}
+
+// auditLogin records a failed login, or a successful one when it starts a
+// new session. Logins with a detail, such as those to the admin listener,
+// have sessions of their own.
+func (s State) auditLogin(r *http.Request, username string, ok bool, detail string) {
+	session := username
+	if detail != "" {
+		session = detail + ":" + username
+	}
+	if ok && !s.sessions.start(session, time.Now()) {
+		return
+	}
+	action := auditLoginFailure
+	if ok {
+		action = auditLoginSuccess
+	}
+	s.recordAudit(r, username, action, username, detail)
+}
+
+// sessionTracker remembers when users last made a request. A nil
+// sessionTracker treats every request as a new session.
+type sessionTracker struct {
+	mu       sync.Mutex
+	lastSeen map[string]time.Time
+}
+
+func newSessionTracker() *sessionTracker {
+	return &sessionTracker{lastSeen: make(map[string]time.Time)}
+}
+
+// start records the user's request at now and reports whether it starts a
+// new session.
+func (t *sessionTracker) start(username string, now time.Time) bool {
+	if t == nil {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, ok := t.lastSeen[username]
+	t.lastSeen[username] = now
+	return !ok || now.Sub(last) >= loginSessionGap
+}
+
+// prune forgets users that have not made a request for the session gap.
+func (t *sessionTracker) prune(now time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for username, last := range t.lastSeen {
+		if now.Sub(last) >= loginSessionGap {
+			delete(t.lastSeen, username)
+		}
+	}
+}
+
+// /admin/audit?actor=...&action=...&target=...&since=...&until=...&limit=...&offset=...
+// since and until are RFC 3339 times, action matches actions starting with it.
+func (s State) getAuditLog(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageFromQuery(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	filter := database.AuditFilter{
+		Actor:  strings.TrimSpace(q.Get("actor")),
+		Action: strings.TrimSpace(q.Get("action")),
+		Target: strings.TrimSpace(q.Get("target")),
+		Limit:  limit,
+		Offset: offset,
+	}
+	for param, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+		if *t, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest)+", "+param+" parameter must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+	entries, err := s.db.ListAudit(filter)
+	if err != nil {
+		s.handle500Err(w, " unable to list audit log")
+		return
+	}
+	s.audit(r, auditSearchAudit, "", r.URL.RawQuery)
+	s.writeJSON(w, entries, "audit log")
+}
+
+// RunAuditRetention removes audit log entries older than AuditRetention
+// every interval until the context is cancelled. It is started once the
+// retention has been configured, a retention of zero keeps every entry.
+func (s *State) RunAuditRetention(ctx context.Context, interval time.Duration) {
+	if s.AuditRetention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		runCtx, span := tracer().Start(ctx, "worker.auditRetention")
+		n, err := s.withContext(runCtx).db.PruneAudit(s.AuditRetention)
+		if err != nil {
+			logging.Component(logAudit).ErrorContext(runCtx, "unable to prune audit log", "err", err)
+		}
+		if n > 0 {
+			logging.Component(logAudit).InfoContext(runCtx, "pruned audit log", "count", n, "retention", s.AuditRetention.String())
+		}
+		span.SetAttributes(attribute.Int("audit.pruned", n))
+		span.End()
+		s.workers.ran("auditRetention", interval, start)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
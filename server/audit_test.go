@@ -0,0 +1,220 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+func TestSessionTracker(t *testing.T) {
+	sessions := newSessionTracker()
+	now := time.Now()
+	if !sessions.start("player2", now) {
+		t.Error("the first request should start a session")
+	}
+	if sessions.start("player2", now.Add(time.Minute)) {
+		t.Error("a request within the session gap should not start a session")
+	}
+	if !sessions.start("player2", now.Add(time.Minute+loginSessionGap)) {
+		t.Error("a request after the session gap should start a session")
+	}
+	sessions.prune(now.Add(time.Minute + 2*loginSessionGap))
+	if len(sessions.lastSeen) != 0 {
+		t.Errorf("expected idle users to be forgotten, got %v", sessions.lastSeen)
+	}
+}
+
+func TestAuditLogins(t *testing.T) {
+	tests := []struct {
+		name    string
+		db      database.Connection
+		want    []string
+		allowed bool
+	}{
+		{"success is recorded once per session", new(passDB), []string{auditLoginSuccess}, true},
+		{"every failure is recorded", new(wrongPasswordDB), []string{auditLoginFailure, auditLoginFailure}, false},
+		{"banned", new(bannedDB), []string{auditLoginFailure, auditLoginFailure}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &auditDB{Connection: tt.db}
+			s := &State{db: db, sessions: newSessionTracker()}
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest("GET", "/game/start", nil)
+				req.RemoteAddr = "203.0.113.9:5000"
+				req.Header.Set("Authorization", getAuthHeader())
+				if ok := s.authMiddleware(httptest.NewRecorder(), req); ok != tt.allowed {
+					t.Fatalf("got authenticated %t want %t", ok, tt.allowed)
+				}
+			}
+			if len(db.entries) != len(tt.want) {
+				t.Fatalf("got %v want actions %v", db.entries, tt.want)
+			}
+			for i, entry := range db.entries {
+				if entry.Action != tt.want[i] || entry.Actor != "captainnobody1" || entry.IP != "203.0.113.9" {
+					t.Errorf("got %+v want %s by captainnobody1 from 203.0.113.9", entry, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAuditAddresses(t *testing.T) {
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		wantIP        string
+		wantForwarded string
+	}{
+		{"direct", "198.51.100.1:5000", "198.51.100.1", ""},
+		{"through a trusted proxy", "10.0.0.5:5000", "10.0.0.5", "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &auditDB{Connection: new(passDB)}
+			s := &State{db: db, TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+			handler := s.realIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s.recordAudit(r, "captainnobody1", auditRegister, "captainnobody1", "")
+			}))
+			req := httptest.NewRequest("GET", "/register", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "203.0.113.9")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if len(db.entries) != 1 {
+				t.Fatalf("got %v want one entry", db.entries)
+			}
+			if entry := db.entries[0]; entry.IP != tt.wantIP || entry.ForwardedFor != tt.wantForwarded {
+				t.Errorf("got ip %q forwarded for %q want %q and %q", entry.IP, entry.ForwardedFor, tt.wantIP, tt.wantForwarded)
+			}
+		})
+	}
+}
+
+func TestAuditAdminLogins(t *testing.T) {
+	tests := []struct {
+		name   string
+		db     database.Connection
+		want   []string
+		detail string
+		status int
+	}{
+		{"success is recorded once per session", new(adminDB), []string{auditLoginSuccess}, "admin", http.StatusOK},
+		{"every failure is recorded", new(wrongPasswordDB), []string{auditLoginFailure, auditLoginFailure}, "admin", http.StatusUnauthorized},
+		{"not an admin", new(passDB), []string{auditLoginFailure, auditLoginFailure}, "admin, not an admin", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &auditDB{Connection: tt.db}
+			s := &State{db: db, sessions: newSessionTracker()}
+			router := s.adminRoutes(prometheus.NewRegistry())
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest("GET", "/metrics", nil)
+				req.RemoteAddr = "203.0.113.9:5000"
+				req.Header.Set("Authorization", getAuthHeader())
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				if w.Code != tt.status {
+					t.Fatalf("got status %d want %d", w.Code, tt.status)
+				}
+			}
+			if len(db.entries) != len(tt.want) {
+				t.Fatalf("got %v want actions %v", db.entries, tt.want)
+			}
+			for i, entry := range db.entries {
+				if entry.Action != tt.want[i] || entry.Actor != "captainnobody1" || entry.Detail != tt.detail {
+					t.Errorf("got %+v want %s by captainnobody1 with detail %q", entry, tt.want[i], tt.detail)
+				}
+			}
+		})
+	}
+}
+
+func TestAuditRegister(t *testing.T) {
+	db := &auditDB{Connection: new(passDB)}
+	s := &State{db: db}
+	handler := middleware.RequestID(http.HandlerFunc(s.registerUser))
+	req := httptest.NewRequest("GET", "/register?username=player3", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body.String())
+	}
+	if len(db.entries) != 1 {
+		t.Fatalf("expected one audit entry, got %v", db.entries)
+	}
+	entry := db.entries[0]
+	if entry.Action != auditRegister || entry.Target != "player3" || entry.RequestID == "" {
+		t.Errorf("got %+v want %s of player3 with a request ID", entry, auditRegister)
+	}
+}
+
+// endedDB has a game that has already ended.
+type endedDB struct {
+	passDB
+}
+
+func (db *endedDB) EndGame(gameID int64, outcome, winner string) error {
+	return database.ErrGameEnded
+}
+
+func TestAuditStopGame(t *testing.T) {
+	tests := []struct {
+		name string
+		db   database.Connection
+		want int
+		logs int
+	}{
+		{"stopped", new(passDB), http.StatusOK, 1},
+		{"already ended", new(endedDB), http.StatusConflict, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &auditDB{Connection: tt.db}
+			s := State{db: db}
+			r := chi.NewRouter()
+			r.Get("/game/{gameID}/stop", s.stopGame)
+			req := httptest.NewRequest("GET", "/game/321/stop", nil)
+			req.Header.Set("Authorization", getAuthHeader())
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("got status %d want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if len(db.entries) != tt.logs {
+				t.Errorf("got audit entries %v want %d", db.entries, tt.logs)
+			}
+		})
+	}
+}
+
+func TestGetAuditLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		db     database.Connection
+		target string
+		want   int
+	}{
+		{"list", new(adminDB), "/admin/audit?action=game.&since=2024-01-02T15:04:05Z", http.StatusOK},
+		{"bad since", new(adminDB), "/admin/audit?since=yesterday", http.StatusBadRequest},
+		{"bad limit", new(adminDB), "/admin/audit?limit=ten", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &auditDB{Connection: tt.db}
+			w := adminRequest(&State{db: db}, "GET", tt.target)
+			if w.Code != tt.want {
+				t.Errorf("got status %d want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want == http.StatusOK && (len(db.entries) != 1 || db.entries[0].Action != auditSearchAudit) {
+				t.Errorf("expected the search to be audited, got %v", db.entries)
+			}
+		})
+	}
+}
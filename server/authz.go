@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// Role is the part a user plays in a game.
+type Role int
+
+const (
+	// RoleSpectator is any authenticated user that is not in the game.
+	RoleSpectator Role = iota
+	// RolePlayer is a user that joined the game to ask questions and guess.
+	RolePlayer
+	// RoleHost is the user that started the game and knows the answer.
+	RoleHost
+	// RoleAdmin is the global admin role. It is granted on top of the
+	// user's role in the game.
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RolePlayer:
+		return "player"
+	case RoleHost:
+		return "host"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "spectator"
+	}
+}
+
+// Action is something a user can do to a game.
+type Action string
+
+const (
+	ActionView   Action = "view"   // status and summary
+	ActionJoin   Action = "join"   // become a player
+	ActionLeave  Action = "leave"  // stop being a player
+	ActionAsk    Action = "ask"    // ask the host a question
+	ActionGuess  Action = "guess"  // guess the answer
+	ActionAnswer Action = "answer" // set the secret answer and answer questions
+	ActionStop   Action = "stop"   // end the game
+	ActionKick   Action = "kick"   // remove a player from the game
+	ActionInvite Action = "invite" // manage the invites of a private game
+)
+
+// permissions lists the actions each role is allowed to take.
+var permissions = map[Role][]Action{
+	RoleSpectator: {ActionView, ActionJoin},
+	RolePlayer:    {ActionView, ActionLeave, ActionAsk, ActionGuess},
+	RoleHost:      {ActionView, ActionLeave, ActionAnswer, ActionStop, ActionKick, ActionInvite},
+	RoleAdmin:     {ActionView, ActionStop, ActionKick},
+}
+
+// gameRole returns the role the user has in the game.
+func gameRole(game database.Game, username string) Role {
+	if game.Host == username {
+		return RoleHost
+	}
+	if isParticipant(game, username) {
+		return RolePlayer
+	}
+	return RoleSpectator
+}
+
+func roleAllows(role Role, action Action) bool {
+	for _, a := range permissions[role] {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// gameAccess is the caller's access to the game in the request path.
+type gameAccess struct {
+	Username string
+	Game     database.Game
+	Role     Role
+	Admin    bool
+}
+
+// can reports whether the caller is allowed to take the action.
+// Spectators of private games can only try to join them.
+func (a gameAccess) can(action Action) bool {
+	if a.Admin && roleAllows(RoleAdmin, action) {
+		return true
+	}
+	if a.Game.Private && a.Role == RoleSpectator && action != ActionJoin {
+		return false
+	}
+	return roleAllows(a.Role, action)
+}
+
+type contextKey int
+
+const gameAccessKey contextKey = iota
+
+func accessFromContext(ctx context.Context) (gameAccess, bool) {
+	access, ok := ctx.Value(gameAccessKey).(gameAccess)
+	return access, ok
+}
+
+// requestAccess returns the access loaded by gameCtx, writing an error
+// response if it is missing.
+func requestAccess(w http.ResponseWriter, r *http.Request) (gameAccess, bool) {
+	access, ok := accessFromContext(r.Context())
+	if !ok {
+		State{ctx: r.Context()}.handle500Err(w, " game access was not loaded")
+	}
+	return access, ok
+}
+
+// gameCtx loads the game in the request path along with the caller's role
+// in it so it can be checked by requireAction.
+func (s State) gameCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := s.withContext(r.Context())
+		username, err := usernameFromHeader(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		gameID, err := getAndValidateGameID(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		game, err := s.db.GetGameData(gameID)
+		if err != nil {
+			s.handle500Err(w, " unable to get game")
+			return
+		}
+		admin, err := s.db.IsAdmin(username)
+		if err != nil {
+			s.handle500Err(w, " unable to get user role")
+			return
+		}
+		access := gameAccess{
+			Username: username,
+			Game:     game,
+			Role:     gameRole(game, username),
+			Admin:    admin,
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), gameAccessKey, access)))
+	})
+}
+
+// requireAction returns middleware that rejects callers whose role in the
+// game does not allow the action. It must run after gameCtx.
+func requireAction(action Action) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			access, ok := requestAccess(w, r)
+			if !ok {
+				return
+			}
+			if !access.can(action) {
+				http.Error(w, fmt.Sprintf("%s, a %s cannot %s in this game", http.StatusText(http.StatusForbidden), access.Role, action), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// roleDB is a passing database with a game that has a host, a player and
+// an answer set, plus a global admin user.
+type roleDB struct {
+	passDB
+	ended   bool
+	private bool
+}
+
+func (db *roleDB) GetGameData(gameID int64) (database.Game, error) {
+	return database.Game{
+		GameID:     gameID,
+		Host:       "host",
+		Players:    []string{"host", "player"},
+		Answer:     "gopher",
+		Ended:      db.ended,
+		Private:    db.private,
+		InviteCode: "secret",
+	}, nil
+}
+
+func (db *roleDB) JoinWithInvite(username string, gameID int64, token string) error {
+	if err := db.AddUserToGame(username, gameID); err != nil {
+		return err
+	}
+	if token != "good" {
+		return database.ErrInvalidInvite
+	}
+	return nil
+}
+
+func (db *roleDB) AddUserToGame(username string, gameID int64) error {
+	if username == "banned" {
+		return database.ErrBanned
+	}
+	return nil
+}
+
+func (db *roleDB) IsAdmin(username string) (bool, error) {
+	return username == "admin", nil
+}
+
+func TestGameAuthorization(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		path    string
+		ended   bool
+		want    int
+		private bool
+	}{
+		{"host can stop", "host", "/game/1/stop", false, http.StatusOK, false},
+		{"player cannot stop", "player", "/game/1/stop", false, http.StatusForbidden, false},
+		{"spectator cannot stop", "spectator", "/game/1/stop", false, http.StatusForbidden, false},
+		{"admin can stop", "admin", "/game/1/stop", false, http.StatusOK, false},
+		{"host can set answer", "host", "/game/1/play?answer=gopher", false, http.StatusOK, false},
+		{"player cannot set answer", "player", "/game/1/play?answer=gopher", false, http.StatusForbidden, false},
+		{"host can answer", "host", "/game/1/answer?questionID=1&answer=yes", false, http.StatusOK, false},
+		{"player cannot answer", "player", "/game/1/answer?questionID=1&answer=yes", false, http.StatusForbidden, false},
+		{"admin cannot answer", "admin", "/game/1/answer?questionID=1&answer=yes", false, http.StatusForbidden, false},
+		{"player can ask", "player", "/game/1/ask?question=is+it+blue", false, http.StatusOK, false},
+		{"host cannot ask", "host", "/game/1/ask?question=is+it+blue", false, http.StatusForbidden, false},
+		{"spectator cannot ask", "spectator", "/game/1/ask?question=is+it+blue", false, http.StatusForbidden, false},
+		{"player can guess", "player", "/game/1/guess?guess=whale", false, http.StatusOK, false},
+		{"spectator cannot guess", "spectator", "/game/1/guess?guess=whale", false, http.StatusForbidden, false},
+		{"player cannot guess after end", "player", "/game/1/guess?guess=whale", true, http.StatusConflict, false},
+		{"spectator can join", "spectator", "/game/1/join", false, http.StatusOK, false},
+		{"player cannot join twice", "player", "/game/1/join", false, http.StatusForbidden, false},
+		{"player can leave", "player", "/game/1/leave", false, http.StatusOK, false},
+		{"host can leave", "host", "/game/1/leave", false, http.StatusOK, false},
+		{"spectator cannot leave", "spectator", "/game/1/leave", false, http.StatusForbidden, false},
+		{"host can kick player", "host", "/game/1/kick?username=player", false, http.StatusOK, false},
+		{"host cannot kick spectator", "host", "/game/1/kick?username=spectator", false, http.StatusBadRequest, false},
+		{"host can ban spectator", "host", "/game/1/ban?username=spectator&reason=spam", false, http.StatusOK, false},
+		{"host cannot ban self", "host", "/game/1/ban?username=host", false, http.StatusBadRequest, false},
+		{"admin can kick", "admin", "/game/1/kick?username=player", false, http.StatusOK, false},
+		{"player cannot kick", "player", "/game/1/kick?username=host", false, http.StatusForbidden, false},
+		{"player cannot ban", "player", "/game/1/ban?username=spectator", false, http.StatusForbidden, false},
+		{"banned user cannot join", "banned", "/game/1/join", false, http.StatusForbidden, false},
+		{"spectator can view status", "spectator", "/game/1/status", false, http.StatusOK, false},
+		{"spectator cannot view private status", "spectator", "/game/1/status", false, http.StatusForbidden, true},
+		{"player can view private status", "player", "/game/1/status", false, http.StatusOK, true},
+		{"spectator cannot join private without invite", "spectator", "/game/1/join", false, http.StatusForbidden, true},
+		{"spectator cannot join private with wrong code", "spectator", "/game/1/join?code=guess", false, http.StatusForbidden, true},
+		{"spectator can join private with code", "spectator", "/game/1/join?code=secret", false, http.StatusOK, true},
+		{"spectator can join private with invite", "spectator", "/game/1/join?invite=good", false, http.StatusOK, true},
+		{"spectator cannot join private with used invite", "spectator", "/game/1/join?invite=used", false, http.StatusForbidden, true},
+		{"banned user cannot join private with invite", "banned", "/game/1/join?invite=good", false, http.StatusForbidden, true},
+		{"host can create invite", "host", "/game/1/invites/new?expiresIn=1h&maxUses=3", false, http.StatusCreated, true},
+		{"host cannot create invite with bad expiry", "host", "/game/1/invites/new?expiresIn=soon", false, http.StatusBadRequest, true},
+		{"host can list invites", "host", "/game/1/invites/", false, http.StatusOK, true},
+		{"player cannot create invite", "player", "/game/1/invites/new", false, http.StatusForbidden, true},
+		{"spectator cannot view summary", "spectator", "/game/1/summary", false, http.StatusForbidden, false},
+		{"player can view summary after end", "player", "/game/1/summary", true, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &State{
+				db: &roleDB{ended: tt.ended, private: tt.private},
+			}
+			r := chi.NewRouter()
+			r.Route("/game", s.gameRoutes)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.SetBasicAuth(tt.user, "password")
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v: %s",
+					w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// event types sent on the game event stream
+const (
+	EventJoined           = "joined"
+	EventLeft             = "left"
+	EventKicked           = "kicked"
+	EventBanned           = "banned"
+	EventHostChanged      = "host_changed"
+	EventAnswerSet        = "answer_set"
+	EventTurn             = "turn"
+	EventTurnSkipped      = "turn_skipped"
+	EventQuestionAsked    = "question_asked"
+	EventQuestionAnswered = "question_answered"
+	EventGuessed          = "guessed"
+	EventGameEnded        = "game_ended"
+	EventAchievement      = "achievement_unlocked"
+)
+
+// Event is a change to a game that is broadcast to everyone watching it.
+type Event struct {
+	Type     string
+	GameID   int64
+	Username string // the user the event is about
+	Message  string
+	Seq      int64 // position of the change in the game's history, 0 for events that are not part of it
+	Time     time.Time
+}
+
+// eventHub fans game events out to the subscribers of each game.
+// A nil hub drops every event, which keeps tests that do not care
+// about events simple.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[int64]map[chan Event]struct{}
+	// the earliest change new subscribers have read the history up to,
+	// for games the event feed has not started following yet
+	starts map[int64]int64
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subs:   make(map[int64]map[chan Event]struct{}),
+		starts: make(map[int64]int64),
+	}
+}
+
+// subscribe returns a channel that receives the events of the game
+// and a function that must be called to stop receiving them.
+func (h *eventHub) subscribe(gameID int64) (<-chan Event, func()) {
+	ch := make(chan Event, 16)
+	h.mu.Lock()
+	if h.subs[gameID] == nil {
+		h.subs[gameID] = make(map[chan Event]struct{})
+	}
+	h.subs[gameID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs[gameID], ch)
+		if len(h.subs[gameID]) == 0 {
+			delete(h.subs, gameID)
+			delete(h.starts, gameID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// startFrom records that a subscriber has read the game's history up to
+// seq. A game the event feed is not following yet is followed from the
+// earliest such change, so its subscribers neither miss a change nor get
+// one they have already read.
+func (h *eventHub) startFrom(gameID, seq int64) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if start, ok := h.starts[gameID]; !ok || seq < start {
+		h.starts[gameID] = seq
+	}
+}
+
+// takeStart returns and forgets where the game's subscribers have read its
+// history up to, reporting false if none of them have yet.
+func (h *eventHub) takeStart(gameID int64) (int64, bool) {
+	if h == nil {
+		return 0, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	start, ok := h.starts[gameID]
+	delete(h.starts, gameID)
+	return start, ok
+}
+
+// subscribers returns the number of clients streaming events.
+func (h *eventHub) subscribers() int {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var n int
+	for _, subs := range h.subs {
+		n += len(subs)
+	}
+	return n
+}
+
+// subscribersByGame returns the number of clients streaming the events
+// of each game.
+func (h *eventHub) subscribersByGame() map[int64]int {
+	byGame := make(map[int64]int)
+	if h == nil {
+		return byGame
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for gameID, subs := range h.subs {
+		byGame[gameID] = len(subs)
+	}
+	return byGame
+}
+
+// publish sends the event to every subscriber of its game and returns how
+// many received it. Subscribers that are not keeping up miss the event
+// rather than blocking the game.
+func (h *eventHub) publish(e Event) (delivered, dropped int) {
+	if h == nil {
+		return 0, 0
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[e.GameID] {
+		select {
+		case ch <- e:
+			delivered++
+		default:
+			dropped++
+		}
+	}
+	return delivered, dropped
+}
+
+// /game/{gameID}/events?after=...
+// streams the game's events as server-sent events until the client
+// disconnects. Clients that reconnect pass the Seq of the last event they
+// saw as after to catch up on what they missed.
+func (s State) streamEvents(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok || s.events == nil {
+		s.handle500Err(w, " event streaming is not supported")
+		return
+	}
+	var after int64
+	if v := r.URL.Query().Get("after"); v != "" {
+		var err error
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil || after < 0 {
+			http.Error(w, http.StatusText(http.StatusBadRequest)+", after parameter must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	// subscribe before reading the history so nothing is missed in between
+	events, unsubscribe := s.events.subscribe(access.Game.GameID)
+	defer unsubscribe()
+	history, err := s.db.GetGameEvents(access.Game.GameID, after)
+	if err != nil {
+		s.handle500Err(w, " unable to get game history")
+		return
+	}
+	var missed []Event
+	if r.URL.Query().Has("after") {
+		for _, e := range history {
+			missed = append(missed, eventsFromHistory(e)...)
+		}
+	}
+	read := after
+	if seq := lastSeq(history); seq > read {
+		read = seq
+	}
+	s.events.startFrom(access.Game.GameID, read)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	for _, e := range missed {
+		writeEvent(w, e)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-events:
+			// changes broadcast between subscribing and reading the
+			// history are part of what was read
+			if e.Seq != 0 && e.Seq <= read {
+				continue
+			}
+			writeEvent(w, e)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes the event as a server-sent event.
+func writeEvent(w http.ResponseWriter, e Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventHub(t *testing.T) {
+	h := newEventHub()
+	events, unsubscribe := h.subscribe(1)
+	other, unsubscribeOther := h.subscribe(2)
+	defer unsubscribeOther()
+
+	h.publish(Event{Type: EventJoined, GameID: 1, Username: "p1"})
+	select {
+	case e := <-events:
+		if e.Type != EventJoined || e.Username != "p1" || e.Time.IsZero() {
+			t.Errorf("wrong event: got %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event was not delivered")
+	}
+	select {
+	case e := <-other:
+		t.Errorf("event delivered to the wrong game: %+v", e)
+	default:
+	}
+
+	unsubscribe()
+	h.publish(Event{Type: EventLeft, GameID: 1})
+	if _, ok := h.subs[1]; ok {
+		t.Error("game subscribers were not cleaned up")
+	}
+
+	// a nil hub drops events
+	var nilHub *eventHub
+	nilHub.publish(Event{Type: EventLeft, GameID: 1})
+}
@@ -1,58 +1,104 @@
 package server
 
 import (
+	crand "crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"log"
-	"math/rand"
 	"net/http"
+	"net/url"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/credentials"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
 )
 
-// this only needs to happen once per program execution
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 func (s State) makeGamePath(gameID int64) string {
 	return fmt.Sprintf("%s/game/%d", s.BaseURL, gameID)
 }
 
+// makeJoinPath returns the link used to join a game. Private games need
+// the invite code or an invite token to be joined.
+func (s State) makeJoinPath(gameID int64, param, code string) string {
+	if code == "" {
+		return s.makeGamePath(gameID) + "/join"
+	}
+	return fmt.Sprintf("%s/join?%s=%s", s.makeGamePath(gameID), param, url.QueryEscape(code))
+}
+
+// genToken returns an unguessable url safe token built from n random bytes.
+func genToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 func usernameFromHeader(w http.ResponseWriter, r *http.Request) (string, error) {
 	username, _, ok := r.BasicAuth()
 	if !ok {
 		return "", errors.New("Authorization header must be in the form username:password")
 	}
-	fmt.Println(username)
 	return username, nil
 }
 
 // we want the header to include basic auth - username:password
 // we wnt this method to return the username
 // https://developer.mozilla.org/en-US/docs/Web/HTTP/Authentication
-func (s *State) authMiddleware(w http.ResponseWriter, r *http.Request) {
+// it reports whether the request is authenticated.
+func (s *State) authMiddleware(w http.ResponseWriter, r *http.Request) bool {
 	//https://pkg.go.dev/net/http#Request.BasicAuth
 	username, password, ok := r.BasicAuth()
 	if !ok {
-		counter400Code.Add(1)
 		http.Error(w, http.StatusText(http.StatusBadRequest)+", Authorization header must be in the form username:password", http.StatusBadRequest)
-		return
+		return false
+	}
+	if !s.checkLogin(w, r, username, password, "") {
+		return false
+	}
+	s.auditLogin(r, username, true, "")
+	if err := s.db.TouchLastSeen(username); err != nil {
+		logging.Component(logAccount).WarnContext(s.context(), "unable to update last seen", "err", err)
 	}
-	if isValid, err := s.db.CheckUserValid(username, password); err != nil || !isValid {
-		counter400Code.Add(1)
+	return true
+}
+
+// checkLogin checks the user's password, writing the error response when
+// the login is refused. Every failed login counts towards the lockout and
+// is audited with the detail, successful logins are left to the caller to
+// audit once it has accepted them.
+func (s State) checkLogin(w http.ResponseWriter, r *http.Request, username, password, detail string) bool {
+	if s.loginLocked(w, r, username) {
+		return false
+	}
+	isValid, err := s.db.CheckUserValid(username, password)
+	if errors.Is(err, database.ErrUserBanned) {
+		s.auditLogin(r, username, false, strings.TrimSpace(detail+" banned"))
+		http.Error(w, http.StatusText(http.StatusForbidden)+", this account has been banned", http.StatusForbidden)
+		return false
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to check user")
+		return false
+	}
+	// every failure counts towards the lockout, including unknown usernames
+	s.limiter.loginResult(s.context(), r, username, isValid)
+	if !isValid {
+		s.auditLogin(r, username, false, detail)
 		http.Error(w, http.StatusText(http.StatusUnauthorized)+", Username or password do not exist", http.StatusUnauthorized)
-		return
+		return false
 	}
+	return true
 }
 
 func getAndValidateUsername(w http.ResponseWriter, r *http.Request) (string, error) {
 	headerUsername, err := usernameFromHeader(w, r)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		counter400Code.Add(1)
 		err := errors.New(http.StatusText(http.StatusBadRequest) + err.Error())
 		return "", err
 	}
@@ -63,7 +109,6 @@ func getAndValidateUsername(w http.ResponseWriter, r *http.Request) (string, err
 	}
 	// stop user from editing other users' data
 	if headerUsername != username {
-		counter400Code.Add(1)
 		return "", errors.New("username does not match")
 	}
 
@@ -71,11 +116,9 @@ func getAndValidateUsername(w http.ResponseWriter, r *http.Request) (string, err
 	// check if username is empty
 	if username == "" {
 		w.WriteHeader(http.StatusBadRequest)
-		counter400Code.Add(1)
 		err := errors.New(http.StatusText(http.StatusBadRequest) + ", username parameter cannot be empty")
 		return "", err
 	}
-	fmt.Println(username)
 	return username, nil
 }
 
@@ -83,45 +126,40 @@ func getAndValidateGameID(w http.ResponseWriter, r *http.Request) (int64, error)
 	gameID := chi.URLParam(r, "gameID")
 	if gameID == "" {
 		w.WriteHeader(http.StatusBadRequest)
-		counter400Code.Add(1)
 		err := errors.New(http.StatusText(http.StatusBadRequest) + ", gameID parameter cannot be empty")
 		return 0, err
 	}
 	gameID64, err := strconv.ParseInt(gameID, 10, 64)
 	if err != nil {
-		log.Println(err)
 		w.WriteHeader(http.StatusBadRequest)
-		counter400Code.Add(1)
 		err := errors.New(http.StatusText(http.StatusBadRequest) + ", gameID parameter must be an integer")
 		return 0, err
 	}
 	return gameID64, nil
 }
 
-var symbols = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ123456789!@#$%^&*()_+")
-var randomNames = []string{"ShadowDragon", "CrimsonReaper", "NightAssassin", "SavageHunter", "Thunderbolt", "StormBringer", "IceQueen", "FireDemon", "TheOneTrueHero", "SilverWolf", "GoldenKnight", "MasterMind", "CyborgAssassin", "ElectricEagle", "GalacticGamer", "NeonNinja", "DarkPhoenix", "DiamondDragon", "ChaosKing", "MysticMage", "IronGiant", "CelestialSiren", "ShadowHunter", "DeathWish", "SnowLeopard", "CosmicCrusader", "EternalKnight", "PhoenixBlaze", "ThunderStorm"}
+// usernameAttempts is how many generated usernames are tried before
+// giving up on registering a user with a random name.
+const usernameAttempts = 10
 
-func genPassword() string {
-	var password string
-	for i := 0; i < 10; i++ {
-		password += string(symbols[rand.Intn(len(symbols))])
+// passwordPolicy returns the policy passwords must meet, using the default
+// policy if the server was not given one.
+func (s State) passwordPolicy() credentials.PasswordPolicy {
+	if s.PasswordPolicy == (credentials.PasswordPolicy{}) {
+		return credentials.DefaultPolicy
 	}
-	return password
+	return s.PasswordPolicy
 }
 
-func (s State) genUsername() (string, error) {
-	// add new user if not exists
-	username := randomNames[rand.Intn(len(randomNames))]
-	password := genPassword()
-	err := s.db.UpsertUsername(username, password)
-	if err != nil {
-		return "", err
-	}
-	return username, nil
+// genPassword returns a random password that meets the password policy.
+func (s State) genPassword() (string, error) {
+	return credentials.Password(s.passwordPolicy())
 }
 
-func handle500Err(w http.ResponseWriter, err string) {
-	log.Println(errors.New(err))
-	http.Error(w, err, http.StatusInternalServerError)
-	counter500Code.Add(1)
+// genUsername registers a user with a random username and the password,
+// trying another username when one is already taken.
+func (s State) genUsername(password string) (string, error) {
+	return credentials.UniqueUsername(usernameAttempts, database.ErrUsernameTaken, func(username string) error {
+		return s.db.UpsertUsername(username, password)
+	})
 }
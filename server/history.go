@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/soypete/golang-cli-game/achievements"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// eventsFromHistory returns the events broadcast for a change in a game's
+// history. The secret answer is never part of them.
+func eventsFromHistory(e database.GameEvent) []Event {
+	event := Event{GameID: e.GameID, Username: e.Username, Seq: e.Seq, Time: e.CreatedAt}
+	switch e.Type {
+	case database.GameEventJoined:
+		event.Type = EventJoined
+	case database.GameEventLeft:
+		event.Type = EventLeft
+	case database.GameEventRemoved:
+		event.Type, event.Message = EventKicked, e.Data.Reason
+		if e.Data.Banned {
+			event.Type = EventBanned
+		}
+	case database.GameEventHostChanged:
+		left := Event{Type: EventLeft, GameID: e.GameID, Username: e.Data.By, Seq: e.Seq, Time: e.CreatedAt}
+		event.Type, event.Message = EventHostChanged, fmt.Sprintf("%s is now the host", e.Username)
+		return []Event{left, event}
+	case database.GameEventAnswerSet:
+		event.Type = EventAnswerSet
+	case database.GameEventTurn:
+		// nobody having the turn is not worth announcing
+		if e.Username == "" {
+			return nil
+		}
+		event.Type, event.Message = EventTurn, fmt.Sprintf("it is %s's turn to ask a question", e.Username)
+		if e.Data.Deadline != nil {
+			event.Message += fmt.Sprintf(", they have %s", e.Data.Deadline.Sub(e.CreatedAt).Round(time.Second))
+		}
+	case database.GameEventQuestionAsked:
+		event.Type, event.Message = EventQuestionAsked, e.Data.Text
+	case database.GameEventQuestionAnswered:
+		event.Type, event.Message = EventQuestionAnswered, e.Data.Answer
+		if e.Data.Answer == database.NoAnswer {
+			event.Message += ", the host ran out of time"
+		}
+	case database.GameEventGuessed:
+		event.Type, event.Message = EventGuessed, e.Data.Text
+	case database.GameEventEnded:
+		event.Type, event.Message = EventGameEnded, e.Data.Outcome
+	case database.GameEventTurnSkipped:
+		event.Type, event.Message = EventTurnSkipped, "ran out of time to ask a question"
+	case database.GameEventAchievement:
+		event.Type, event.Message = EventAchievement, e.Data.Achievement
+		if d, ok := achievements.Find(achievements.Default, e.Data.Achievement); ok {
+			event.Message = d.Name + ": " + d.Description
+		}
+	default:
+		return nil
+	}
+	return []Event{event}
+}
+
+// runEventFeed follows the history of every game that is being watched
+// and broadcasts its changes, every interval until the context is
+// cancelled.
+func (s State) runEventFeed(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// the last change broadcast for each game being watched
+	cursors := make(map[int64]int64)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			s.feedEvents(ctx, cursors)
+			s.workers.ran("eventFeed", interval, start)
+		}
+	}
+}
+
+// feedEvents broadcasts the changes to each watched game since its cursor.
+// Games that have just started being watched are followed from where
+// their streams read the history up to, since the streams have already
+// sent what came before. Runs with games to follow are traced.
+func (s State) feedEvents(ctx context.Context, cursors map[int64]int64) {
+	watched := s.events.subscribersByGame()
+	for gameID := range cursors {
+		if watched[gameID] == 0 {
+			delete(cursors, gameID)
+		}
+	}
+	if len(watched) == 0 {
+		return
+	}
+	runCtx, span := tracer().Start(ctx, "worker.eventFeed")
+	defer span.End()
+	s = s.withContext(runCtx)
+	published := 0
+	for gameID := range watched {
+		start, started := s.events.takeStart(gameID)
+		if _, ok := cursors[gameID]; !ok {
+			// wait for the stream to read the history
+			if !started {
+				continue
+			}
+			cursors[gameID] = start
+		}
+		history, err := s.db.GetGameEvents(gameID, cursors[gameID])
+		if err != nil {
+			logging.Component(logGame).ErrorContext(s.context(), "unable to follow game history", "game_id", gameID, "err", err)
+			continue
+		}
+		for _, e := range history {
+			for _, event := range eventsFromHistory(e) {
+				s.publish(event)
+				published++
+			}
+			cursors[gameID] = e.Seq
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("games.watched", len(watched)),
+		attribute.Int("events.published", published),
+	)
+}
+
+// lastSeq returns the position of the most recent change in the history.
+func lastSeq(history []database.GameEvent) int64 {
+	if len(history) == 0 {
+		return 0
+	}
+	return history[len(history)-1].Seq
+}
+
+// GameHistory is a game's history along with the game it adds up to.
+type GameHistory struct {
+	Game   database.Game
+	Events []database.GameEvent
+}
+
+// /admin/games/{gameID}/history
+// returns every change made to the game, including its answer.
+func (s State) getGameHistory(w http.ResponseWriter, r *http.Request) {
+	gameID, err := getAndValidateGameID(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	history, err := s.db.GetGameEvents(gameID, 0)
+	if err != nil {
+		s.handle500Err(w, " unable to get game history")
+		return
+	}
+	if len(history) == 0 {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", game has no history", http.StatusNotFound)
+		return
+	}
+	game := database.FoldGame(history)
+	// games started before their history was recorded have no created
+	// event to fold from, so the stored game is shown instead
+	if history[0].Type != database.GameEventCreated {
+		game, err = s.db.GetGameData(gameID)
+		if err != nil {
+			s.handle500Err(w, " unable to get game")
+			return
+		}
+	}
+	s.audit(r, auditGameHistory, fmt.Sprint(gameID), "")
+	s.writeJSON(w, GameHistory{Game: game, Events: history}, "game history")
+}
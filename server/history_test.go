@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+func TestEventsFromHistory(t *testing.T) {
+	created := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	deadline := created.Add(30 * time.Second)
+	tests := []struct {
+		name  string
+		event database.GameEvent
+		want  []Event
+	}{
+		{"created is not broadcast", database.GameEvent{Type: database.GameEventCreated, Username: "host"}, nil},
+		{"joined", database.GameEvent{Type: database.GameEventJoined, Username: "p1"},
+			[]Event{{Type: EventJoined, Username: "p1"}}},
+		{"banned", database.GameEvent{Type: database.GameEventRemoved, Username: "p1", Data: database.GameEventData{By: "host", Reason: "spam", Banned: true}},
+			[]Event{{Type: EventBanned, Username: "p1", Message: "spam"}}},
+		{"host changed", database.GameEvent{Type: database.GameEventHostChanged, Username: "p1", Data: database.GameEventData{By: "host"}},
+			[]Event{{Type: EventLeft, Username: "host"}, {Type: EventHostChanged, Username: "p1", Message: "p1 is now the host"}}},
+		{"answer is kept secret", database.GameEvent{Type: database.GameEventAnswerSet, Username: "host", Data: database.GameEventData{Answer: "a cat"}},
+			[]Event{{Type: EventAnswerSet, Username: "host"}}},
+		{"turn with a deadline", database.GameEvent{Type: database.GameEventTurn, Username: "p1", Data: database.GameEventData{Deadline: &deadline}},
+			[]Event{{Type: EventTurn, Username: "p1", Message: "it is p1's turn to ask a question, they have 30s"}}},
+		{"nobody's turn", database.GameEvent{Type: database.GameEventTurn}, nil},
+		{"host ran out of time", database.GameEvent{Type: database.GameEventQuestionAnswered, Username: "host", Data: database.GameEventData{QuestionID: 1, Answer: database.NoAnswer}},
+			[]Event{{Type: EventQuestionAnswered, Username: "host", Message: "no answer, the host ran out of time"}}},
+		{"ended", database.GameEvent{Type: database.GameEventEnded, Username: "p1", Data: database.GameEventData{Outcome: database.OutcomeSolved}},
+			[]Event{{Type: EventGameEnded, Username: "p1", Message: database.OutcomeSolved}}},
+		{"turn skipped", database.GameEvent{Type: database.GameEventTurnSkipped, Username: "p1"},
+			[]Event{{Type: EventTurnSkipped, Username: "p1", Message: "ran out of time to ask a question"}}},
+		{"achievement", database.GameEvent{Type: database.GameEventAchievement, Username: "p1", Data: database.GameEventData{Achievement: "first_win"}},
+			[]Event{{Type: EventAchievement, Username: "p1", Message: "First Win: Win a game as a player or the host"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.event.GameID, tt.event.Seq, tt.event.CreatedAt = 1, 7, created
+			got := eventsFromHistory(tt.event)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v want %+v", got, tt.want)
+			}
+			for i, want := range tt.want {
+				want.GameID, want.Seq, want.Time = 1, 7, created
+				if got[i] != want {
+					t.Errorf("got %+v want %+v", got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestFeedEvents(t *testing.T) {
+	s := State{db: new(passDB), events: newEventHub()}
+	events, unsubscribe := s.events.subscribe(321)
+	defer unsubscribe()
+	cursors := make(map[int64]int64)
+
+	// the game is not followed until the stream has read its history
+	s.feedEvents(context.Background(), cursors)
+	if _, ok := cursors[321]; ok {
+		t.Fatal("the game was followed before its stream read the history")
+	}
+
+	// and then only from where the stream read up to
+	s.events.startFrom(321, 1)
+	s.feedEvents(context.Background(), cursors)
+	for _, want := range []string{EventJoined, EventAnswerSet} {
+		select {
+		case e := <-events:
+			if e.Type != want {
+				t.Errorf("got %s want %s", e.Type, want)
+			}
+		default:
+			t.Fatalf("expected a %s event", want)
+		}
+	}
+	if cursors[321] != 3 {
+		t.Errorf("got cursor %d want 3", cursors[321])
+	}
+	// nothing has changed since
+	s.feedEvents(context.Background(), cursors)
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+
+	unsubscribe()
+	s.feedEvents(context.Background(), cursors)
+	if _, ok := cursors[321]; ok {
+		t.Error("games nobody is watching should not be followed")
+	}
+}
+
+func TestStreamEventsCatchUp(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"live events only", "", []string{EventGuessed}},
+		{"catch up", "?after=1", []string{EventJoined, EventAnswerSet, EventGuessed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &State{db: new(passDB), events: newEventHub()}
+			r := chi.NewRouter()
+			r.Route("/game", s.gameRoutes)
+			ctx, cancel := context.WithCancel(context.Background())
+			req := httptest.NewRequest("GET", "/game/321/events"+tt.query, nil).WithContext(ctx)
+			req.Header.Set("Authorization", getAuthHeader())
+			w := httptest.NewRecorder()
+			done := make(chan struct{})
+			go func() {
+				r.ServeHTTP(w, req)
+				close(done)
+			}()
+			for s.events.subscribers() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			// changes broadcast before the stream read the history have
+			// already been sent or skipped
+			s.events.publish(Event{Type: EventJoined, GameID: 321, Seq: 2})
+			s.events.publish(Event{Type: EventGuessed, GameID: 321, Seq: 4})
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+			<-done
+
+			var got []string
+			for _, line := range strings.Split(w.Body.String(), "\n") {
+				if data, ok := strings.CutPrefix(line, "data: "); ok {
+					var e Event
+					if err := json.Unmarshal([]byte(data), &e); err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, e.Type)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got events %v want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetGameHistory(t *testing.T) {
+	db := &auditDB{Connection: new(adminDB)}
+	w := adminRequest(&State{db: db}, "GET", "/admin/games/321/history")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body.String())
+	}
+	var history GameHistory
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	game := history.Game
+	if len(history.Events) != 3 || game.Host != "captainnobody1" || game.Answer != "a cat" ||
+		strings.Join(game.Players, ",") != "captainnobody1,player2" {
+		t.Errorf("the history did not add up to the game: %+v", history)
+	}
+	if len(db.entries) != 1 || db.entries[0].Action != auditGameHistory {
+		t.Errorf("expected viewing the history to be audited, got %v", db.entries)
+	}
+}
+
+// legacyHistoryDB has a game started before its history was recorded.
+type legacyHistoryDB struct {
+	adminDB
+}
+
+func (db *legacyHistoryDB) GetGameEvents(gameID, afterSeq int64) ([]database.GameEvent, error) {
+	return []database.GameEvent{
+		{GameID: gameID, Seq: 1, Type: database.GameEventGuessed, Username: "player2", Data: database.GameEventData{Text: "a dog"}},
+	}, nil
+}
+
+func TestGetGameHistoryBeforeRecording(t *testing.T) {
+	w := adminRequest(&State{db: new(legacyHistoryDB)}, "GET", "/admin/games/321/history")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body.String())
+	}
+	var history GameHistory
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if history.Game.GameID != 321 || history.Game.Host != "captainnobody1" || len(history.Events) != 1 {
+		t.Errorf("expected the stored game with the history there is, got %+v", history)
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// inviteToken checks that a request to join a private game has either the
+// game's invite code or an invite token. The token is returned so it is
+// only used up once the user has joined, it is empty when the invite code
+// was given.
+func inviteToken(w http.ResponseWriter, r *http.Request, game database.Game) (string, bool) {
+	if code := r.URL.Query().Get("code"); code != "" && game.InviteCode != "" &&
+		subtle.ConstantTimeCompare([]byte(code), []byte(game.InviteCode)) == 1 {
+		return "", true
+	}
+	token := r.URL.Query().Get("invite")
+	if token == "" {
+		http.Error(w, http.StatusText(http.StatusForbidden)+", this game is private and needs an invite to join", http.StatusForbidden)
+		return "", false
+	}
+	return token, true
+}
+
+// /game/{gameID}/invites/new?expiresIn=1h&maxUses=5
+// both parameters are optional, without them the invite never expires
+// and can be used any number of times.
+func (s State) createInvite(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	invite := database.Invite{
+		GameID:    access.Game.GameID,
+		CreatedBy: access.Username,
+	}
+	if expiresIn := r.URL.Query().Get("expiresIn"); expiresIn != "" {
+		d, err := time.ParseDuration(expiresIn)
+		if err != nil || d <= 0 {
+			http.Error(w, http.StatusText(http.StatusBadRequest)+", expiresIn parameter must be a positive duration like 1h", http.StatusBadRequest)
+			return
+		}
+		expiresAt := time.Now().Add(d)
+		invite.ExpiresAt = &expiresAt
+	}
+	if maxUses := r.URL.Query().Get("maxUses"); maxUses != "" {
+		n, err := strconv.Atoi(maxUses)
+		if err != nil || n < 1 {
+			http.Error(w, http.StatusText(http.StatusBadRequest)+", maxUses parameter must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		invite.MaxUses = n
+	}
+	var err error
+	invite.Token, err = genToken(16)
+	if err != nil {
+		s.handle500Err(w, " unable to create invite token")
+		return
+	}
+	if err := s.db.CreateInvite(invite); err != nil {
+		s.handle500Err(w, " unable to create invite")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(fmt.Sprintf("share this link so others can join %s\n", s.makeJoinPath(invite.GameID, "invite", invite.Token))))
+}
+
+// /game/{gameID}/invites
+func (s State) listInvites(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	invites, err := s.db.GetGameInvites(access.Game.GameID)
+	if err != nil {
+		s.handle500Err(w, " unable to get invites")
+		return
+	}
+	invitesJson, err := json.Marshal(invites)
+	if err != nil {
+		s.handle500Err(w, " unable to marshal invites")
+		return
+	}
+	w.Write(invitesJson)
+}
+
+// DELETE /game/{gameID}/invites/{token}
+func (s State) revokeInvite(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	token := chi.URLParam(r, "token")
+	err := s.db.RevokeInvite(access.Game.GameID, token)
+	if errors.Is(err, database.ErrInvalidInvite) {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", invite does not exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to revoke invite")
+		return
+	}
+	w.Write([]byte("invite revoked"))
+}
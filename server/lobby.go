@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/soypete/golang-cli-game/database"
+)
+
+const maxLobbyLimit = 100
+
+// LobbyEntry is a joinable game listed in the lobby.
+type LobbyEntry struct {
+	GameID     int64
+	Host       string
+	Players    int
+	MaxPlayers int
+	Phase      string
+	Category   string
+	Age        string
+	JoinLink   string
+}
+
+// LobbyPage is one page of the lobby. NextCursor is empty on the last page.
+type LobbyPage struct {
+	Games      []LobbyEntry
+	NextCursor string
+}
+
+// lobbyCursor is encoded into the opaque cursor handed to clients. The
+// sort is kept so a cursor cannot be reused with a different order.
+type lobbyCursor struct {
+	Sort string
+	database.LobbyCursor
+}
+
+func encodeLobbyCursor(c lobbyCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeLobbyCursor(s string) (lobbyCursor, error) {
+	var c lobbyCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// lobbyFilter reads the lobby filters from the query parameters.
+func lobbyFilter(r *http.Request) (database.LobbyFilter, error) {
+	q := r.URL.Query()
+	filter := database.LobbyFilter{
+		Phase:    q.Get("phase"),
+		Category: strings.ToLower(strings.TrimSpace(q.Get("category"))),
+		Host:     q.Get("host"),
+		Sort:     q.Get("sort"),
+		Limit:    20,
+	}
+	if filter.Sort == "" {
+		filter.Sort = database.SortNewest
+	}
+	if !database.ValidLobbySort(filter.Sort) {
+		return filter, fmt.Errorf("sort parameter must be one of %s, %s, %s or %s",
+			database.SortNewest, database.SortOldest, database.SortMostPlayers, database.SortFewestPlayers)
+	}
+	if filter.Phase != "" && filter.Phase != database.PhaseStarting && filter.Phase != database.PhaseInProgress {
+		return filter, fmt.Errorf("phase parameter must be %s or %s", database.PhaseStarting, database.PhaseInProgress)
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxLobbyLimit {
+			return filter, fmt.Errorf("limit parameter must be between 1 and %d", maxLobbyLimit)
+		}
+		filter.Limit = n
+	}
+	if cursor := q.Get("cursor"); cursor != "" {
+		c, err := decodeLobbyCursor(cursor)
+		if err != nil || c.Sort != filter.Sort {
+			return filter, errors.New("cursor parameter is not valid for this sort")
+		}
+		filter.After = &c.LobbyCursor
+	}
+	return filter, nil
+}
+
+// /game/lobby?phase=...&category=...&host=...&sort=...&limit=...&cursor=...&format=text
+// lists public games that can still be joined.
+func (s State) getLobby(w http.ResponseWriter, r *http.Request) {
+	filter, err := lobbyFilter(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// ask for one more game than needed to know if there is another page
+	limit := filter.Limit
+	filter.Limit++
+	games, err := s.db.ListLobbyGames(filter)
+	if err != nil {
+		s.handle500Err(w, " unable to list games")
+		return
+	}
+
+	var page LobbyPage
+	if len(games) > limit {
+		games = games[:limit]
+		last := games[len(games)-1]
+		page.NextCursor = encodeLobbyCursor(lobbyCursor{
+			Sort: filter.Sort,
+			LobbyCursor: database.LobbyCursor{
+				StartTime: last.StartTime,
+				Players:   last.Players,
+				GameID:    last.GameID,
+			},
+		})
+	}
+	for _, g := range games {
+		page.Games = append(page.Games, LobbyEntry{
+			GameID:     g.GameID,
+			Host:       g.Host,
+			Players:    g.Players,
+			MaxPlayers: g.MaxPlayers,
+			Phase:      g.Phase(),
+			Category:   g.Category,
+			Age:        time.Since(g.StartTime).Round(time.Second).String(),
+			JoinLink:   s.makeJoinPath(g.GameID, "", ""),
+		})
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writeLobbyText(w, page)
+		return
+	}
+	pageJson, err := json.Marshal(page)
+	if err != nil {
+		s.handle500Err(w, " unable to marshal lobby")
+		return
+	}
+	w.Write(pageJson)
+}
+
+// writeLobbyText writes the lobby as aligned columns for terminal clients.
+func writeLobbyText(w http.ResponseWriter, page LobbyPage) {
+	if len(page.Games) == 0 {
+		fmt.Fprintln(w, "no open games, start one at /game/start")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tHOST\tPLAYERS\tPHASE\tCATEGORY\tAGE")
+	for _, g := range page.Games {
+		category := g.Category
+		if category == "" {
+			category = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d/%d\t%s\t%s\t%s\n", g.GameID, g.Host, g.Players, g.MaxPlayers,
+			strings.ReplaceAll(g.Phase, "_", " "), category, g.Age)
+	}
+	tw.Flush()
+	if page.NextCursor != "" {
+		fmt.Fprintf(w, "more games: cursor=%s\n", page.NextCursor)
+	}
+}
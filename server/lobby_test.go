@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLobby(t *testing.T) {
+	s := State{db: new(passDB)}
+
+	get := func(t *testing.T, s State, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.getLobby(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	t.Run("pages with cursor", func(t *testing.T) {
+		w := get(t, s, "/game/lobby?limit=2")
+		if w.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+		}
+		var page LobbyPage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Games) != 2 || page.NextCursor == "" {
+			t.Fatalf("wrong page: got %d games and cursor %q", len(page.Games), page.NextCursor)
+		}
+		if page.Games[1].Phase != "in_progress" || page.Games[0].JoinLink == "" {
+			t.Errorf("wrong lobby entry: %+v", page.Games[1])
+		}
+
+		w = get(t, s, "/game/lobby?limit=2&cursor="+page.NextCursor)
+		if w.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code for next page: got %v want %v", w.Code, http.StatusOK)
+		}
+		w = get(t, s, "/game/lobby?sort=oldest&cursor="+page.NextCursor)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("cursor reused with another sort: got %v want %v", w.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("last page has no cursor", func(t *testing.T) {
+		w := get(t, s, "/game/lobby")
+		var page LobbyPage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Games) != 3 || page.NextCursor != "" {
+			t.Errorf("wrong page: got %d games and cursor %q", len(page.Games), page.NextCursor)
+		}
+	})
+
+	t.Run("text format", func(t *testing.T) {
+		w := get(t, s, "/game/lobby?format=text")
+		if body := w.Body.String(); !strings.Contains(body, "captainnobody1  1/5") || !strings.Contains(body, "in progress") {
+			t.Errorf("wrong text lobby:\n%s", body)
+		}
+	})
+
+	t.Run("category is case insensitive", func(t *testing.T) {
+		filter, err := lobbyFilter(httptest.NewRequest("GET", "/game/lobby?category=%20Animals%20", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filter.Category != "animals" {
+			t.Errorf("got category %q want animals", filter.Category)
+		}
+	})
+
+	for _, path := range []string{
+		"/game/lobby?sort=random",
+		"/game/lobby?phase=finished",
+		"/game/lobby?limit=0",
+		"/game/lobby?cursor=not-a-cursor",
+	} {
+		if w := get(t, s, path); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", path, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	if w := get(t, State{db: new(failDB)}, "/game/lobby"); w.Code != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusInternalServerError)
+	}
+}
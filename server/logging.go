@@ -0,0 +1,92 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/soypete/golang-cli-game/logging"
+)
+
+// components the server logs as, each can be given its own level
+const (
+	logHTTP        = "http"
+	logGame        = "game"
+	logAccount     = "account"
+	logMatchmaking = "matchmaking"
+	logMetrics     = "metrics"
+	logAudit       = "audit"
+)
+
+// requestLogger adds the request id to the context logs are written with
+// and logs every request once it is done, along with the user if the
+// request was authenticated. Query parameters are left out since they can
+// hold passwords and answers.
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := logging.WithRequestID(r.Context(), middleware.GetReqID(r.Context()))
+		ctx = logging.WithUserSlot(ctx)
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []any{
+			"method", r.Method,
+			"path", redactedPath(r),
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"duration", time.Since(start),
+			"remote_addr", clientIP(r),
+		}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			attrs = append(attrs, "route", rctx.RoutePattern())
+		}
+		logging.Component(logHTTP).Log(ctx, level, "request", attrs...)
+	})
+}
+
+// secretRouteParams are route parameters whose values are secrets, such as
+// invite tokens. Key based redaction cannot see them in a path.
+var secretRouteParams = map[string]bool{"token": true}
+
+// redactedPath returns the request path with the values of secret route
+// parameters masked, e.g. /game/1/invites/[REDACTED]. The router has to
+// have matched the request for its parameters to be known.
+func redactedPath(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return r.URL.Path
+	}
+	segments := strings.Split(r.URL.Path, "/")
+	for i, key := range rctx.URLParams.Keys {
+		value := rctx.URLParams.Values[i]
+		if !secretRouteParams[key] || value == "" {
+			continue
+		}
+		for j := len(segments) - 1; j >= 0; j-- {
+			if segments[j] == value {
+				segments[j] = logging.Redacted
+				break
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// handle500Err logs the error along with the request it happened in and
+// responds with a 500.
+func (s State) handle500Err(w http.ResponseWriter, err string) {
+	logging.Component(logHTTP).ErrorContext(s.context(), strings.TrimSpace(err))
+	http.Error(w, err, http.StatusInternalServerError)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
+)
+
+func TestRequestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, logging.Config{}))
+	defer slog.SetDefault(previous)
+
+	s := &State{db: new(failDB)}
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(requestLogger)
+	r.With(s.middlewareHandler).Get("/register/{username}/update", s.traced(State.updateUsername))
+	req := httptest.NewRequest("GET", "/register/captainnobody1/update?password=Sup3r-Secret-Pass", nil)
+	req.Header.Set("Authorization", getAuthHeader())
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	out := buf.String()
+	if strings.Contains(out, "Sup3r-Secret-Pass") {
+		t.Errorf("password was logged: %s", out)
+	}
+	for _, want := range []string{"request_id=", "route=/register/{username}/update", "status="} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in %s", want, out)
+		}
+	}
+
+	// the user is only known once the request is authenticated
+	buf.Reset()
+	s.db = new(passDB)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	logged := false
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, "msg=request") {
+			logged = strings.Contains(line, "user=captainnobody1")
+		}
+	}
+	if !logged {
+		t.Errorf("missing the user in the request log: %s", buf.String())
+	}
+}
+
+func TestInviteTokenNotLogged(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, logging.Config{}))
+	defer slog.SetDefault(previous)
+	exporter := inMemoryTracing(t)
+
+	r := chi.NewRouter()
+	r.Use(requestLogger)
+	r.Use(tracingMiddleware)
+	r.Route("/game/{gameID}", func(r chi.Router) {
+		r.Delete("/invites/{token}", func(w http.ResponseWriter, r *http.Request) {})
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/game/1/invites/4f3c2a1b", nil))
+
+	if out := buf.String(); strings.Contains(out, "4f3c2a1b") || !strings.Contains(out, "path=/game/1/invites/"+logging.Redacted) {
+		t.Errorf("invite token was not masked in the request log: %s", out)
+	}
+	span, ok := spanNamed(exporter.GetSpans(), "DELETE /game/{gameID}/invites/{token}")
+	if !ok {
+		t.Fatalf("no span for the route in %v", exporter.GetSpans())
+	}
+	target := ""
+	for _, attr := range span.Attributes {
+		if attr.Key == "http.target" {
+			target = attr.Value.AsString()
+		}
+	}
+	if target != "/game/1/invites/"+logging.Redacted {
+		t.Errorf("invite token was not masked in the span target %q", target)
+	}
+}
+
+func TestGameLogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logging.New(&buf, logging.Config{}).Info("game", "game", database.Game{GameID: 1, Answer: "gopher", InviteCode: "secret-code"})
+	if out := buf.String(); strings.Contains(out, "gopher") || strings.Contains(out, "secret-code") {
+		t.Errorf("game secrets were logged: %s", out)
+	}
+}
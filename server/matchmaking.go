@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
+	"github.com/soypete/golang-cli-game/matchmaking"
+	"github.com/soypete/golang-cli-game/rating"
+)
+
+// createMatchGame starts a game for players grouped by the matchmaking
+// queue, with the longest waiting player as the host.
+func (s State) createMatchGame(m matchmaking.Match) (int64, error) {
+	gameID, err := s.db.CreateGame(m.Host, database.GameSettings{Category: m.Category})
+	if err != nil {
+		return 0, err
+	}
+	for _, player := range m.Players {
+		if player == m.Host {
+			continue
+		}
+		if err := s.db.AddUserToGame(player, gameID); err != nil {
+			// the game is still usable by everyone else that was added
+			logging.Component(logMatchmaking).ErrorContext(s.context(), "unable to add player to matched game", "game_id", gameID, "username", player, "err", err)
+			continue
+		}
+	}
+	return gameID, nil
+}
+
+// ratedBand asks to be matched with players of a similar skill rating
+// instead of a skill band the players choose themselves.
+const ratedBand = "rated"
+
+// /matchmaking/join?category=...&band=...
+// band=rated matches the player with others in the same rating band.
+func (s State) joinQueue(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromHeader(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	band := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("band")))
+	if band == ratedBand {
+		// match with players of similar skill
+		ratings, err := s.userRatings([]string{username})
+		if err != nil {
+			s.handle500Err(w, " unable to get user rating")
+			return
+		}
+		band = ratedBand + " " + rating.Band(ratings[username])
+	}
+	err = s.matchmaker.Join(matchmaking.Ticket{
+		Username:  username,
+		Category:  strings.ToLower(strings.TrimSpace(r.URL.Query().Get("category"))),
+		SkillBand: band,
+	})
+	if errors.Is(err, matchmaking.ErrAlreadyQueued) {
+		http.Error(w, http.StatusText(http.StatusConflict)+", "+err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to join matchmaking queue")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(fmt.Sprintf("User %s is waiting for a match, check /matchmaking/status for your game", username)))
+}
+
+// /matchmaking/leave
+func (s State) leaveQueue(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromHeader(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if !s.matchmaker.Leave(username) {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", not in the matchmaking queue", http.StatusNotFound)
+		return
+	}
+	w.Write([]byte(fmt.Sprintf("User %s left the matchmaking queue", username)))
+}
+
+// queueStatus is the caller's place in the queue along with an overview
+// of everyone waiting.
+type queueStatus struct {
+	matchmaking.Status
+	GameLink string
+	Queue    matchmaking.QueueStats
+}
+
+// /matchmaking/status
+func (s State) getQueueStatus(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromHeader(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	status := queueStatus{
+		Status: s.matchmaker.Status(username),
+		Queue:  s.matchmaker.Stats(),
+	}
+	if status.GameID != 0 {
+		status.GameLink = s.makeGamePath(status.GameID)
+	}
+	statusJson, err := json.Marshal(status)
+	if err != nil {
+		s.handle500Err(w, " unable to marshal matchmaking status")
+		return
+	}
+	w.Write(statusJson)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/matchmaking"
+)
+
+func TestMatchmakingEndpoints(t *testing.T) {
+	s := State{db: new(passDB)}
+	s.matchmaker = matchmaking.NewQueue(matchmaking.DefaultStrategy, s.createMatchGame)
+	r := chi.NewRouter()
+	r.Get("/matchmaking/join", s.joinQueue)
+	r.Get("/matchmaking/status", s.getQueueStatus)
+	r.Get("/matchmaking/leave", s.leaveQueue)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", getAuthHeader())
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	steps := []struct {
+		path string
+		want int
+	}{
+		{"/matchmaking/join?category=Animals", http.StatusAccepted},
+		{"/matchmaking/join", http.StatusConflict},
+		{"/matchmaking/status", http.StatusOK},
+		{"/matchmaking/leave", http.StatusOK},
+		{"/matchmaking/leave", http.StatusNotFound},
+	}
+	for _, step := range steps {
+		w := serve(step.path)
+		if w.Code != step.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", step.path, w.Code, step.want)
+		}
+		if step.path != "/matchmaking/status" {
+			continue
+		}
+		var status queueStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		if !status.Queued || status.Category != "animals" || status.Queue.Waiting != 1 {
+			t.Errorf("wrong queue status: %+v", status)
+		}
+	}
+
+	gameID, err := s.createMatchGame(matchmaking.Match{Host: "captainnobody1", Players: []string{"captainnobody1", "player2"}})
+	if err != nil || gameID != 1234 {
+		t.Errorf("unable to create matched game: got %d, %v", gameID, err)
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
+)
+
+// guess outcomes counted by guessesTotal
+const (
+	guessCorrect = "correct"
+	guessClose   = "close" // correct, allowing for a typo
+	guessWrong   = "wrong"
+)
+
+var (
+	// requests are labeled with the route pattern rather than the path so
+	// game ids and usernames do not each get their own series.
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "How long requests took by route, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	playersPerGame = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_players",
+		Help:    "Number of players in games that have ended.",
+		Buckets: prometheus.LinearBuckets(1, 1, 10),
+	})
+	questionsPerGame = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_questions",
+		Help:    "Number of questions asked in games that have ended.",
+		Buckets: prometheus.LinearBuckets(0, 5, 10),
+	})
+	timeToSolve = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_time_to_solve_seconds",
+		Help:    "How long it took the players to guess the answer of solved games.",
+		Buckets: prometheus.ExponentialBuckets(30, 2, 10),
+	})
+	guessesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_guesses_total",
+		Help: "Number of guesses by outcome.",
+	}, []string{"outcome"})
+)
+
+// registerMetrics adds the request and game metrics to the registry.
+// Gauges are read when the metrics are scraped.
+func (s *State) registerMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(requestsTotal, requestDuration, playersPerGame, questionsPerGame, timeToSolve, guessesTotal, throttledTotal)
+	reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "game_active_games",
+		Help: "Number of games that have not ended.",
+	}, func() float64 {
+		n, err := s.db.CountActiveGames()
+		if err != nil {
+			logging.Component(logMetrics).Error("unable to count active games", "err", err)
+			return 0
+		}
+		return float64(n)
+	}))
+	reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "game_event_subscribers",
+		Help: "Number of clients streaming game events.",
+	}, func() float64 {
+		return float64(s.events.subscribers())
+	}))
+}
+
+// metricsMiddleware counts and times every request.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		// the pattern is only known once the router has matched the request
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+		requestsTotal.With(labels).Inc()
+		requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// observeGuess counts the guess by whether it was correct.
+func observeGuess(correct, fuzzy bool) {
+	switch {
+	case fuzzy:
+		guessesTotal.WithLabelValues(guessClose).Inc()
+	case correct:
+		guessesTotal.WithLabelValues(guessCorrect).Inc()
+	default:
+		guessesTotal.WithLabelValues(guessWrong).Inc()
+	}
+}
+
+// observeGameEnded records the size and length of a game once it has
+// been scored.
+func observeGameEnded(game database.Game, questions []database.Question) {
+	playersPerGame.Observe(float64(len(game.Players)))
+	questionsPerGame.Observe(float64(len(questions)))
+	if game.Outcome == database.OutcomeSolved && !game.StartTime.IsZero() && game.EndTime.After(game.StartTime) {
+		timeToSolve.Observe(game.EndTime.Sub(game.StartTime).Seconds())
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(metricsMiddleware)
+	r.Get("/game/{gameID}/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	r.Get("/game/{gameID}/guess", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad", http.StatusBadRequest)
+	})
+
+	ok := requestsTotal.WithLabelValues("/game/{gameID}/status", "GET", "200")
+	bad := requestsTotal.WithLabelValues("/game/{gameID}/guess", "GET", "400")
+	okBefore, badBefore := testutil.ToFloat64(ok), testutil.ToFloat64(bad)
+	for _, path := range []string{"/game/1/status", "/game/2/status", "/game/3/guess"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if got := testutil.ToFloat64(ok) - okBefore; got != 2 {
+		t.Errorf("counted %v ok requests, want 2 for the route pattern", got)
+	}
+	if got := testutil.ToFloat64(bad) - badBefore; got != 1 {
+		t.Errorf("counted %v bad requests, want 1", got)
+	}
+}
+
+func TestObserveGuess(t *testing.T) {
+	tests := []struct {
+		correct, fuzzy bool
+		outcome        string
+	}{
+		{true, false, guessCorrect},
+		{true, true, guessClose},
+		{false, false, guessWrong},
+	}
+	for _, tt := range tests {
+		before := testutil.ToFloat64(guessesTotal.WithLabelValues(tt.outcome))
+		observeGuess(tt.correct, tt.fuzzy)
+		if got := testutil.ToFloat64(guessesTotal.WithLabelValues(tt.outcome)) - before; got != 1 {
+			t.Errorf("%s guesses went up by %v, want 1", tt.outcome, got)
+		}
+	}
+}
+
+func TestEventSubscribers(t *testing.T) {
+	var nilHub *eventHub
+	if n := nilHub.subscribers(); n != 0 {
+		t.Errorf("nil hub has %d subscribers", n)
+	}
+	h := newEventHub()
+	_, stop1 := h.subscribe(1)
+	_, stop2 := h.subscribe(2)
+	if n := h.subscribers(); n != 2 {
+		t.Errorf("got %d subscribers want 2", n)
+	}
+	stop1()
+	stop2()
+	if n := h.subscribers(); n != 0 {
+		t.Errorf("got %d subscribers after unsubscribing", n)
+	}
+}
@@ -0,0 +1,394 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/matchmaking"
+)
+
+// admin actions recorded in the audit log, they all start with admin.
+// so they can be listed together.
+const (
+	auditSearchUsers   = "admin.users.search"
+	auditViewUser      = "admin.user.view"
+	auditUserHistory   = "admin.user.history"
+	auditBan           = "admin.user.ban"
+	auditUnban         = "admin.user.unban"
+	auditResetPassword = "admin.user.reset_password"
+	auditSearchGames   = "admin.games.search"
+	auditEndGame       = "admin.game.end"
+	auditGameHistory   = "admin.game.history"
+	auditServerState   = "admin.server.state"
+	auditSearchAudit   = "admin.audit.search"
+)
+
+type adminKey int
+
+const adminActorKey adminKey = 0
+
+// withAdminActor returns a context recording who is using the admin
+// routes.
+func withAdminActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, adminActorKey, actor)
+}
+
+// adminActor returns the admin making the request, or the address of an
+// allowlisted request made without credentials.
+func adminActor(r *http.Request) string {
+	actor, _ := r.Context().Value(adminActorKey).(string)
+	return actor
+}
+
+// adminAPIRoutes sets up the /admin routes used to moderate users and
+// games. They are only mounted on the admin router.
+func (s *State) adminAPIRoutes(r chi.Router) {
+	r.Get("/state", s.traced(State.getServerState)) // GET /admin/state
+	r.Get("/audit", s.traced(State.getAuditLog))    // GET /admin/audit?actor=...&action=admin.&target=...&since=2024-01-02T15:04:05Z&until=...&limit=50&offset=0
+	r.Route("/users", func(r chi.Router) {
+		r.Get("/", s.traced(State.listUsers)) // GET /admin/users?search=...&banned=true&deleted=true&limit=50&offset=0
+		r.Route("/{username}", func(r chi.Router) {
+			r.Get("/", s.traced(State.getAdminUser))                     // GET /admin/users/123
+			r.Get("/games", s.traced(State.getUserHistory))              // GET /admin/users/123/games
+			r.Post("/ban", s.traced(State.banUser))                      // POST /admin/users/123/ban?reason=...
+			r.Post("/unban", s.traced(State.unbanUser))                  // POST /admin/users/123/unban
+			r.Post("/reset-password", s.traced(State.resetUserPassword)) // POST /admin/users/123/reset-password?password=...
+		})
+	})
+	r.Route("/games", func(r chi.Router) {
+		r.Get("/", s.traced(State.listGames))                      // GET /admin/games?player=...&ended=false&limit=50&offset=0
+		r.Post("/{gameID}/end", s.traced(State.forceEndGame))      // POST /admin/games/123/end
+		r.Get("/{gameID}/history", s.traced(State.getGameHistory)) // GET /admin/games/123/history
+	})
+}
+
+// audit records the admin action in the audit log.
+func (s State) audit(r *http.Request, action, target, detail string) {
+	s.recordAudit(r, adminActor(r), action, target, detail)
+}
+
+// writeJSON writes v as the JSON response.
+func (s State) writeJSON(w http.ResponseWriter, v interface{}, what string) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		s.handle500Err(w, " unable to marshal "+what)
+		return
+	}
+	w.Write(data)
+}
+
+// pageFromQuery reads the limit and offset query parameters.
+func pageFromQuery(r *http.Request) (limit, offset int, err error) {
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			return 0, 0, errors.New("limit parameter must be a positive integer")
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, errors.New("offset parameter must be a positive integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// /admin/users?search=...&banned=true&deleted=true&limit=...&offset=...
+func (s State) listUsers(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageFromQuery(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	filter := database.UserFilter{
+		Search:         strings.TrimSpace(q.Get("search")),
+		Banned:         q.Get("banned") == "true",
+		IncludeDeleted: q.Get("deleted") == "true",
+		Limit:          limit,
+		Offset:         offset,
+	}
+	users, err := s.db.ListUsers(filter)
+	if err != nil {
+		s.handle500Err(w, " unable to list users")
+		return
+	}
+	s.audit(r, auditSearchUsers, "", r.URL.RawQuery)
+	s.writeJSON(w, users, "users")
+}
+
+// AdminUser is everything an admin sees about a user.
+type AdminUser struct {
+	database.User
+	Stats           database.UserStats
+	UsernameHistory []database.UsernameChange
+}
+
+// /admin/users/{username}
+func (s State) getAdminUser(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	user, err := s.db.GetUserIncludingDeleted(username)
+	if errors.Is(err, database.ErrUserNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", no user named "+username, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to get user")
+		return
+	}
+	view := AdminUser{User: user}
+	if view.Stats, err = s.db.GetUserStats(username); err != nil && !errors.Is(err, database.ErrUserNotFound) {
+		s.handle500Err(w, " unable to get user stats")
+		return
+	}
+	if view.UsernameHistory, err = s.db.GetUsernameHistory(username); err != nil {
+		s.handle500Err(w, " unable to get username history")
+		return
+	}
+	s.audit(r, auditViewUser, username, "")
+	s.writeJSON(w, view, "user")
+}
+
+// /admin/users/{username}/games
+// returns every game the user has hosted or played, oldest first.
+func (s State) getUserHistory(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	games, err := s.db.GetUserGames(username)
+	if err != nil {
+		s.handle500Err(w, " unable to get user games")
+		return
+	}
+	s.audit(r, auditUserHistory, username, "")
+	s.writeJSON(w, games, "user games")
+}
+
+// /admin/users/{username}/ban?reason=...
+// stops the user from logging in and takes them out of the matchmaking queue.
+func (s State) banUser(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+	err := s.db.BanUser(username, reason)
+	if errors.Is(err, database.ErrUserNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", no user named "+username, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to ban user")
+		return
+	}
+	if s.matchmaker != nil {
+		s.matchmaker.Leave(username)
+	}
+	s.audit(r, auditBan, username, reason)
+	w.Write([]byte(fmt.Sprintf("user %s banned", username)))
+}
+
+// /admin/users/{username}/unban
+func (s State) unbanUser(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	err := s.db.UnbanUser(username)
+	if errors.Is(err, database.ErrUserNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", no user named "+username, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to unban user")
+		return
+	}
+	s.audit(r, auditUnban, username, "")
+	w.Write([]byte(fmt.Sprintf("user %s unbanned", username)))
+}
+
+// /admin/users/{username}/reset-password?password=...
+// sets the password, generating one if it is not given, and clears any
+// login lockout.
+func (s State) resetUserPassword(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	password := r.URL.Query().Get("password")
+	generated := password == ""
+	if generated {
+		var err error
+		if password, err = s.genPassword(); err != nil {
+			s.handle500Err(w, " unable to generate password")
+			return
+		}
+	} else if err := s.passwordPolicy().Check(password); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := s.db.ResetPassword(username, password)
+	if errors.Is(err, database.ErrUserNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", no user named "+username, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to reset password")
+		return
+	}
+	s.limiter.clearLockout(username)
+	s.audit(r, auditResetPassword, username, "")
+	if generated {
+		w.Write([]byte(fmt.Sprintf("password of %s reset to %s", username, password)))
+		return
+	}
+	w.Write([]byte(fmt.Sprintf("password of %s reset", username)))
+}
+
+// /admin/games?player=...&ended=true|false&limit=...&offset=...
+func (s State) listGames(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageFromQuery(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := database.GameFilter{
+		Player: strings.TrimSpace(r.URL.Query().Get("player")),
+		Limit:  limit,
+		Offset: offset,
+	}
+	if v := r.URL.Query().Get("ended"); v != "" {
+		ended, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest)+", ended parameter must be true or false", http.StatusBadRequest)
+			return
+		}
+		filter.Ended = &ended
+	}
+	games, err := s.db.ListGames(filter)
+	if err != nil {
+		s.handle500Err(w, " unable to list games")
+		return
+	}
+	s.audit(r, auditSearchGames, "", r.URL.RawQuery)
+	s.writeJSON(w, games, "games")
+}
+
+// /admin/games/{gameID}/end
+// stops the game as if the host had stopped it.
+func (s State) forceEndGame(w http.ResponseWriter, r *http.Request) {
+	gameID, err := getAndValidateGameID(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, err = s.db.GetGameData(gameID)
+	if errors.Is(err, database.ErrGameNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", no game with id "+strconv.FormatInt(gameID, 10), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to get game")
+		return
+	}
+	err = s.endGame(gameID, database.OutcomeStopped, "")
+	if errors.Is(err, database.ErrGameEnded) {
+		http.Error(w, http.StatusText(http.StatusConflict)+", the game has already ended", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to end game")
+		return
+	}
+	s.audit(r, auditEndGame, strconv.FormatInt(gameID, 10), "")
+	w.Write([]byte(fmt.Sprintf("game %d ended", gameID)))
+}
+
+// WorkerStatus is how a background worker has been running.
+type WorkerStatus struct {
+	Name         string
+	Interval     string
+	Runs         int64
+	LastRun      time.Time
+	LastDuration string
+}
+
+// workerStatus keeps track of the background workers. A nil workerStatus
+// tracks nothing.
+type workerStatus struct {
+	mu      sync.Mutex
+	workers map[string]*WorkerStatus
+}
+
+func newWorkerStatus() *workerStatus {
+	return &workerStatus{workers: make(map[string]*WorkerStatus)}
+}
+
+// ran records a run of the worker that started at start.
+func (ws *workerStatus) ran(name string, interval time.Duration, start time.Time) {
+	if ws == nil {
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	status, ok := ws.workers[name]
+	if !ok {
+		status = &WorkerStatus{Name: name, Interval: interval.String()}
+		ws.workers[name] = status
+	}
+	status.Runs++
+	status.LastRun = start
+	status.LastDuration = time.Since(start).String()
+}
+
+// list returns the status of every worker that has run, by name.
+func (ws *workerStatus) list() []WorkerStatus {
+	if ws == nil {
+		return nil
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	list := make([]WorkerStatus, 0, len(ws.workers))
+	for _, status := range ws.workers {
+		list = append(list, *status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// ServerState is an overview of what the server is doing.
+type ServerState struct {
+	StartedAt     time.Time
+	Uptime        string
+	Goroutines    int
+	ActiveGames   int
+	EventStreams  int
+	StreamsByGame map[int64]int
+	Matchmaking   matchmaking.QueueStats
+	Workers       []WorkerStatus
+}
+
+// /admin/state
+func (s State) getServerState(w http.ResponseWriter, r *http.Request) {
+	activeGames, err := s.db.CountActiveGames()
+	if err != nil {
+		s.handle500Err(w, " unable to count active games")
+		return
+	}
+	state := ServerState{
+		StartedAt:     s.startedAt,
+		Goroutines:    runtime.NumGoroutine(),
+		ActiveGames:   activeGames,
+		EventStreams:  s.events.subscribers(),
+		StreamsByGame: s.events.subscribersByGame(),
+		Workers:       s.workers.list(),
+	}
+	if !s.startedAt.IsZero() {
+		state.Uptime = time.Since(s.startedAt).Round(time.Second).String()
+	}
+	if s.matchmaker != nil {
+		state.Matchmaking = s.matchmaker.Stats()
+	}
+	s.audit(r, auditServerState, "", "")
+	s.writeJSON(w, state, "server state")
+}
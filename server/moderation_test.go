@@ -0,0 +1,202 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// auditDB records the audit log of the wrapped connection in memory.
+type auditDB struct {
+	database.Connection
+	entries []database.AuditEntry
+}
+
+func (db *auditDB) RecordAudit(entry database.AuditEntry) error {
+	db.entries = append(db.entries, entry)
+	return nil
+}
+
+// bannedDB says every user is banned.
+type bannedDB struct {
+	passDB
+}
+
+func (db *bannedDB) CheckUserValid(username, password string) (bool, error) {
+	return false, database.ErrUserBanned
+}
+
+func adminRequest(s *State, method, target string) *httptest.ResponseRecorder {
+	// the admin is already logged in so only the request itself is audited
+	if s.sessions == nil {
+		s.sessions = newSessionTracker()
+		s.sessions.start("admin:captainnobody1", time.Now())
+	}
+	router := s.adminRoutes(prometheus.NewRegistry())
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = "203.0.113.9:5000"
+	req.Header.Set("Authorization", getAuthHeader())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAPI(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+		action string
+	}{
+		{"list users", "GET", "/admin/users?search=captain", http.StatusOK, auditSearchUsers},
+		{"bad limit", "GET", "/admin/users?limit=-1", http.StatusBadRequest, ""},
+		{"view user", "GET", "/admin/users/player2", http.StatusOK, auditViewUser},
+		{"user history", "GET", "/admin/users/player2/games", http.StatusOK, auditUserHistory},
+		{"ban", "POST", "/admin/users/player2/ban?reason=spam", http.StatusOK, auditBan},
+		{"unban", "POST", "/admin/users/player2/unban", http.StatusOK, auditUnban},
+		{"reset password", "POST", "/admin/users/player2/reset-password", http.StatusOK, auditResetPassword},
+		{"weak password", "POST", "/admin/users/player2/reset-password?password=abc", http.StatusBadRequest, ""},
+		{"list games", "GET", "/admin/games?ended=false", http.StatusOK, auditSearchGames},
+		{"bad ended", "GET", "/admin/games?ended=maybe", http.StatusBadRequest, ""},
+		{"end game", "POST", "/admin/games/321/end", http.StatusOK, auditEndGame},
+		{"server state", "GET", "/admin/state", http.StatusOK, auditServerState},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &auditDB{Connection: new(adminDB)}
+			s := &State{db: db, events: newEventHub()}
+			w := adminRequest(s, tt.method, tt.target)
+			if w.Code != tt.want {
+				t.Fatalf("got status %d want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.action == "" {
+				if len(db.entries) != 0 {
+					t.Errorf("expected no audit entries, got %v", db.entries)
+				}
+				return
+			}
+			if len(db.entries) != 1 {
+				t.Fatalf("expected one audit entry, got %v", db.entries)
+			}
+			entry := db.entries[0]
+			if entry.Action != tt.action || entry.Actor != "captainnobody1" {
+				t.Errorf("got audit entry %+v want action %s by captainnobody1", entry, tt.action)
+			}
+		})
+	}
+}
+
+// missingGameDB has no games.
+type missingGameDB struct {
+	adminDB
+}
+
+func (db *missingGameDB) GetGameData(gameID int64) (database.Game, error) {
+	return database.Game{}, database.ErrGameNotFound
+}
+
+// endedGameDB has a game that has already ended.
+type endedGameDB struct {
+	adminDB
+}
+
+func (db *endedGameDB) EndGame(gameID int64, outcome, winner string) error {
+	return database.ErrGameEnded
+}
+
+func TestForceEndGameErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		db   database.Connection
+		want int
+	}{
+		{"missing", new(missingGameDB), http.StatusNotFound},
+		{"already ended", new(endedGameDB), http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &auditDB{Connection: tt.db}
+			w := adminRequest(&State{db: db}, "POST", "/admin/games/321/end")
+			if w.Code != tt.want {
+				t.Fatalf("got status %d want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if len(db.entries) != 0 {
+				t.Errorf("expected no audit entries, got %v", db.entries)
+			}
+		})
+	}
+}
+
+func TestResetPasswordNotLogged(t *testing.T) {
+	db := &auditDB{Connection: new(adminDB)}
+	s := &State{db: db}
+	password := "Correct-Horse-Battery-9"
+	w := adminRequest(s, "POST", "/admin/users/player2/reset-password?password="+password)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), password) {
+		t.Error("a password chosen by the admin should not be echoed back")
+	}
+	for _, entry := range db.entries {
+		if strings.Contains(entry.Detail, password) {
+			t.Errorf("the audit log contains the password: %+v", entry)
+		}
+	}
+}
+
+func TestServerState(t *testing.T) {
+	s := &State{db: &auditDB{Connection: new(adminDB)}, events: newEventHub(), workers: newWorkerStatus()}
+	_, cancel := s.events.subscribe(321)
+	defer cancel()
+	w := adminRequest(s, "GET", "/admin/state")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body.String())
+	}
+	var state ServerState
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.EventStreams != 1 || state.StreamsByGame[321] != 1 {
+		t.Errorf("expected one stream for game 321, got %+v", state)
+	}
+}
+
+func TestBannedUserRefused(t *testing.T) {
+	s := &State{db: new(bannedDB)}
+	req := httptest.NewRequest("GET", "/game/start", nil)
+	req.Header.Set("Authorization", getAuthHeader())
+	w := httptest.NewRecorder()
+	if s.authMiddleware(w, req) {
+		t.Fatal("a banned user should not be authenticated")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Errorf("got status %d want %d: %s", w.Code, http.StatusForbidden, w.Body.String())
+	}
+}
+
+// unanonymizedDB has deleted player2's account without anonymizing it yet.
+type unanonymizedDB struct {
+	adminDB
+}
+
+func (db *unanonymizedDB) GetUserData(username string) (database.User, error) {
+	if username == "player2" {
+		return database.User{}, database.ErrUserNotFound
+	}
+	return db.adminDB.GetUserData(username)
+}
+
+func TestAdminViewDeletedUser(t *testing.T) {
+	s := &State{db: new(unanonymizedDB)}
+	if w := adminRequest(s, "GET", "/admin/users/player2"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "player2") {
+		t.Errorf("got %d %s, want the deleted user", w.Code, w.Body.String())
+	}
+}
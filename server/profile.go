@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// Profile limits.
+const (
+	maxDisplayName = 50
+	maxBio         = 280
+	maxAvatarRunes = 8 // enough for emoji joined with modifiers, e.g. 👩🏽‍🚀
+)
+
+// languagePattern matches language tags such as en, pt-BR or zh-Hant.
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// Profile is a user's profile as returned by /users/{username}. Other users
+// only see what the privacy settings allow, and a private profile shows
+// nothing but the username.
+type Profile struct {
+	Username     string
+	DisplayName  string
+	Avatar       string
+	Bio          string
+	Language     string
+	Private      bool
+	LastSeen     *time.Time
+	Stats        *StatsResponse
+	Achievements []UnlockedAchievement
+	// only included when users view their own profile
+	Privacy   *database.Privacy
+	CreatedAt *time.Time
+}
+
+// canSeeStats reports whether the viewer may see the user's stats.
+func canSeeStats(user database.User, viewer string) bool {
+	return user.Username == viewer || (user.Public && user.ShowStats)
+}
+
+// buildProfile returns the view of the user's profile that the viewer is
+// allowed to see.
+func (s State) buildProfile(user database.User, viewer string) (Profile, error) {
+	self := user.Username == viewer
+	profile := Profile{Username: user.Username}
+	if !self && !user.Public {
+		profile.Private = true
+		return profile, nil
+	}
+	profile.DisplayName = user.DisplayName
+	profile.Avatar = user.Avatar
+	profile.Bio = user.Bio
+	profile.Language = user.Language
+	if self || user.ShowLastSeen {
+		profile.LastSeen = user.LastSeen
+	}
+	if self {
+		privacy, created := user.Privacy, user.CreatedAt
+		profile.Privacy = &privacy
+		profile.CreatedAt = &created
+	}
+	if canSeeStats(user, viewer) {
+		stats, err := s.db.GetUserStats(user.Username)
+		if err != nil {
+			return profile, err
+		}
+		profile.Stats = &StatsResponse{
+			UserStats:               stats,
+			AverageQuestionsToSolve: stats.AverageQuestionsToSolve(),
+			GuessAccuracy:           stats.GuessAccuracy(),
+		}
+		profile.Achievements, err = s.userAchievements(user.Username)
+		if err != nil {
+			return profile, err
+		}
+	}
+	return profile, nil
+}
+
+// writeProfile writes the view of the user's profile the viewer may see.
+func (s State) writeProfile(w http.ResponseWriter, user database.User, viewer string) {
+	profile, err := s.buildProfile(user, viewer)
+	if err != nil {
+		s.handle500Err(w, " unable to get profile")
+		return
+	}
+	profileJson, err := json.Marshal(profile)
+	if err != nil {
+		s.handle500Err(w, " unable to marshal profile")
+		return
+	}
+	w.Write(profileJson)
+}
+
+// validateProfileUpdate checks the new profile values and trims the
+// text fields.
+func validateProfileUpdate(u *database.ProfileUpdate) error {
+	if u.DisplayName != nil {
+		name := strings.TrimSpace(*u.DisplayName)
+		if utf8.RuneCountInString(name) > maxDisplayName {
+			return fmt.Errorf("DisplayName can be at most %d characters", maxDisplayName)
+		}
+		u.DisplayName = &name
+	}
+	if u.Bio != nil {
+		bio := strings.TrimSpace(*u.Bio)
+		if utf8.RuneCountInString(bio) > maxBio {
+			return fmt.Errorf("Bio can be at most %d characters", maxBio)
+		}
+		u.Bio = &bio
+	}
+	if u.Avatar != nil {
+		avatar := strings.TrimSpace(*u.Avatar)
+		if avatar != "" && !isEmoji(avatar) {
+			return errors.New("Avatar must be an emoji")
+		}
+		u.Avatar = &avatar
+	}
+	if u.Language != nil && !languagePattern.MatchString(*u.Language) {
+		return errors.New("Language must be a language tag such as en or pt-BR")
+	}
+	return nil
+}
+
+// isEmoji reports whether s looks like a single emoji: symbols, possibly
+// joined together or changed with skin tone and presentation modifiers.
+func isEmoji(s string) bool {
+	if utf8.RuneCountInString(s) > maxAvatarRunes {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(s)
+	if !unicode.Is(unicode.So, first) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.In(r, unicode.So, unicode.Sk, unicode.Mn, unicode.Cf) {
+			return false
+		}
+	}
+	return true
+}
+
+// /users/{username}
+// returns the user's profile, with everything for the user themselves and
+// what the privacy settings allow for everyone else.
+func (s State) getProfile(w http.ResponseWriter, r *http.Request) {
+	viewer, err := usernameFromHeader(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	username := chi.URLParam(r, "username")
+	user, err := s.db.GetUserData(username)
+	if errors.Is(err, database.ErrUserNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", no user named "+username, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to get user")
+		return
+	}
+	s.writeProfile(w, user, viewer)
+}
+
+// PATCH /users/{username} {"DisplayName": "...", "Avatar": "🦫", "Public": false, ...}
+// changes the fields of the profile that are in the body. Users can only
+// change their own profile.
+func (s State) updateProfile(w http.ResponseWriter, r *http.Request) {
+	username, err := getAndValidateUsername(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var update database.ProfileUpdate
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<14)).Decode(&update); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", body must be a JSON object of the profile fields to change", http.StatusBadRequest)
+		return
+	}
+	if err := validateProfileUpdate(&update); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := s.db.UpdateProfile(username, update)
+	if err != nil {
+		s.handle500Err(w, " unable to update profile")
+		return
+	}
+	s.writeProfile(w, user, username)
+}
+
+// statsVisible writes an error and returns false if the caller may not
+// see the stats of the user in the path.
+func (s State) statsVisible(w http.ResponseWriter, r *http.Request) bool {
+	viewer, err := usernameFromHeader(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return false
+	}
+	username := chi.URLParam(r, "username")
+	user, err := s.db.GetUserData(username)
+	if errors.Is(err, database.ErrUserNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", no user named "+username, http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to get user")
+		return false
+	}
+	if !canSeeStats(user, viewer) {
+		http.Error(w, http.StatusText(http.StatusForbidden)+", "+username+" keeps their stats private", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// privateDB has a user with a private profile and one that hides their stats.
+type privateDB struct {
+	passDB
+}
+
+func (db *privateDB) GetUserData(username string) (database.User, error) {
+	user, _ := db.passDB.GetUserData(username)
+	switch username {
+	case "hermit":
+		user.Public = false
+	case "shy":
+		user.ShowStats = false
+		user.ShowLastSeen = false
+	}
+	return user, nil
+}
+
+func profileRouter(s State) *chi.Mux {
+	r := chi.NewRouter()
+	r.Route("/users/{username}", func(r chi.Router) {
+		r.Get("/", s.getProfile)
+		r.Patch("/", s.updateProfile)
+		r.Get("/stats", s.getUserStats)
+	})
+	return r
+}
+
+func TestProfileViews(t *testing.T) {
+	r := profileRouter(State{db: new(privateDB)})
+	tests := []struct {
+		name        string
+		path        string
+		wantPrivate bool
+		wantStats   bool
+		wantSelf    bool
+	}{
+		{"self", "/users/captainnobody1", false, true, true},
+		{"public", "/users/player2", false, true, false},
+		{"private", "/users/hermit", true, false, false},
+		{"hidden stats", "/users/shy", false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Authorization", getAuthHeader())
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+			}
+			var profile Profile
+			if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+				t.Fatal(err)
+			}
+			if profile.Private != tt.wantPrivate || (profile.Stats != nil) != tt.wantStats || (profile.Privacy != nil) != tt.wantSelf {
+				t.Errorf("wrong profile view: %s", w.Body.String())
+			}
+			if tt.wantPrivate && profile.DisplayName != "" {
+				t.Errorf("private profile shows display name %q", profile.DisplayName)
+			}
+		})
+	}
+}
+
+func TestStatsPrivacy(t *testing.T) {
+	r := profileRouter(State{db: new(privateDB)})
+	for path, want := range map[string]int{
+		"/users/captainnobody1/stats": http.StatusOK,
+		"/users/player2/stats":        http.StatusOK,
+		"/users/shy/stats":            http.StatusForbidden,
+		"/users/hermit/stats":         http.StatusForbidden,
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", getAuthHeader())
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", path, w.Code, want)
+		}
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	tests := []struct {
+		name string
+		db   database.Connection
+		path string
+		body string
+		want int
+	}{
+		{"pass", new(passDB), "/users/captainnobody1", `{"DisplayName": " Captain ", "Avatar": "🦫", "Language": "pt-BR", "Public": false}`, http.StatusOK},
+		{"skin tone avatar", new(passDB), "/users/captainnobody1", `{"Avatar": "👩🏽‍🚀"}`, http.StatusOK},
+		{"someone else", new(passDB), "/users/player2", `{"DisplayName": "Player Two"}`, http.StatusBadRequest},
+		{"not json", new(passDB), "/users/captainnobody1", `DisplayName=Captain`, http.StatusBadRequest},
+		{"avatar not emoji", new(passDB), "/users/captainnobody1", `{"Avatar": "cat"}`, http.StatusBadRequest},
+		{"bio too long", new(passDB), "/users/captainnobody1", `{"Bio": "` + strings.Repeat("a", maxBio+1) + `"}`, http.StatusBadRequest},
+		{"bad language", new(passDB), "/users/captainnobody1", `{"Language": "English"}`, http.StatusBadRequest},
+		{"db error", new(failDB), "/users/captainnobody1", `{"DisplayName": "Captain"}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := profileRouter(State{db: tt.db})
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("PATCH", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", getAuthHeader())
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.name != "pass" {
+				return
+			}
+			var profile Profile
+			if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+				t.Fatal(err)
+			}
+			if profile.DisplayName != "Captain" || profile.Privacy == nil || profile.Privacy.Public {
+				t.Errorf("profile not updated: %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// forwardedIPKey holds the client address a trusted proxy forwarded the
+// request for.
+type forwardedIPKey struct{}
+
+// realIP records the client address that trusted proxies forwarded the
+// request for. X-Forwarded-For and X-Real-IP can be set by anyone so they
+// are ignored unless the request came from one of the TrustedProxies.
+func (s *State) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := s.forwardedIP(r); ip != "" {
+			r = r.WithContext(context.WithValue(r.Context(), forwardedIPKey{}, ip))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedIP returns the client address forwarded by trusted proxies, or
+// an empty string if the request did not come through one. Proxies append
+// the address they got the request from, so the client is the nearest
+// address that is not one of the trusted proxies.
+func (s *State) forwardedIP(r *http.Request) string {
+	peer, ok := peerAddr(r)
+	if !ok || !containsAddr(s.TrustedProxies, peer) {
+		return ""
+	}
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	if len(hops) == 0 {
+		hops = []string{r.Header.Get("X-Real-IP")}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return ""
+		}
+		addr = addr.Unmap()
+		if !containsAddr(s.TrustedProxies, addr) {
+			return addr.String()
+		}
+	}
+	return ""
+}
+
+// clientIP returns the address of the client, which is the one a trusted
+// proxy forwarded the request for or else the address that connected.
+func clientIP(r *http.Request) string {
+	if ip := forwardedFor(r); ip != "" {
+		return ip
+	}
+	return peerIP(r)
+}
+
+// forwardedFor returns the client address a trusted proxy forwarded the
+// request for, or an empty string if it did not come through one.
+func forwardedFor(r *http.Request) string {
+	ip, _ := r.Context().Value(forwardedIPKey{}).(string)
+	return ip
+}
+
+// peerIP returns the address that connected, which may be a proxy.
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// peerAddr parses the address that connected.
+func peerAddr(r *http.Request) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(peerIP(r))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// containsAddr reports whether the address is in any of the prefixes.
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		want       string
+	}{
+		{"direct", "198.51.100.1:1234", "", "", "198.51.100.1"},
+		{"spoofed header is ignored", "198.51.100.1:1234", "203.0.113.7", "", "198.51.100.1"},
+		{"spoofed real ip is ignored", "198.51.100.1:1234", "", "203.0.113.7", "198.51.100.1"},
+		{"trusted proxy", "10.0.0.5:1234", "203.0.113.7", "", "203.0.113.7"},
+		{"client prepends a fake hop", "10.0.0.5:1234", "192.0.2.1, 203.0.113.7", "", "203.0.113.7"},
+		{"chain of trusted proxies", "10.0.0.5:1234", "203.0.113.7, 10.0.0.6", "", "203.0.113.7"},
+		{"trusted proxy with real ip", "10.0.0.5:1234", "", "203.0.113.7", "203.0.113.7"},
+		{"trusted proxy without a header", "10.0.0.5:1234", "", "", "10.0.0.5"},
+		{"invalid hop", "10.0.0.5:1234", "not an ip", "", "10.0.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &State{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+			var got string
+			handler := s.realIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("got %s want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/soypete/golang-cli-game/logging"
+	"github.com/soypete/golang-cli-game/ratelimit"
+)
+
+// route classes, each with their own rate limits
+const (
+	classAuth         = "auth"          // registering and restoring accounts
+	classGameCreation = "game_creation" // starting games and joining the matchmaking queue
+	classGameAction   = "game_action"   // everything done in a game
+	classLogin        = "login"         // failed logins, only used for metrics
+)
+
+// RateLimits are the limits on how often each IP address and each user
+// can use a class of routes, along with how failed logins are locked out.
+type RateLimits struct {
+	Auth         ratelimit.Limit
+	GameCreation ratelimit.Limit
+	GameAction   ratelimit.Limit
+	// a user is locked out after LockoutThreshold failed logins in a row
+	// and an IP address after IPLockoutThreshold, for LockoutBase which
+	// doubles with every failure after that up to LockoutMax.
+	LockoutThreshold   int
+	IPLockoutThreshold int
+	LockoutBase        time.Duration
+	LockoutMax         time.Duration
+}
+
+// DefaultRateLimits are used by NewState.
+var DefaultRateLimits = RateLimits{
+	Auth:               ratelimit.Per(10, time.Minute),
+	GameCreation:       ratelimit.Per(5, time.Minute),
+	GameAction:         ratelimit.Per(120, time.Minute),
+	LockoutThreshold:   5,
+	IPLockoutThreshold: 20,
+	LockoutBase:        30 * time.Second,
+	LockoutMax:         time.Hour,
+}
+
+// throttledTotal counts requests that were turned away, by route class
+// and whether the IP address or user hit the limit.
+var throttledTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_throttled_requests_total",
+	Help: "Number of requests rejected by rate limits or login lockouts.",
+}, []string{"class", "key"})
+
+// rateLimiter holds the token buckets and login lockouts of the server.
+// A nil rateLimiter allows everything.
+type rateLimiter struct {
+	byIP        map[string]*ratelimit.Limiter
+	byUser      map[string]*ratelimit.Limiter
+	userLockout *ratelimit.Lockout
+	ipLockout   *ratelimit.Lockout
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	classes := map[string]ratelimit.Limit{
+		classAuth:         limits.Auth,
+		classGameCreation: limits.GameCreation,
+		classGameAction:   limits.GameAction,
+	}
+	l := &rateLimiter{
+		byIP:        make(map[string]*ratelimit.Limiter),
+		byUser:      make(map[string]*ratelimit.Limiter),
+		userLockout: ratelimit.NewLockout(limits.LockoutThreshold, limits.LockoutBase, limits.LockoutMax),
+		ipLockout:   ratelimit.NewLockout(limits.IPLockoutThreshold, limits.LockoutBase, limits.LockoutMax),
+	}
+	for class, limit := range classes {
+		l.byIP[class] = ratelimit.NewLimiter(limit)
+		l.byUser[class] = ratelimit.NewLimiter(limit)
+	}
+	return l
+}
+
+// allow takes a token for the request from the buckets of its IP address
+// and user, returning which of them ran out and for how long.
+func (l *rateLimiter) allow(class string, r *http.Request) (string, time.Duration) {
+	if l == nil {
+		return "", 0
+	}
+	if ok, wait := l.byIP[class].Allow(clientIP(r)); !ok {
+		return "ip", wait
+	}
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		if ok, wait := l.byUser[class].Allow(username); !ok {
+			return "user", wait
+		}
+	}
+	return "", 0
+}
+
+// lockedOut reports whether logins as the user or from the request's IP
+// address are locked out.
+func (l *rateLimiter) lockedOut(r *http.Request, username string) (bool, time.Duration) {
+	if l == nil {
+		return false, 0
+	}
+	if locked, wait := l.userLockout.Locked(username); locked {
+		return true, wait
+	}
+	return l.ipLockout.Locked(clientIP(r))
+}
+
+// loginResult records whether a login succeeded. Only the user's failures
+// are cleared on success so logging in to another account does not reset
+// an IP address that is guessing passwords.
+func (l *rateLimiter) loginResult(ctx context.Context, r *http.Request, username string, ok bool) {
+	if l == nil {
+		return
+	}
+	if ok {
+		l.userLockout.Succeed(username)
+		return
+	}
+	wait := l.userLockout.Fail(username)
+	if ipWait := l.ipLockout.Fail(clientIP(r)); ipWait > wait {
+		wait = ipWait
+	}
+	if wait > 0 {
+		logging.Component(logAccount).WarnContext(ctx, "login locked out", "username", username, "ip", clientIP(r), "for", wait)
+	}
+}
+
+// clearLockout lets the user log in again straight away, e.g. after an
+// admin reset their password.
+func (l *rateLimiter) clearLockout(username string) {
+	if l == nil {
+		return
+	}
+	l.userLockout.Succeed(username)
+}
+
+// prune forgets buckets and lockouts that have been idle for a while.
+func (l *rateLimiter) prune(idle time.Duration) {
+	if l == nil {
+		return
+	}
+	for _, limiters := range []map[string]*ratelimit.Limiter{l.byIP, l.byUser} {
+		for _, limiter := range limiters {
+			limiter.Prune(idle)
+		}
+	}
+	l.userLockout.Prune(idle)
+	l.ipLockout.Prune(idle)
+}
+
+// tooManyRequests tells the client to slow down and when to try again.
+func tooManyRequests(w http.ResponseWriter, class, key string, wait time.Duration) {
+	throttledTotal.WithLabelValues(class, key).Inc()
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, fmt.Sprintf("%s, try again in %ds", http.StatusText(http.StatusTooManyRequests), seconds), http.StatusTooManyRequests)
+}
+
+// rateLimit returns middleware that limits how often each IP address and
+// user can use the class of routes.
+func (s *State) rateLimit(class string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key, wait := s.limiter.allow(class, r); key != "" {
+				tooManyRequests(w, class, key, wait)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// loginLocked checks the user is not locked out after failing to log in
+// too many times, writing a 429 if they are.
+func (s State) loginLocked(w http.ResponseWriter, r *http.Request, username string) bool {
+	locked, wait := s.limiter.lockedOut(r, username)
+	if locked {
+		tooManyRequests(w, classLogin, "lockout", wait)
+	}
+	return locked
+}
+
+// runRateLimitCleanup forgets idle rate limit buckets and lockouts until
+// the context is cancelled.
+func (s State) runRateLimitCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			s.limiter.prune(10 * time.Minute)
+			s.sessions.prune(start)
+			s.workers.ran("rateLimitCleanup", interval, start)
+		}
+	}
+}
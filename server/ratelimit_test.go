@@ -0,0 +1,154 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/soypete/golang-cli-game/ratelimit"
+)
+
+// wrongPasswordDB rejects every password.
+type wrongPasswordDB struct {
+	passDB
+}
+
+func (db *wrongPasswordDB) CheckUserValid(string, string) (bool, error) {
+	return false, nil
+}
+
+// brokenLoginDB cannot check passwords.
+type brokenLoginDB struct {
+	passDB
+}
+
+func (db *brokenLoginDB) CheckUserValid(username, password string) (bool, error) {
+	return false, fmt.Errorf("failed to get user %s from db", username)
+}
+
+func TestRateLimit(t *testing.T) {
+	limits := DefaultRateLimits
+	limits.GameCreation = ratelimit.Per(2, time.Minute)
+	s := &State{db: new(passDB), limiter: newRateLimiter(limits)}
+	r := chi.NewRouter()
+	r.With(s.rateLimit(classGameCreation)).Get("/game/start", func(w http.ResponseWriter, r *http.Request) {})
+
+	request := func(ip, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/game/start", nil)
+		req.RemoteAddr = ip + ":1234"
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	throttled := throttledTotal.WithLabelValues(classGameCreation, "ip")
+	before := testutil.ToFloat64(throttled)
+	for i := 0; i < 2; i++ {
+		if w := request("198.51.100.1", ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d got status %d", i, w.Code)
+		}
+	}
+	w := request("198.51.100.1", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "30" {
+		t.Errorf("got Retry-After %q want 30", w.Header().Get("Retry-After"))
+	}
+	if got := testutil.ToFloat64(throttled) - before; got != 1 {
+		t.Errorf("counted %v throttled requests want 1", got)
+	}
+
+	// the same user is limited across IP addresses
+	for i, ip := range []string{"198.51.100.2", "198.51.100.3"} {
+		if w := request(ip, getAuthHeader()); w.Code != http.StatusOK {
+			t.Fatalf("user request %d got status %d", i, w.Code)
+		}
+	}
+	if w := request("198.51.100.4", getAuthHeader()); w.Code != http.StatusTooManyRequests {
+		t.Errorf("got status %d want the user to be limited", w.Code)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	limits := DefaultRateLimits
+	limits.LockoutThreshold = 3
+	s := &State{db: new(wrongPasswordDB), limiter: newRateLimiter(limits)}
+	r := chi.NewRouter()
+	r.With(s.middlewareHandler).Get("/users/{username}", func(w http.ResponseWriter, r *http.Request) {})
+
+	login := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/users/captainnobody1", nil)
+		req.Header.Set("Authorization", getAuthHeader())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	for i := 0; i < 3; i++ {
+		if w := login(); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d got status %d want 401", i, w.Code)
+		}
+	}
+	// even the right password is turned away while locked out
+	s.db = new(passDB)
+	w := login()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "30" {
+		t.Errorf("got Retry-After %q want 30", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestLoginLockoutIgnoresDBErrors(t *testing.T) {
+	limits := DefaultRateLimits
+	limits.LockoutThreshold = 1
+	s := &State{db: new(brokenLoginDB), limiter: newRateLimiter(limits)}
+	r := chi.NewRouter()
+	r.With(s.middlewareHandler).Get("/users/{username}", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest("GET", "/users/captainnobody1", nil)
+	req.Header.Set("Authorization", getAuthHeader())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d want 500", w.Code)
+	}
+	// the user did not fail to log in so they are not locked out
+	if locked, _ := s.limiter.lockedOut(req, "captainnobody1"); locked {
+		t.Error("a database error locked the user out")
+	}
+}
+
+func TestIPLockoutIgnoresSpoofedHeaders(t *testing.T) {
+	limits := DefaultRateLimits
+	limits.IPLockoutThreshold = 3
+	s := &State{db: new(wrongPasswordDB), limiter: newRateLimiter(limits)}
+	r := chi.NewRouter()
+	r.Use(s.realIP)
+	r.With(s.middlewareHandler).Get("/users/{username}", func(w http.ResponseWriter, r *http.Request) {})
+
+	// a new forwarded address and username on every attempt
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest("GET", "/users/captainnobody1", nil)
+		req.RemoteAddr = "198.51.100.1:1234"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i))
+		req.SetBasicAuth(fmt.Sprintf("user%d", i), "guess")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		want := http.StatusUnauthorized
+		if i == 3 {
+			want = http.StatusTooManyRequests
+		}
+		if w.Code != want {
+			t.Fatalf("attempt %d got status %d want %d", i, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/rating"
+)
+
+// RatingResponse is a user's current skill rating and how it got there.
+type RatingResponse struct {
+	Username  string
+	Rating    float64
+	Deviation float64
+	Band      string // the matchmaking skill band for the rating
+	History   []database.RatingChange
+}
+
+// currentRating returns the user's rating, made less certain by any time
+// since they last played. Users without a rating get the default one.
+func currentRating(r database.UserRating, now time.Time) rating.Rating {
+	if r.RatedAt == nil {
+		return rating.New()
+	}
+	return rating.Rating{Rating: r.Rating, Deviation: r.Deviation}.Decay(now.Sub(*r.RatedAt))
+}
+
+// userRatings returns the current rating of each user.
+func (s State) userRatings(usernames []string) (map[string]rating.Rating, error) {
+	stored, err := s.db.GetRatings(usernames)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ratings := make(map[string]rating.Rating, len(usernames))
+	for _, username := range usernames {
+		ratings[username] = rating.New()
+	}
+	for _, r := range stored {
+		ratings[r.Username] = currentRating(r, now)
+	}
+	return ratings, nil
+}
+
+// rateGame updates the skill rating of everyone in the game from where
+// they finished. Games nobody won, e.g. because the host stopped them,
+// are not rated.
+func (s State) rateGame(game database.Game, standings []database.Standing) error {
+	var won bool
+	usernames := make([]string, 0, len(standings))
+	for _, st := range standings {
+		won = won || st.Won
+		usernames = append(usernames, st.Username)
+	}
+	if !won || len(standings) < 2 {
+		return nil
+	}
+	current, err := s.userRatings(usernames)
+	if err != nil {
+		return err
+	}
+	placings := make([]rating.Placing, 0, len(standings))
+	for _, st := range standings {
+		placings = append(placings, rating.Placing{Player: st.Username, Rank: st.Rank, Rating: current[st.Username]})
+	}
+	updated := rating.RateGame(placings)
+	ratings := make([]database.UserRating, 0, len(updated))
+	for _, username := range usernames {
+		ratings = append(ratings, database.UserRating{
+			Username:  username,
+			Rating:    updated[username].Rating,
+			Deviation: updated[username].Deviation,
+		})
+	}
+	return s.db.SaveRatings(game.GameID, ratings)
+}
+
+// /users/{username}/rating
+// returns the user's skill rating and its history.
+func (s State) getUserRating(w http.ResponseWriter, r *http.Request) {
+	if !s.statsVisible(w, r) {
+		return
+	}
+	username := chi.URLParam(r, "username")
+	ratings, err := s.userRatings([]string{username})
+	if err != nil {
+		s.handle500Err(w, " unable to get user rating")
+		return
+	}
+	history, err := s.db.GetRatingHistory(username)
+	if err != nil {
+		s.handle500Err(w, " unable to get rating history")
+		return
+	}
+	current := ratings[username]
+	ratingJson, err := json.Marshal(RatingResponse{
+		Username:  username,
+		Rating:    current.Rating,
+		Deviation: current.Deviation,
+		Band:      rating.Band(current),
+		History:   history,
+	})
+	if err != nil {
+		s.handle500Err(w, " unable to marshal user rating")
+		return
+	}
+	w.Write(ratingJson)
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/matchmaking"
+)
+
+// ratingsDB records the ratings saved for a game.
+type ratingsDB struct {
+	passDB
+	saved []database.UserRating
+}
+
+func (db *ratingsDB) SaveRatings(gameID int64, ratings []database.UserRating) error {
+	db.saved = ratings
+	return nil
+}
+
+func TestRateGame(t *testing.T) {
+	tests := []struct {
+		name      string
+		standings []database.Standing
+		wantSaved bool
+	}{
+		{
+			name: "winner",
+			standings: []database.Standing{
+				{Username: "player2", Rank: 1, Won: true},
+				{Username: "captainnobody1", Rank: 2, Host: true},
+			},
+			wantSaved: true,
+		},
+		{
+			name: "nobody won",
+			standings: []database.Standing{
+				{Username: "captainnobody1", Rank: 1, Host: true},
+				{Username: "player2", Rank: 1},
+			},
+		},
+		{
+			name:      "played alone",
+			standings: []database.Standing{{Username: "captainnobody1", Rank: 1, Host: true, Won: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := new(ratingsDB)
+			s := State{db: db}
+			if err := s.rateGame(database.Game{GameID: 1}, tt.standings); err != nil {
+				t.Fatal(err)
+			}
+			if (db.saved != nil) != tt.wantSaved {
+				t.Fatalf("got saved ratings %+v, want saved %v", db.saved, tt.wantSaved)
+			}
+			if !tt.wantSaved {
+				return
+			}
+			for _, r := range db.saved {
+				// player2 is new and beat a settled, higher rated host
+				if r.Username == "player2" && r.Rating <= 1500 {
+					t.Errorf("winner lost rating: %+v", r)
+				}
+				if r.Username == "captainnobody1" && r.Rating >= 1720 {
+					t.Errorf("loser gained rating: %+v", r)
+				}
+			}
+		})
+	}
+}
+
+func TestUserRating(t *testing.T) {
+	s := State{db: new(passDB)}
+	r := chi.NewRouter()
+	r.Get("/users/{username}/rating", s.getUserRating)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/users/captainnobody1/rating", nil)
+	req.Header.Set("Authorization", getAuthHeader())
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	var got RatingResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Rating != 1720 || got.Band != "1600-1799" || len(got.History) != 1 {
+		t.Errorf("wrong rating: %+v", got)
+	}
+}
+
+func TestRatedMatchmaking(t *testing.T) {
+	s := State{db: new(passDB)}
+	s.matchmaker = matchmaking.NewQueue(matchmaking.DefaultStrategy, s.createMatchGame)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/matchmaking/join?band=rated", nil)
+	req.Header.Set("Authorization", getAuthHeader())
+	s.joinQueue(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusAccepted)
+	}
+	if got := s.matchmaker.Status("captainnobody1").SkillBand; got != "rated 1600-1799" {
+		t.Errorf("wrong skill band: got %q", got)
+	}
+}
+
+func TestRatingLeaderboardFilter(t *testing.T) {
+	for query, wantErr := range map[string]bool{
+		"by=rating":                 false,
+		"by=rating&period=weekly":   true,
+		"by=rating&category=sports": true,
+		"by=luck":                   true,
+	} {
+		filter, err := leaderboardFilter(httptest.NewRequest("GET", "/leaderboard?"+query, nil))
+		if (err != nil) != wantErr {
+			t.Errorf("%q: got error %v, want error %v", query, err, wantErr)
+		}
+		if err == nil && !filter.ByRating {
+			t.Errorf("%q: not ranked by rating", query)
+		}
+	}
+}
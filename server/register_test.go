@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/credentials"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// takenDB already has the first few usernames it is asked to register.
+type takenDB struct {
+	passDB
+	taken    int
+	username string
+	password string
+}
+
+func (db *takenDB) UpsertUsername(username, password string) error {
+	if db.taken > 0 {
+		db.taken--
+		return database.ErrUsernameTaken
+	}
+	db.username, db.password = username, password
+	return nil
+}
+
+func registerRouter(s State) *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/register", s.registerUser)
+	r.Get("/register/{username}/update", s.updateUsername)
+	return r
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name string
+		db   database.Connection
+		path string
+		want int
+	}{
+		{"chosen password", new(takenDB), "/register/captainnobody1/update?password=Sup3r-Secret-Pass", http.StatusOK},
+		{"weak password", new(takenDB), "/register/captainnobody1/update?password=password", http.StatusBadRequest},
+		{"username taken", &takenDB{taken: 1}, "/register/captainnobody1/update", http.StatusConflict},
+		{"invalid username", new(takenDB), "/register/a/update", http.StatusBadRequest},
+		{"random username", &takenDB{taken: 3}, "/register", http.StatusOK},
+		{"no free usernames", &takenDB{taken: usernameAttempts}, "/register", http.StatusInternalServerError},
+		{"db error", new(failDB), "/register", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := State{db: tt.db}
+			w := httptest.NewRecorder()
+			registerRouter(s).ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.want {
+				t.Errorf("got status %d want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRegisterReturnsCredentials(t *testing.T) {
+	db := &takenDB{taken: 2}
+	s := State{db: db, PasswordPolicy: credentials.PasswordPolicy{Length: 12, RequireDigit: true}}
+	w := httptest.NewRecorder()
+	registerRouter(s).ServeHTTP(w, httptest.NewRequest("GET", "/register", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body.String())
+	}
+	if len(db.password) != 12 {
+		t.Errorf("password %q does not follow the configured policy", db.password)
+	}
+	want := "user " + db.username + " registered with password " + db.password
+	if w.Body.String() != want {
+		t.Errorf("got %q want %q", w.Body.String(), want)
+	}
+	if !regexp.MustCompile(`^[A-Z][a-z]+[A-Z][a-z]+[0-9]{4}$`).MatchString(db.username) {
+		t.Errorf("username %q is not adjective+noun+number", db.username)
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// Replay is the timeline of a finished game returned by
+// /game/{gameID}/replay.
+type Replay struct {
+	GameID      int64
+	Host        string // the host the game started with
+	Players     []string
+	Category    string
+	Perspective string // the participant the game is seen as, empty for everyone's view
+	Answer      string // revealed once the timeline has been played
+	Outcome     string
+	Winner      string
+	Duration    time.Duration
+	Events      []ReplayEvent
+}
+
+// ReplayEvent is an event of the game and when it happened.
+type ReplayEvent struct {
+	Event
+	Offset time.Duration // since the game started
+	Mine   bool          // made by or to the participant the game is seen as
+}
+
+// /game/{gameID}/replay?as=...
+// returns the timeline of a finished game, seen as one of its participants
+// if as is given.
+func (s State) getReplay(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	if !access.Game.Ended {
+		http.Error(w, http.StatusText(http.StatusConflict)+", games can only be replayed once they have ended", http.StatusConflict)
+		return
+	}
+	history, err := s.db.GetGameEvents(access.Game.GameID, 0)
+	if err != nil {
+		s.handle500Err(w, " unable to get game history")
+		return
+	}
+	// games started before replays were recorded have no created event
+	// to start the timeline from
+	if len(history) == 0 || history[0].Type != database.GameEventCreated {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", the game was played before replays were recorded", http.StatusNotFound)
+		return
+	}
+	as := strings.TrimSpace(r.URL.Query().Get("as"))
+	if as != "" && !tookPart(history, as) {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+as+" did not take part in the game", http.StatusBadRequest)
+		return
+	}
+	replay := buildReplay(history, as)
+	replayJson, err := json.Marshal(replay)
+	if err != nil {
+		s.handle500Err(w, " unable to marshal replay")
+		return
+	}
+	w.Write(replayJson)
+}
+
+// tookPart reports whether the user hosted or joined the game at any point.
+func tookPart(history []database.GameEvent, username string) bool {
+	for _, e := range history {
+		switch e.Type {
+		case database.GameEventCreated, database.GameEventJoined, database.GameEventHostChanged:
+			if e.Username == username {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// buildReplay turns the game's history, starting with its created event,
+// into its timeline. Seen as a participant, the timeline only covers the
+// time they were in the game, and hosts see the answer when they learn it.
+func buildReplay(history []database.GameEvent, as string) Replay {
+	game := database.FoldGame(history)
+	start := history[0].CreatedAt
+	replay := Replay{
+		GameID:      game.GameID,
+		Host:        history[0].Username,
+		Players:     game.Players,
+		Category:    game.Category,
+		Perspective: as,
+		Answer:      game.Answer,
+		Outcome:     game.Outcome,
+		Duration:    game.EndTime.Sub(start),
+	}
+	// everyone is watching until the participant joins
+	present := as == ""
+	var answer string
+	for _, e := range history {
+		switch {
+		case e.Type == database.GameEventAnswerSet:
+			answer = e.Data.Answer
+		case e.Type == database.GameEventEnded:
+			replay.Winner = e.Username
+		}
+		if e.Username == as && (e.Type == database.GameEventCreated || e.Type == database.GameEventJoined) {
+			present = true
+		}
+		if !present {
+			continue
+		}
+		for _, event := range eventsFromHistory(e) {
+			mine := as != "" && (event.Username == as || (e.Type == database.GameEventRemoved && e.Data.By == as))
+			switch {
+			case mine && event.Type == EventAnswerSet:
+				event.Message = answer
+			case mine && event.Type == EventHostChanged:
+				event.Message += ", the answer is " + answer
+			}
+			replay.Events = append(replay.Events, ReplayEvent{Event: event, Offset: e.CreatedAt.Sub(start), Mine: mine})
+		}
+		// the participant sees nothing after they leave
+		switch {
+		case as == "":
+		case e.Username == as && (e.Type == database.GameEventLeft || e.Type == database.GameEventRemoved):
+			present = false
+		case e.Data.By == as && e.Type == database.GameEventHostChanged:
+			present = false
+		}
+	}
+	return replay
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+var replayStart = time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+
+func at(seconds int) time.Time {
+	return replayStart.Add(time.Duration(seconds) * time.Second)
+}
+
+// replayHistory is a game where player2 takes over from the host and
+// player3 is kicked.
+var replayHistory = []database.GameEvent{
+	{Seq: 1, Type: database.GameEventCreated, Username: "captainnobody1", CreatedAt: at(0), Data: database.GameEventData{Category: "animals"}},
+	{Seq: 2, Type: database.GameEventJoined, Username: "player2", CreatedAt: at(5)},
+	{Seq: 3, Type: database.GameEventAnswerSet, Username: "captainnobody1", CreatedAt: at(10), Data: database.GameEventData{Answer: "a cat"}},
+	{Seq: 4, Type: database.GameEventJoined, Username: "player3", CreatedAt: at(15)},
+	{Seq: 5, Type: database.GameEventHostChanged, Username: "player2", CreatedAt: at(20), Data: database.GameEventData{By: "captainnobody1"}},
+	{Seq: 6, Type: database.GameEventRemoved, Username: "player3", CreatedAt: at(25), Data: database.GameEventData{By: "player2", Reason: "spam"}},
+	{Seq: 7, Type: database.GameEventJoined, Username: "player4", CreatedAt: at(30)},
+	{Seq: 8, Type: database.GameEventQuestionAsked, Username: "player4", CreatedAt: at(40), Data: database.GameEventData{QuestionID: 1, Text: "is it a pet?"}},
+	{Seq: 9, Type: database.GameEventQuestionAnswered, Username: "player2", CreatedAt: at(50), Data: database.GameEventData{QuestionID: 1, Answer: "yes"}},
+	{Seq: 10, Type: database.GameEventGuessed, Username: "player4", CreatedAt: at(60), Data: database.GameEventData{GuessID: 1, Text: "a cat", Correct: true}},
+	{Seq: 11, Type: database.GameEventEnded, Username: "player4", CreatedAt: at(60), Data: database.GameEventData{Outcome: database.OutcomeSolved}},
+}
+
+func TestBuildReplay(t *testing.T) {
+	tests := []struct {
+		name  string
+		as    string
+		first time.Duration
+		want  []string // messages of the events that are the participant's own
+		count int
+	}{
+		{"everyone", "", 5 * time.Second, nil, 11},
+		{"first host", "captainnobody1", 5 * time.Second, []string{"a cat", ""}, 5},
+		{"new host", "player2", 5 * time.Second, []string{"", "player2 is now the host, the answer is a cat", "spam", "yes"}, 11},
+		{"kicked player", "player3", 15 * time.Second, []string{"", "spam"}, 4},
+		{"late player", "player4", 30 * time.Second, []string{"", "is it a pet?", "a cat", database.OutcomeSolved}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay := buildReplay(replayHistory, tt.as)
+			if replay.Answer != "a cat" || replay.Winner != "player4" || replay.Host != "captainnobody1" || replay.Duration != time.Minute {
+				t.Errorf("got %+v", replay)
+			}
+			if len(replay.Events) != tt.count {
+				t.Fatalf("got %d events want %d: %+v", len(replay.Events), tt.count, replay.Events)
+			}
+			if replay.Events[0].Offset != tt.first {
+				t.Errorf("got first event at %s want %s", replay.Events[0].Offset, tt.first)
+			}
+			var mine []string
+			for _, e := range replay.Events {
+				if e.Mine {
+					mine = append(mine, e.Message)
+				}
+				if e.Type == EventAnswerSet && e.Message != "" && tt.as != "captainnobody1" {
+					t.Errorf("%q should not see the answer being set: %+v", tt.as, e)
+				}
+			}
+			if strings.Join(mine, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got own events %q want %q", mine, tt.want)
+			}
+		})
+	}
+}
+
+// replayDB has a game with a history.
+type replayDB struct {
+	passDB
+	ended   bool
+	history []database.GameEvent
+}
+
+func (db *replayDB) GetGameData(gameID int64) (database.Game, error) {
+	game, err := db.passDB.GetGameData(gameID)
+	game.Ended = db.ended
+	return game, err
+}
+
+func (db *replayDB) GetGameEvents(gameID, afterSeq int64) ([]database.GameEvent, error) {
+	return db.history, nil
+}
+
+func TestGetReplay(t *testing.T) {
+	tests := []struct {
+		name string
+		db   database.Connection
+		path string
+		want int
+	}{
+		{"finished game", &replayDB{ended: true, history: replayHistory}, "/game/321/replay", http.StatusOK},
+		{"as a player", &replayDB{ended: true, history: replayHistory}, "/game/321/replay?as=player3", http.StatusOK},
+		{"as someone else", &replayDB{ended: true, history: replayHistory}, "/game/321/replay?as=player9", http.StatusBadRequest},
+		{"game still being played", &replayDB{history: replayHistory}, "/game/321/replay", http.StatusConflict},
+		{"played before replays", &replayDB{ended: true}, "/game/321/replay", http.StatusNotFound},
+		{"started before replays", &replayDB{ended: true, history: replayHistory[1:]}, "/game/321/replay", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &State{db: tt.db}
+			r := chi.NewRouter()
+			r.Route("/game", s.gameRoutes)
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Authorization", getAuthHeader())
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("got status %d want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var replay Replay
+			if err := json.Unmarshal(w.Body.Bytes(), &replay); err != nil {
+				t.Fatal(err)
+			}
+			if replay.Answer != "a cat" || len(replay.Events) == 0 {
+				t.Errorf("got %+v", replay)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
+)
+
+// startRequest is the optional JSON body of POST /game/start.
+type startRequest struct {
+	Private  bool
+	Category string
+	Rules    database.Rules
+}
+
+// gameSettingsFromRequest reads the settings for a new game from the JSON
+// body, if there is one, and then from the query parameters, which take
+// precedence. Rules that are not set get their defaults.
+func gameSettingsFromRequest(r *http.Request) (database.GameSettings, error) {
+	var req startRequest
+	if r.Body != nil {
+		err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req)
+		if err != nil && err != io.EOF {
+			return database.GameSettings{}, errors.New("body must be a JSON object with Private, Category and Rules")
+		}
+	}
+	q := r.URL.Query()
+	if v := q.Get("private"); v != "" {
+		private, err := strconv.ParseBool(v)
+		if err != nil {
+			return database.GameSettings{}, errors.New("private parameter must be true or false")
+		}
+		req.Private = private
+	}
+	if v := q.Get("category"); v != "" {
+		req.Category = v
+	}
+	rules, err := rulesFromQuery(r, req.Rules)
+	if err != nil {
+		return database.GameSettings{}, err
+	}
+	rules = rules.WithDefaults()
+	if err := rules.Validate(); err != nil {
+		return database.GameSettings{}, err
+	}
+	return database.GameSettings{
+		Private:  req.Private,
+		Category: strings.ToLower(strings.TrimSpace(req.Category)),
+		Rules:    rules,
+	}, nil
+}
+
+// rulesFromQuery overrides the rules with any set in the query parameters.
+func rulesFromQuery(r *http.Request, rules database.Rules) (database.Rules, error) {
+	q := r.URL.Query()
+	ints := map[string]*int{
+		"maxQuestions":     &rules.MaxQuestions,
+		"maxPlayers":       &rules.MaxPlayers,
+		"guessesPerPlayer": &rules.GuessesPerPlayer,
+		"timeLimitSeconds": &rules.TimeLimitSeconds,
+		"turnSeconds":      &rules.TurnSeconds,
+		"answerSeconds":    &rules.AnswerSeconds,
+	}
+	for name, rule := range ints {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return rules, fmt.Errorf("%s parameter must be an integer", name)
+			}
+			*rule = n
+		}
+	}
+	bools := map[string]*bool{
+		"roundRobin":      &rules.RoundRobin,
+		"wrongGuessCosts": &rules.WrongGuessCosts,
+	}
+	for name, rule := range bools {
+		if v := q.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return rules, fmt.Errorf("%s parameter must be true or false", name)
+			}
+			*rule = b
+		}
+	}
+	if v := q.Get("vocabulary"); v != "" {
+		rules.Vocabulary = v
+	}
+	return rules, nil
+}
+
+// guessesBy counts the guesses made by the user.
+func guessesBy(guesses []database.Guess, username string) int {
+	var n int
+	for _, g := range guesses {
+		if g.UserID == username {
+			n++
+		}
+	}
+	return n
+}
+
+// stumped reports whether the players can no longer win the game, either
+// because a wrong guess cost the last of their questions, or was made with
+// none left, or because every player has used all their guesses.
+func stumped(game database.Game, guesses []database.Guess) bool {
+	if game.Rules.WrongGuessCosts && game.QuestionsLeft() == 0 {
+		return true
+	}
+	if game.Rules.GuessesPerPlayer == 0 {
+		return false
+	}
+	players := 0
+	for _, p := range game.Players {
+		if p == game.Host {
+			continue
+		}
+		players++
+		if guessesBy(guesses, p) < game.Rules.GuessesPerPlayer {
+			return false
+		}
+	}
+	return players > 0
+}
+
+// endGame ends the game, scores it and lets everyone know how it ended
+// and who won. A game that could not be scored is scored the next time its
+// standings are asked for. Games that have already ended are left as they
+// are and database.ErrGameEnded is returned.
+func (s State) endGame(gameID int64, outcome, winner string) error {
+	if err := s.db.EndGame(gameID, outcome, winner); err != nil {
+		return err
+	}
+	if _, err := s.saveStandings(gameID); err != nil {
+		logging.Component(logGame).ErrorContext(s.context(), "unable to score game", "game_id", gameID, "err", err)
+	}
+	return nil
+}
+
+// expireGames ends games that have run past their time limit. The host
+// wins since nobody guessed the answer in time.
+func (s State) expireGames() {
+	gameIDs, err := s.db.ListExpiredGames()
+	if err != nil {
+		logging.Component(logGame).ErrorContext(s.context(), "unable to list expired games", "err", err)
+		return
+	}
+	for _, gameID := range gameIDs {
+		game, err := s.db.GetGameData(gameID)
+		if err != nil {
+			logging.Component(logGame).ErrorContext(s.context(), "unable to get game", "game_id", gameID, "err", err)
+			continue
+		}
+		if err := s.endGame(gameID, database.OutcomeTimeUp, game.Host); err != nil && !errors.Is(err, database.ErrGameEnded) {
+			logging.Component(logGame).ErrorContext(s.context(), "unable to end expired game", "game_id", gameID, "err", err)
+		}
+	}
+}
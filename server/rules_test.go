@@ -0,0 +1,168 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+func TestGameSettingsFromRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		body    string
+		want    database.Rules
+		wantErr bool
+	}{
+		{name: "defaults", want: database.DefaultRules()},
+		{
+			name:  "query",
+			query: "maxQuestions=10&maxPlayers=3&guessesPerPlayer=2&wrongGuessCosts=true&vocabulary=extended&roundRobin=true&turnSeconds=30",
+			want: database.Rules{MaxQuestions: 10, MaxPlayers: 3, GuessesPerPlayer: 2, WrongGuessCosts: true,
+				Vocabulary: database.VocabularyExtended, RoundRobin: true, TurnSeconds: 30},
+		},
+		{
+			name: "body",
+			body: `{"Category": "Animals", "Rules": {"MaxQuestions": 5, "TimeLimitSeconds": 600}}`,
+			want: database.Rules{MaxQuestions: 5, MaxPlayers: database.DefaultMaxPlayers, Vocabulary: database.VocabularyYesNo, TimeLimitSeconds: 600},
+		},
+		{
+			name:  "query overrides body",
+			query: "maxQuestions=7",
+			body:  `{"Rules": {"MaxQuestions": 5}}`,
+			want:  database.Rules{MaxQuestions: 7, MaxPlayers: database.DefaultMaxPlayers, Vocabulary: database.VocabularyYesNo},
+		},
+		{name: "turn limit without round robin", query: "turnSeconds=30", wantErr: true},
+		{name: "bad bool", query: "roundRobin=maybe", wantErr: true},
+		{name: "bad int", query: "answerSeconds=soon", wantErr: true},
+		{name: "one player", query: "maxPlayers=1", wantErr: true},
+		{name: "unknown vocabulary", query: "vocabulary=maybe", wantErr: true},
+		{name: "bad body", body: `{"Rules": "lots"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/game/start?"+tt.query, strings.NewReader(tt.body))
+			settings, err := gameSettingsFromRequest(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && settings.Rules != tt.want {
+				t.Errorf("wrong rules: got %+v want %+v", settings.Rules, tt.want)
+			}
+		})
+	}
+}
+
+func TestStumped(t *testing.T) {
+	players := []string{"host", "p1", "p2"}
+	tests := []struct {
+		name    string
+		game    database.Game
+		guesses []database.Guess
+		want    bool
+	}{
+		{"no limits", database.Game{Host: "host", Players: players, QuestionCount: 20}, nil, false},
+		{
+			"out of questions when guesses cost",
+			database.Game{Host: "host", Players: players, QuestionCount: 9, WrongGuesses: 1, Rules: database.Rules{MaxQuestions: 10, WrongGuessCosts: true}},
+			nil, true,
+		},
+		{
+			"some guesses left",
+			database.Game{Host: "host", Players: players, Rules: database.Rules{GuessesPerPlayer: 1}},
+			[]database.Guess{{UserID: "p1"}}, false,
+		},
+		{
+			"everyone out of guesses",
+			database.Game{Host: "host", Players: players, Rules: database.Rules{GuessesPerPlayer: 1}},
+			[]database.Guess{{UserID: "p1"}, {UserID: "p2"}}, true,
+		},
+	}
+	for _, tt := range tests {
+		if got := stumped(tt.game, tt.guesses); got != tt.want {
+			t.Errorf("%s: got %v want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// rulesDB is a game with a host, two players, some questions asked and
+// the rules under test.
+type rulesDB struct {
+	passDB
+	rules   database.Rules
+	asked   int64
+	guesses []database.Guess
+	ended   string
+}
+
+func (db *rulesDB) GetGameData(gameID int64) (database.Game, error) {
+	return database.Game{
+		GameID:        gameID,
+		Host:          "host",
+		Players:       []string{"host", "p1", "p2"},
+		Answer:        "gopher",
+		Rules:         db.rules,
+		QuestionCount: db.asked,
+	}, nil
+}
+
+func (db *rulesDB) AddUserToGame(username string, gameID int64) error {
+	if db.rules.MaxPlayers > 0 && db.rules.MaxPlayers <= 3 {
+		return database.ErrGameFull
+	}
+	return nil
+}
+
+func (db *rulesDB) GetGameGuesses(gameID int64) ([]database.Guess, error) {
+	return db.guesses, nil
+}
+
+func (db *rulesDB) EndGame(gameID int64, outcome, winner string) error {
+	db.ended = outcome
+	return nil
+}
+
+func TestRulesEnforced(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      string
+		path      string
+		db        *rulesDB
+		want      int
+		wantEnded string
+	}{
+		{"game full", "spectator", "/game/1/join", &rulesDB{rules: database.Rules{MaxPlayers: 3}}, http.StatusConflict, ""},
+		{"questions left", "p1", "/game/1/ask?question=is+it+big", &rulesDB{rules: database.Rules{MaxQuestions: 5}, asked: 4}, http.StatusOK, ""},
+		{"no questions left", "p1", "/game/1/ask?question=is+it+big", &rulesDB{rules: database.Rules{MaxQuestions: 5}, asked: 5}, http.StatusConflict, ""},
+		{"answer outside vocabulary", "host", "/game/1/answer?questionID=1&answer=sometimes", &rulesDB{}, http.StatusBadRequest, ""},
+		{"extended vocabulary", "host", "/game/1/answer?questionID=1&answer=Sometimes", &rulesDB{rules: database.Rules{Vocabulary: database.VocabularyExtended}}, http.StatusOK, ""},
+		{"last answer leaves a final guess", "host", "/game/1/answer?questionID=1&answer=no", &rulesDB{rules: database.Rules{MaxQuestions: 5, WrongGuessCosts: true}, asked: 5}, http.StatusOK, ""},
+		{"no guesses left", "p1", "/game/1/guess?guess=cat", &rulesDB{rules: database.Rules{GuessesPerPlayer: 1}, guesses: []database.Guess{{UserID: "p1"}}}, http.StatusConflict, ""},
+		{"last guess ends game", "p2", "/game/1/guess?guess=cat", &rulesDB{rules: database.Rules{GuessesPerPlayer: 1}, guesses: []database.Guess{{UserID: "p1"}}}, http.StatusOK, database.OutcomeStumped},
+		{"wrong guess costs last question", "p1", "/game/1/guess?guess=cat", &rulesDB{rules: database.Rules{MaxQuestions: 5, WrongGuessCosts: true}, asked: 4}, http.StatusOK, database.OutcomeStumped},
+		{"correct final guess", "p1", "/game/1/guess?guess=gopher", &rulesDB{rules: database.Rules{MaxQuestions: 5, WrongGuessCosts: true}, asked: 5}, http.StatusOK, database.OutcomeSolved},
+		{"wrong final guess", "p1", "/game/1/guess?guess=cat", &rulesDB{rules: database.Rules{MaxQuestions: 5, WrongGuessCosts: true}, asked: 5}, http.StatusOK, database.OutcomeStumped},
+		{"correct guess", "p1", "/game/1/guess?guess=Gopher", &rulesDB{}, http.StatusOK, database.OutcomeSolved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &State{db: tt.db}
+			r := chi.NewRouter()
+			r.Route("/game", s.gameRoutes)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.SetBasicAuth(tt.user, "password")
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.db.ended != tt.wantEnded {
+				t.Errorf("wrong outcome: got %q want %q", tt.db.ended, tt.wantEnded)
+			}
+		})
+	}
+}
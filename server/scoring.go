@@ -0,0 +1,130 @@
+package server
+
+import (
+	"sort"
+
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
+)
+
+// Points awarded when a game is scored.
+const (
+	// pointsCorrectGuess go to the player who guesses the answer.
+	pointsCorrectGuess = 100
+	// pointsPerQuestionLeft reward solving the game with few questions.
+	pointsPerQuestionLeft = 5
+	// penaltyWrongGuess is taken off for every wrong guess.
+	penaltyWrongGuess = 10
+	// pointsHostStumped go to the host when nobody guesses the answer.
+	pointsHostStumped = 50
+	// pointsPerQuestionAnswered are added for the host for each question
+	// they answered without giving the game away.
+	pointsPerQuestionAnswered = 2
+)
+
+// hostWins reports whether the host wins a game that ended this way
+// without anyone guessing the answer.
+func hostWins(outcome string) bool {
+	return outcome == database.OutcomeStumped || outcome == database.OutcomeTimeUp
+}
+
+// scoreGame works out the standings of an ended game. The player who
+// guessed the answer scores the most, with a bonus for every question they
+// had left, and every wrong guess costs points. If the players were stumped
+// or ran out of time the host scores instead. Games stopped by the host or
+// abandoned have no winner, but wrong guesses still count against players.
+func scoreGame(game database.Game, questions []database.Question, guesses []database.Guess) []database.Standing {
+	order := participants(game, questions, guesses)
+	standings := make(map[string]*database.Standing, len(order))
+	for _, username := range order {
+		standings[username] = &database.Standing{GameID: game.GameID, Username: username}
+	}
+	standings[game.Host].Host = true
+	for _, q := range questions {
+		standings[q.UserID].QuestionsAsked++
+	}
+	solved := false
+	for _, g := range guesses {
+		s := standings[g.UserID]
+		if !g.Correct {
+			s.WrongGuesses++
+			s.Points -= penaltyWrongGuess
+			continue
+		}
+		s.CorrectGuesses++
+		if !solved {
+			solved = true
+			s.Won = true
+			s.Points += pointsCorrectGuess + pointsPerQuestionLeft*int(game.QuestionsLeft())
+		}
+	}
+	if !solved && hostWins(game.Outcome) {
+		host := standings[game.Host]
+		host.Won = true
+		host.Points += pointsHostStumped + pointsPerQuestionAnswered*len(questions)
+	}
+
+	ranked := make([]database.Standing, 0, len(order))
+	for _, username := range order {
+		ranked = append(ranked, *standings[username])
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Points != ranked[j].Points {
+			return ranked[i].Points > ranked[j].Points
+		}
+		return ranked[i].Won && !ranked[j].Won
+	})
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+		if i > 0 && ranked[i].Points == ranked[i-1].Points && ranked[i].Won == ranked[i-1].Won {
+			ranked[i].Rank = ranked[i-1].Rank
+		}
+	}
+	return ranked
+}
+
+// saveStandings scores the ended game and stores the results. The game is
+// only counted in the metrics, rated and awarded by the call that scored
+// it, so racing to score a game does not count it twice.
+func (s State) saveStandings(gameID int64) ([]database.Standing, error) {
+	game, err := s.db.GetGameData(gameID)
+	if err != nil {
+		return nil, err
+	}
+	questions, err := s.db.GetGameQuestions(gameID)
+	if err != nil {
+		return nil, err
+	}
+	guesses, err := s.db.GetGameGuesses(gameID)
+	if err != nil {
+		return nil, err
+	}
+	standings := scoreGame(game, questions, guesses)
+	scored, err := s.db.SaveStandings(game, standings)
+	if err != nil {
+		return nil, err
+	}
+	if !scored {
+		return standings, nil
+	}
+	observeGameEnded(game, questions)
+	if err := s.rateGame(game, standings); err != nil {
+		logging.Component(logGame).ErrorContext(s.context(), "unable to rate game", "game_id", game.GameID, "err", err)
+	}
+	s.awardAchievements(game, standings, guesses)
+	return standings, nil
+}
+
+// gameStandings returns the stored standings of the game, which only has
+// standings once it has ended. Games that were not scored when they ended,
+// e.g. because the database was briefly unavailable, are scored now.
+func (s State) gameStandings(game database.Game) ([]database.Standing, error) {
+	if !game.Ended {
+		return nil, nil
+	}
+	standings, err := s.db.GetGameStandings(game.GameID)
+	if err != nil || len(standings) > 0 {
+		return standings, err
+	}
+	return s.saveStandings(game.GameID)
+}
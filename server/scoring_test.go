@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+func TestScoreGame(t *testing.T) {
+	questions := []database.Question{{UserID: "p1"}, {UserID: "p2"}, {UserID: "p1"}}
+	tests := []struct {
+		name    string
+		game    database.Game
+		guesses []database.Guess
+		want    []database.Standing
+	}{
+		{
+			name:    "solved",
+			game:    database.Game{Host: "host", Players: []string{"host", "p1", "p2"}, QuestionCount: 3, Outcome: database.OutcomeSolved},
+			guesses: []database.Guess{{UserID: "p1"}, {UserID: "p2", Correct: true}},
+			want: []database.Standing{
+				{Username: "p2", Rank: 1, Points: pointsCorrectGuess + pointsPerQuestionLeft*17, Won: true, QuestionsAsked: 1, CorrectGuesses: 1},
+				{Username: "host", Rank: 2, Host: true},
+				{Username: "p1", Rank: 3, Points: -penaltyWrongGuess, QuestionsAsked: 2, WrongGuesses: 1},
+			},
+		},
+		{
+			name:    "fewer questions score more",
+			game:    database.Game{Host: "host", Players: []string{"host", "p1"}, QuestionCount: 19, Outcome: database.OutcomeSolved},
+			guesses: []database.Guess{{UserID: "p1", Correct: true}},
+			want: []database.Standing{
+				{Username: "p1", Rank: 1, Points: pointsCorrectGuess + pointsPerQuestionLeft, Won: true, QuestionsAsked: 2, CorrectGuesses: 1},
+				{Username: "host", Rank: 2, Host: true},
+				{Username: "p2", Rank: 2, QuestionsAsked: 1},
+			},
+		},
+		{
+			name:    "host stumps everyone",
+			game:    database.Game{Host: "host", Players: []string{"host", "p1", "p2"}, QuestionCount: 3, Outcome: database.OutcomeStumped},
+			guesses: []database.Guess{{UserID: "p1"}},
+			want: []database.Standing{
+				{Username: "host", Rank: 1, Points: pointsHostStumped + pointsPerQuestionAnswered*3, Host: true, Won: true},
+				{Username: "p2", Rank: 2, QuestionsAsked: 1},
+				{Username: "p1", Rank: 3, Points: -penaltyWrongGuess, QuestionsAsked: 2, WrongGuesses: 1},
+			},
+		},
+		{
+			name: "stopped has no winner",
+			game: database.Game{Host: "host", Players: []string{"host", "p1", "p2"}, QuestionCount: 3, Outcome: database.OutcomeStopped},
+			want: []database.Standing{
+				{Username: "host", Rank: 1, Host: true},
+				{Username: "p1", Rank: 1, QuestionsAsked: 2},
+				{Username: "p2", Rank: 1, QuestionsAsked: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scoreGame(tt.game, questions, tt.guesses)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d standings want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("standing %d: got %+v want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// standingsDB records the standings saved for a game.
+type standingsDB struct {
+	roleDB
+	saved []database.Standing
+}
+
+func (db *standingsDB) SaveStandings(game database.Game, standings []database.Standing) (bool, error) {
+	if db.saved != nil {
+		return false, nil
+	}
+	db.saved = standings
+	return true, nil
+}
+
+func (db *standingsDB) GetGameStandings(gameID int64) ([]database.Standing, error) {
+	return db.saved, nil
+}
+
+func TestStandingsInStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		ended bool
+		want  bool
+	}{
+		{"playing", false, false},
+		{"ended", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &standingsDB{roleDB: roleDB{ended: tt.ended}}
+			s := &State{db: db}
+			r := chi.NewRouter()
+			r.Route("/game", s.gameRoutes)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/game/1/status", nil)
+			req.SetBasicAuth("player", "password")
+			r.ServeHTTP(w, req)
+
+			var game database.Game
+			if err := json.Unmarshal(w.Body.Bytes(), &game); err != nil {
+				t.Fatalf("unable to read game %q: %v", w.Body.String(), err)
+			}
+			if got := len(game.Standings) > 0; got != tt.want {
+				t.Errorf("got standings %v want %v", game.Standings, tt.want)
+			}
+			if got := len(db.saved) > 0; got != tt.want {
+				t.Errorf("saved standings %v want %v", db.saved, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveStandingsObservesOnce(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(questionsPerGame)
+	observed := func() uint64 {
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return families[0].GetMetric()[0].GetHistogram().GetSampleCount()
+	}
+	before := observed()
+	// a status request racing the end of the game scores it a second time
+	s := State{db: &standingsDB{roleDB: roleDB{ended: true}}}
+	for i := 0; i < 2; i++ {
+		if _, err := s.saveStandings(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := observed() - before; got != 1 {
+		t.Errorf("the game was observed %d times want 1", got)
+	}
+}
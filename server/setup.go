@@ -2,32 +2,51 @@
 package server
 
 import (
-	"expvar"
+	"context"
 	"net/http"
+	"net/netip"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/soypete/golang-cli-game/credentials"
 	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
+	"github.com/soypete/golang-cli-game/matchmaking"
 )
 
 // State is the global state of the server.
 type State struct {
-	db      database.Connection
-	Router  *chi.Mux
-	BaseURL string
-	Port    string
+	db         database.Connection
+	ctx        context.Context // the request or worker run the state was copied for
+	events     *eventHub
+	matchmaker *matchmaking.Queue
+	limiter    *rateLimiter
+	workers    *workerStatus
+	sessions   *sessionTracker
+	startedAt  time.Time
+	Router     *chi.Mux
+	BaseURL    string
+	Port       string
+	// AdminRouter serves pprof, expvar, metrics and the admin API on
+	// AdminAddr. Allowlisted addresses can read pprof, expvar and metrics,
+	// everything else needs an admin user.
+	AdminRouter    *chi.Mux
+	AdminAddr      string
+	AdminAllowlist []netip.Prefix
+	// TrustedProxies are the only addresses whose X-Forwarded-For and
+	// X-Real-IP headers are believed, see clientIP.
+	TrustedProxies []netip.Prefix
+	// PasswordPolicy is used for generated passwords and checked against
+	// passwords users choose.
+	PasswordPolicy credentials.PasswordPolicy
+	// AuditRetention is how long audit log entries are kept by
+	// RunAuditRetention.
+	AuditRetention time.Duration
 }
 
-var (
-	// expvar counters for the number of status codes returned
-	counter200Code expvar.Int
-	counter400Code expvar.Int
-	counter500Code expvar.Int
-)
-
 // NewState creates a new server state.
 func NewState() *State {
 	// create table if not exists
@@ -36,92 +55,153 @@ func NewState() *State {
 	//
 	// curl http://localhost:3000
 	r := chi.NewRouter()
+	s := &State{
+		db:        db,
+		events:    newEventHub(),
+		limiter:   newRateLimiter(DefaultRateLimits),
+		workers:   newWorkerStatus(),
+		sessions:  newSessionTracker(),
+		startedAt: time.Now(),
+		Router:    r,
+		BaseURL:   "http://localhost:3000", // TODO: this should be a config
+		Port:      ":3000",
+
+		AdminAddr:      "localhost:3001",
+		AdminAllowlist: DefaultAdminAllowlist,
+
+		PasswordPolicy: credentials.DefaultPolicy,
+		AuditRetention: DefaultAuditRetention,
+	}
 
 	// add prebuild middleware for all requests
-	r.Use(middleware.Logger)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(s.realIP)
+	r.Use(requestLogger)
+	r.Use(tracingMiddleware)
+	r.Use(metricsMiddleware)
 	r.Use(middleware.Recoverer)
 
-	// add expvars at /debug/vars
-	expvar.Publish("counter200Code", &counter200Code)
-	expvar.Publish("counter400Code", &counter400Code)
-	expvar.Publish("counter500Code", &counter500Code)
-	// add pprof at /debug/
-	r.Mount("/debug", middleware.Profiler())
-
-	// add expvar and db metrics to prometheus
+	// add db metrics to prometheus, request and game metrics are added
+	// once the state exists
 	reg := prometheus.NewRegistry()
 	reg.MustRegister(collectors.NewBuildInfoCollector())
 	reg.MustRegister(collectors.NewDBStatsCollector(db.GetSqlDB(), "postgres"))
-	// TODO: figure out how to add types
-	reg.MustRegister(collectors.NewExpvarCollector(
-		map[string]*prometheus.Desc{
-			"counter200Code": prometheus.NewDesc("expvar_200Status", "number of status 200 api calls", nil, nil),
-			"counter400Code": prometheus.NewDesc("expvar_400status", "number of status 400 api calls", nil, nil),
-			"counter500Code": prometheus.NewDesc("expvar_500status", "number of status 500 api calls", nil, nil),
-		},
-	))
-	// add prometheus endpoint at /metrics. The above collectors will be show
-	// in the reverse order the are registered.
-	r.Mount("/metrics", promhttp.HandlerFor(
-		reg,
-		promhttp.HandlerOpts{
-			// Opt into OpenMetrics to support exemplars.
-			EnableOpenMetrics: true,
-		},
-	))
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("welcome to game server"))
 	})
 
-	s := &State{
-		db:      db,
-		Router:  r,
-		BaseURL: "http://localhost:3000", // TODO: this should be a config
-		Port:    ":3000",
-	}
+	s.registerMetrics(reg)
+	// pprof, expvar and metrics are only served to admins
+	s.AdminRouter = s.adminRoutes(reg)
 
 	// TODO: Change register routes.
-	// /register?username=...&password... (no auth)
 	// user/{username}/get (with auth)
 	// user/{username}/update?password=... (with auth)
 	// user/{username}/delete (with auth)
 
 	// setup routes
 	r.Route("/register", func(r chi.Router) {
+		r.Use(s.rateLimit(classAuth))
+		r.Get("/", s.traced(State.registerUser)) // GET /register?username=...&password=.., both generated if not given
 		// subroutes for register
 		r.Route("/{username}", func(r chi.Router) {
-			r.Get("/get", s.getUsername)          // GET /register/123/get
-			r.Get("/update", s.updateUsername)    // PUT /register/123/update?password=..
-			r.Delete("/delete", s.deleteUsername) // DELETE /register/123/delete //TODO: should this have /delete in the path?
+			r.With(s.middlewareHandler).Get("/get", s.traced(State.getUsername))          // GET /register/123/get
+			r.Get("/update", s.traced(State.updateUsername))                              // PUT /register/123/update?password=..
+			r.With(s.middlewareHandler).Delete("/delete", s.traced(State.deleteUsername)) // DELETE /register/123/delete //TODO: should this have /delete in the path?
+			r.Get("/restore", s.traced(State.restoreUser))                                // GET /register/123/restore, within a week of deleting
+			r.With(s.middlewareHandler).Get("/rename", s.traced(State.renameUser))        // GET /register/123/rename?to=...
+			r.With(s.middlewareHandler).Get("/export", s.traced(State.exportUser))        // GET /register/123/export
 		})
 	})
 
 	// add middleware to /game routes
-	r.With(s.middlewareHandler).Route("/game", func(r chi.Router) {
-		// /start add you to the host role
-		r.Get("/start", s.startGame) // GET /game/start
-		// // subroutes for game
-		r.Route("/{gameID}", func(r chi.Router) {
-			r.Get("/join", s.joinGame)       // GET /game/123/join?
-			r.Get("/status", s.getGameState) // GET /game/123/status
-			r.Get("/play", s.playGame)       // GET /game/123/play?&answer=...
-			// r.Get("/turn", s.TakeTurn) // GET /game/123/turn?action=question/answer&question=...&answer=...
-			// 	// only the host can get the summary
-			// 	r.Get("/summary", s.getSummary) // GET /game/123/summary
-			// 	// only the host can stop the game
-			r.Get("/stop", s.stopGame) // GET /game/123/stop
-		})
-		// /abandoned returns all games that have been abandoned without being finished
-		// r.Get("/abandoned", s.getAbandonedGames) // GET /game/abandoned
-
+	r.With(s.middlewareHandler).Route("/game", s.gameRoutes)
+
+	// profiles, with stats and leaderboards kept up to date as games end
+	r.With(s.middlewareHandler).Route("/users/{username}", func(r chi.Router) {
+		r.Get("/", s.traced(State.getProfile))                      // GET /users/123
+		r.Patch("/", s.traced(State.updateProfile))                 // PATCH /users/123 {"DisplayName": "...", "Avatar": "...", ...}
+		r.Get("/stats", s.traced(State.getUserStats))               // GET /users/123/stats
+		r.Get("/rating", s.traced(State.getUserRating))             // GET /users/123/rating
+		r.Get("/achievements", s.traced(State.getUserAchievements)) // GET /users/123/achievements
+	})
+	r.With(s.middlewareHandler).Get("/leaderboard", s.traced(State.getLeaderboard)) // GET /leaderboard?period=weekly&category=...&by=rating&format=text
+
+	// end games and turns that run out of time
+	go s.runGameTimers(context.Background(), time.Second)
+	// anonymize deleted accounts once they can no longer be restored
+	go s.runAccountCleanup(context.Background(), time.Hour)
+	// broadcast changes to games from their history
+	go s.runEventFeed(context.Background(), 250*time.Millisecond)
+	// forget clients that have stopped making requests
+	go s.runRateLimitCleanup(context.Background(), time.Minute)
+
+	// quick match puts players in a queue that is grouped into new games
+	s.matchmaker = matchmaking.NewQueue(matchmaking.DefaultStrategy, s.tracedMatch)
+	go s.matchmaker.Run(context.Background(), time.Second)
+	r.With(s.middlewareHandler).Route("/matchmaking", func(r chi.Router) {
+		r.With(s.rateLimit(classGameCreation)).Get("/join", s.traced(State.joinQueue)) // GET /matchmaking/join?category=...&band=rated
+		r.Get("/status", s.traced(State.getQueueStatus))                               // GET /matchmaking/status
+		r.Get("/leave", s.traced(State.leaveQueue))                                    // GET /matchmaking/leave
 	})
 
 	return s
 }
 
+// gameRoutes sets up the /game routes. Every action on a single game is
+// checked against the caller's role in that game.
+func (s *State) gameRoutes(r chi.Router) {
+	// /start add you to the host role
+	r.With(s.rateLimit(classGameCreation)).Get("/start", s.traced(State.startGame))  // GET /game/start?private=true&category=...&maxQuestions=10&roundRobin=true...
+	r.With(s.rateLimit(classGameCreation)).Post("/start", s.traced(State.startGame)) // POST /game/start {"Private": true, "Category": "...", "Rules": {...}}
+	// /lobby lists public games that can still be joined
+	r.Get("/lobby", s.traced(State.getLobby)) // GET /game/lobby?phase=...&category=...&sort=...&cursor=...&format=text
+	// // subroutes for game
+	r.Route("/{gameID}", func(r chi.Router) {
+		r.Use(s.rateLimit(classGameAction))
+		r.Use(s.gameCtx)
+		r.With(requireAction(ActionJoin)).Get("/join", s.traced(State.joinGame))       // GET /game/123/join?
+		r.With(requireAction(ActionLeave)).Get("/leave", s.traced(State.leaveGame))    // GET /game/123/leave
+		r.With(requireAction(ActionView)).Get("/status", s.traced(State.getGameState)) // GET /game/123/status
+		r.With(requireAction(ActionView)).Get("/events", s.traced(State.streamEvents)) // GET /game/123/events
+		// only the host can set the answer and answer questions
+		r.With(requireAction(ActionAnswer)).Get("/play", s.traced(State.playGame))         // GET /game/123/play?answer=...
+		r.With(requireAction(ActionAnswer)).Get("/answer", s.traced(State.answerQuestion)) // GET /game/123/answer?questionID=...&answer=...
+		// only players can ask questions and guess
+		r.With(requireAction(ActionAsk)).Get("/ask", s.traced(State.askQuestion))   // GET /game/123/ask?question=...
+		r.With(requireAction(ActionGuess)).Get("/guess", s.traced(State.makeGuess)) // GET /game/123/guess?guess=...
+		// only the host can get the summary until the game ends
+		r.With(requireAction(ActionView)).Get("/summary", s.traced(State.getSummary)) // GET /game/123/summary
+		// anyone that can view a finished game can replay it
+		r.With(requireAction(ActionView)).Get("/replay", s.traced(State.getReplay)) // GET /game/123/replay?as=...
+		// only the host can remove players
+		r.With(requireAction(ActionKick)).Get("/kick", s.traced(State.kickPlayer)) // GET /game/123/kick?username=...&reason=...
+		r.With(requireAction(ActionKick)).Get("/ban", s.traced(State.banPlayer))   // GET /game/123/ban?username=...&reason=...
+		// only the host can invite players to private games
+		r.Route("/invites", func(r chi.Router) {
+			r.Use(requireAction(ActionInvite))
+			r.Get("/", s.traced(State.listInvites))            // GET /game/123/invites
+			r.Get("/new", s.traced(State.createInvite))        // GET /game/123/invites/new?expiresIn=1h&maxUses=5
+			r.Delete("/{token}", s.traced(State.revokeInvite)) // DELETE /game/123/invites/abc
+		})
+		// only the host can stop the game
+		r.With(requireAction(ActionStop)).Get("/stop", s.traced(State.stopGame)) // GET /game/123/stop
+	})
+	// /abandoned returns all games that have been abandoned without being finished
+	// r.Get("/abandoned", s.getAbandonedGames) // GET /game/abandoned
+}
+
+// middlewareHandler only passes requests on to the next handler
+// once they have been authenticated.
 func (s *State) middlewareHandler(next http.Handler) http.Handler {
-	return http.HandlerFunc(s.authMiddleware)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := s.withContext(r.Context())
+		if !st.authMiddleware(w, r) {
+			return
+		}
+		// authenticated users are included in every log of the request
+		username, _, _ := r.BasicAuth()
+		next.ServeHTTP(w, r.WithContext(logging.WithUser(r.Context(), username)))
+	})
 }
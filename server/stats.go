@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+const maxLeaderboardLimit = 100
+
+// weekPattern matches the weekly leaderboard periods, e.g. 2026-W42.
+var weekPattern = regexp.MustCompile(`^\d{4}-W\d{2}$`)
+
+// StatsResponse is a user's stats with the averages worked out.
+type StatsResponse struct {
+	database.UserStats
+	AverageQuestionsToSolve float64
+	GuessAccuracy           float64
+}
+
+// Leaderboard is one of the leaderboards returned by /leaderboard.
+type Leaderboard struct {
+	Period   string
+	Category string
+	ByRating bool
+	Entries  []database.LeaderboardEntry
+}
+
+// /users/{username}/stats
+// returns the games played, hosted and won by the user along with their
+// streaks and how well they guess.
+func (s State) getUserStats(w http.ResponseWriter, r *http.Request) {
+	if !s.statsVisible(w, r) {
+		return
+	}
+	username := chi.URLParam(r, "username")
+	stats, err := s.db.GetUserStats(username)
+	if errors.Is(err, database.ErrUserNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound)+", no user named "+username, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.handle500Err(w, " unable to get user stats")
+		return
+	}
+	statsJson, err := json.Marshal(StatsResponse{
+		UserStats:               stats,
+		AverageQuestionsToSolve: stats.AverageQuestionsToSolve(),
+		GuessAccuracy:           stats.GuessAccuracy(),
+	})
+	if err != nil {
+		s.handle500Err(w, " unable to marshal user stats")
+		return
+	}
+	w.Write(statsJson)
+}
+
+// leaderboardFilter reads the leaderboard to show from the query
+// parameters. The period is all, weekly for the current week, or a week
+// such as 2026-W42. Players can be ranked by=points, the default, or
+// by=rating, which is only kept for all time across every category.
+func leaderboardFilter(r *http.Request) (database.LeaderboardFilter, error) {
+	q := r.URL.Query()
+	filter := database.LeaderboardFilter{
+		Period:   q.Get("period"),
+		Category: strings.ToLower(strings.TrimSpace(q.Get("category"))),
+		Limit:    20,
+	}
+	switch {
+	case filter.Period == "":
+		filter.Period = database.PeriodAllTime
+	case filter.Period == database.PeriodWeekly:
+		filter.Period = database.WeekPeriod(time.Now())
+	case filter.Period == database.PeriodAllTime, weekPattern.MatchString(filter.Period):
+	default:
+		return filter, fmt.Errorf("period parameter must be %s, %s or a week like %s",
+			database.PeriodAllTime, database.PeriodWeekly, database.WeekPeriod(time.Now()))
+	}
+	switch q.Get("by") {
+	case "", "points":
+	case "rating":
+		if filter.Period != database.PeriodAllTime || filter.Category != "" {
+			return filter, errors.New("ratings are only ranked for all time across every category")
+		}
+		filter.ByRating = true
+	default:
+		return filter, errors.New("by parameter must be points or rating")
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxLeaderboardLimit {
+			return filter, fmt.Errorf("limit parameter must be between 1 and %d", maxLeaderboardLimit)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// /leaderboard?period=...&category=...&by=...&limit=...&format=text
+// returns the players with the most points.
+func (s State) getLeaderboard(w http.ResponseWriter, r *http.Request) {
+	filter, err := leaderboardFilter(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest)+", "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := s.db.GetLeaderboard(filter)
+	if err != nil {
+		s.handle500Err(w, " unable to get leaderboard")
+		return
+	}
+	board := Leaderboard{Period: filter.Period, Category: filter.Category, ByRating: filter.ByRating, Entries: entries}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writeLeaderboardText(w, board)
+		return
+	}
+	boardJson, err := json.Marshal(board)
+	if err != nil {
+		s.handle500Err(w, " unable to marshal leaderboard")
+		return
+	}
+	w.Write(boardJson)
+}
+
+// writeLeaderboardText writes the leaderboard as aligned columns for
+// terminal clients.
+func writeLeaderboardText(w http.ResponseWriter, board Leaderboard) {
+	title := board.Period
+	if board.Category != "" {
+		title += " " + board.Category
+	}
+	if len(board.Entries) == 0 {
+		fmt.Fprintf(w, "nobody is on the %s leaderboard yet\n", title)
+		return
+	}
+	if board.ByRating {
+		title += " rating"
+	}
+	fmt.Fprintf(w, "%s leaderboard\n", title)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RANK\tPLAYER\tPOINTS\tWINS\tGAMES\tRATING")
+	for _, e := range board.Entries {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%.0f ± %.0f\n", e.Rank, e.Username, e.Points, e.Wins, e.Games, e.Rating, e.Deviation)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// missingUserDB has no users.
+type missingUserDB struct {
+	passDB
+}
+
+func (db *missingUserDB) GetUserStats(username string) (database.UserStats, error) {
+	return database.UserStats{}, database.ErrUserNotFound
+}
+
+func TestUserStats(t *testing.T) {
+	tests := []struct {
+		name string
+		db   database.Connection
+		want int
+	}{
+		{"found", new(passDB), http.StatusOK},
+		{"missing user", new(missingUserDB), http.StatusNotFound},
+		{"db error", new(failDB), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := State{db: tt.db}
+			r := chi.NewRouter()
+			r.Get("/users/{username}/stats", s.getUserStats)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/users/player2/stats", nil)
+			req.SetBasicAuth("captainnobody1", "password")
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var stats StatsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+				t.Fatal(err)
+			}
+			if stats.Username != "player2" || stats.AverageQuestionsToSolve != 7.5 || stats.GuessAccuracy != 0.75 {
+				t.Errorf("wrong stats: %+v", stats)
+			}
+		})
+	}
+}
+
+func TestLeaderboardFilter(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    database.LeaderboardFilter
+		wantErr bool
+	}{
+		{query: "", want: database.LeaderboardFilter{Period: database.PeriodAllTime, Limit: 20}},
+		{query: "period=weekly&category=Animals", want: database.LeaderboardFilter{Period: database.WeekPeriod(time.Now()), Category: "animals", Limit: 20}},
+		{query: "period=2026-W01&limit=5", want: database.LeaderboardFilter{Period: "2026-W01", Limit: 5}},
+		{query: "period=monthly", wantErr: true},
+		{query: "limit=1000", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := leaderboardFilter(httptest.NewRequest("GET", "/leaderboard?"+tt.query, nil))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("%q: got %+v want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	s := State{db: new(passDB)}
+	w := httptest.NewRecorder()
+	s.getLeaderboard(w, httptest.NewRequest("GET", "/leaderboard?format=text", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "all leaderboard") || !strings.Contains(body, "1     captainnobody1  300") {
+		t.Errorf("wrong text leaderboard:\n%s", body)
+	}
+
+	s = State{db: new(failDB)}
+	w = httptest.NewRecorder()
+	s.getLeaderboard(w, httptest.NewRequest("GET", "/leaderboard", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusInternalServerError)
+	}
+}
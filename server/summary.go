@@ -0,0 +1,131 @@
+package server
+
+import (
+	"time"
+
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// GameSummary is the post-game report returned by /game/{gameID}/summary.
+type GameSummary struct {
+	GameID    int64
+	Host      string
+	Answer    string
+	Ended     bool
+	Winner    string // empty while the game is still being played
+	StartTime time.Time
+	EndTime   time.Time
+	Duration  string
+	Questions []database.Question
+	Guesses   []database.Guess
+	Players   []PlayerStats
+	Standings []database.Standing // final scores, empty until the game ends
+}
+
+// PlayerStats are the per-player totals included in a GameSummary.
+type PlayerStats struct {
+	Username       string
+	QuestionsAsked int
+	Guesses        int
+	CorrectGuesses int
+}
+
+// isParticipant reports whether the user is the host or one of the players
+// of the game.
+func isParticipant(game database.Game, username string) bool {
+	if game.Host == username {
+		return true
+	}
+	for _, player := range game.Players {
+		if player == username {
+			return true
+		}
+	}
+	return false
+}
+
+// canViewSummary reports whether the user is allowed to see the summary.
+// The host can see it at any time, other participants only after the game
+// has ended since it contains the answer.
+func canViewSummary(game database.Game, username string) bool {
+	if game.Host == username {
+		return true
+	}
+	return game.Ended && isParticipant(game, username)
+}
+
+// buildSummary combines the game with its questions and guesses into a
+// GameSummary. The winner is the first player to guess correctly, or the
+// host if the players were stumped or ran out of time. Games stopped by
+// the host or abandoned have no winner.
+func buildSummary(game database.Game, questions []database.Question, guesses []database.Guess) GameSummary {
+	summary := GameSummary{
+		GameID:    game.GameID,
+		Host:      game.Host,
+		Answer:    game.Answer,
+		Ended:     game.Ended,
+		StartTime: game.StartTime,
+		EndTime:   game.EndTime,
+		Questions: questions,
+		Guesses:   guesses,
+	}
+
+	end := game.EndTime
+	if !game.Ended || end.IsZero() {
+		end = time.Now()
+	}
+	if !game.StartTime.IsZero() {
+		summary.Duration = end.Sub(game.StartTime).Round(time.Second).String()
+	}
+
+	order := participants(game, questions, guesses)
+	stats := make(map[string]*PlayerStats, len(order))
+	for _, username := range order {
+		stats[username] = &PlayerStats{Username: username}
+	}
+	for _, q := range questions {
+		stats[q.UserID].QuestionsAsked++
+	}
+	for _, g := range guesses {
+		p := stats[g.UserID]
+		p.Guesses++
+		if g.Correct {
+			p.CorrectGuesses++
+			if summary.Winner == "" {
+				summary.Winner = g.UserID
+			}
+		}
+	}
+	if summary.Winner == "" && game.Ended && hostWins(game.Outcome) {
+		summary.Winner = game.Host
+	}
+	for _, username := range order {
+		summary.Players = append(summary.Players, *stats[username])
+	}
+	return summary
+}
+
+// participants returns the host and the players of a game in join order,
+// followed by anyone who only shows up in its questions or guesses (e.g.
+// they have since left the game).
+func participants(game database.Game, questions []database.Question, guesses []database.Guess) []string {
+	seen := make(map[string]bool)
+	var order []string
+	add := func(username string) {
+		if !seen[username] {
+			seen[username] = true
+			order = append(order, username)
+		}
+	}
+	add(game.Host)
+	for _, username := range game.Players {
+		add(username)
+	}
+	for _, q := range questions {
+		add(q.UserID)
+	}
+	for _, g := range guesses {
+		add(g.UserID)
+	}
+	return order
+}
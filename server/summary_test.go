@@ -0,0 +1,81 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soypete/golang-cli-game/database"
+)
+
+func TestBuildSummary(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	game := database.Game{
+		GameID:    1,
+		Host:      "host",
+		Players:   []string{"host", "p1", "p2"},
+		Answer:    "gopher",
+		StartTime: start,
+		EndTime:   start.Add(90 * time.Second),
+		Ended:     true,
+	}
+	questions := []database.Question{
+		{QuestionText: "is it alive?", Answer: "yes", UserID: "p1"},
+		{QuestionText: "is it blue?", Answer: "yes", UserID: "p2"},
+	}
+
+	t.Run("winner is first correct guess", func(t *testing.T) {
+		guesses := []database.Guess{
+			{GuessText: "whale", UserID: "p1"},
+			{GuessText: "gopher", UserID: "p2", Correct: true},
+		}
+		summary := buildSummary(game, questions, guesses)
+		if summary.Winner != "p2" {
+			t.Errorf("wrong winner: got %q want %q", summary.Winner, "p2")
+		}
+		if summary.Duration != "1m30s" {
+			t.Errorf("wrong duration: got %q want %q", summary.Duration, "1m30s")
+		}
+		want := []PlayerStats{
+			{Username: "host"},
+			{Username: "p1", QuestionsAsked: 1, Guesses: 1},
+			{Username: "p2", QuestionsAsked: 1, Guesses: 1, CorrectGuesses: 1},
+		}
+		if len(summary.Players) != len(want) {
+			t.Fatalf("wrong number of players: got %d want %d", len(summary.Players), len(want))
+		}
+		for i := range want {
+			if summary.Players[i] != want[i] {
+				t.Errorf("wrong stats for player %d: got %+v want %+v", i, summary.Players[i], want[i])
+			}
+		}
+	})
+
+	t.Run("host wins when nobody guesses", func(t *testing.T) {
+		stumped := game
+		stumped.Outcome = database.OutcomeStumped
+		summary := buildSummary(stumped, questions, nil)
+		if summary.Winner != "host" {
+			t.Errorf("wrong winner: got %q want %q", summary.Winner, "host")
+		}
+	})
+
+	t.Run("no winner when stopped", func(t *testing.T) {
+		for _, outcome := range []string{database.OutcomeStopped, database.OutcomeHostLeft} {
+			stopped := game
+			stopped.Outcome = outcome
+			summary := buildSummary(stopped, questions, nil)
+			if summary.Winner != "" {
+				t.Errorf("%s: wrong winner: got %q want none", outcome, summary.Winner)
+			}
+		}
+	})
+
+	t.Run("no winner while playing", func(t *testing.T) {
+		inProgress := game
+		inProgress.Ended = false
+		summary := buildSummary(inProgress, questions, nil)
+		if summary.Winner != "" {
+			t.Errorf("wrong winner: got %q want none", summary.Winner)
+		}
+	})
+}
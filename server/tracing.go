@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/matchmaking"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is looked up on every use so tests can swap the tracer provider.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/soypete/golang-cli-game/server")
+}
+
+// tracingMiddleware starts a span for every request, continuing the
+// caller's trace if the request has W3C trace context headers. The span
+// is named after the route once the router has matched it, which is also
+// when secrets in the path can be masked.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", r.Method),
+			))
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.String("http.target", redactedPath(r)))
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName(r.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(attribute.String("http.route", rctx.RoutePattern()))
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// withContext returns a copy of the state whose database calls and events
+// are traced as part of ctx.
+func (s State) withContext(ctx context.Context) State {
+	s.ctx = ctx
+	if s.db != nil {
+		s.db = database.WithTracing(ctx, s.db)
+	}
+	return s
+}
+
+// context returns the context of the request or worker run the state was
+// copied for.
+func (s State) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// traced adapts a handler so it runs with a copy of the state bound to
+// the request, which also means it sees the state as it is when the
+// request comes in rather than when the route was added.
+func (s *State) traced(h func(State, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h(s.withContext(r.Context()), w, r)
+	}
+}
+
+// tracedMatch creates the game of a match made by the matchmaking queue.
+func (s *State) tracedMatch(m matchmaking.Match) (int64, error) {
+	ctx, span := tracer().Start(context.Background(), "matchmaking.createMatchGame",
+		trace.WithAttributes(attribute.Int("match.players", len(m.Players))))
+	defer span.End()
+	gameID, err := s.withContext(ctx).createMatchGame(m)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return gameID, err
+}
+
+// publish sends the event to everyone watching its game.
+func (s State) publish(e Event) {
+	_, span := tracer().Start(s.context(), "events.publish "+e.Type,
+		trace.WithAttributes(
+			attribute.Int64("game.id", e.GameID),
+			attribute.String("event.type", e.Type),
+		))
+	defer span.End()
+	delivered, dropped := s.events.publish(e)
+	span.SetAttributes(
+		attribute.Int("event.delivered", delivered),
+		attribute.Int("event.dropped", dropped),
+	)
+	if dropped > 0 {
+		span.AddEvent(fmt.Sprintf("%d subscribers were not keeping up", dropped))
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// inMemoryTracing installs a tracer provider that keeps every span in
+// memory for the rest of the test.
+func inMemoryTracing(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return exporter
+}
+
+func spanNamed(spans tracetest.SpanStubs, name string) (tracetest.SpanStub, bool) {
+	for _, span := range spans {
+		if span.Name == name {
+			return span, true
+		}
+	}
+	return tracetest.SpanStub{}, false
+}
+
+func TestTracing(t *testing.T) {
+	exporter := inMemoryTracing(t)
+
+	s := &State{db: new(passDB), events: newEventHub()}
+	r := chi.NewRouter()
+	r.Use(tracingMiddleware)
+	r.Get("/game/{gameID}/stop", s.traced(func(s State, w http.ResponseWriter, r *http.Request) {
+		if _, err := s.db.GetGameData(1); err != nil {
+			t.Fatal(err)
+		}
+		s.publish(Event{Type: EventGameEnded, GameID: 1})
+	}))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/game/1/stop", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	request, ok := spanNamed(spans, "GET /game/{gameID}/stop")
+	if !ok {
+		t.Fatalf("no span for the route in %v", spans)
+	}
+	if request.SpanContext.TraceID().String() != traceID {
+		t.Errorf("request span did not continue the caller's trace, got trace %s", request.SpanContext.TraceID())
+	}
+	for _, name := range []string{"database.GetGameData", "events.publish " + EventGameEnded} {
+		span, ok := spanNamed(spans, name)
+		if !ok {
+			t.Errorf("no %s span in %v", name, spans)
+			continue
+		}
+		if span.Parent.SpanID() != request.SpanContext.SpanID() {
+			t.Errorf("%s span is not a child of the request span", name)
+		}
+	}
+}
+
+func TestEventFeedTracing(t *testing.T) {
+	exporter := inMemoryTracing(t)
+
+	s := State{db: new(passDB), events: newEventHub()}
+	cursors := make(map[int64]int64)
+	// nothing is traced while nobody is watching
+	s.feedEvents(context.Background(), cursors)
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Fatalf("expected no spans, got %v", spans)
+	}
+
+	_, unsubscribe := s.events.subscribe(321)
+	defer unsubscribe()
+	s.events.startFrom(321, 2)
+	s.feedEvents(context.Background(), cursors)
+
+	spans := exporter.GetSpans()
+	run, ok := spanNamed(spans, "worker.eventFeed")
+	if !ok {
+		t.Fatalf("no span for the run in %v", spans)
+	}
+	for _, name := range []string{"database.GetGameEvents", "events.publish " + EventAnswerSet} {
+		span, ok := spanNamed(spans, name)
+		if !ok {
+			t.Errorf("no %s span in %v", name, spans)
+			continue
+		}
+		if span.Parent.SpanID() != run.SpanContext.SpanID() {
+			t.Errorf("%s span is not a child of the run", name)
+		}
+	}
+}
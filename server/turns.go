@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/soypete/golang-cli-game/database"
+	"github.com/soypete/golang-cli-game/logging"
+)
+
+// nextTurn returns the player whose turn comes after current in the order
+// they joined the game. The host never takes a turn. If current is not a
+// player the first player gets the turn, and if there are no other players
+// an empty string is returned.
+func nextTurn(game database.Game, current string) string {
+	var players []string
+	for _, p := range game.Players {
+		if p != game.Host {
+			players = append(players, p)
+		}
+	}
+	if len(players) == 0 {
+		return ""
+	}
+	for i, p := range players {
+		if p == current {
+			return players[(i+1)%len(players)]
+		}
+	}
+	return players[0]
+}
+
+// passTurnOnRemoval is called when a player leaves or is removed from the
+// game. If it was their turn the next player gets it. The game passed in
+// must be from before the player was removed.
+func (s State) passTurnOnRemoval(game database.Game, username string) {
+	if !game.Rules.RoundRobin || game.TurnPlayer != username {
+		return
+	}
+	next := nextTurn(game, username)
+	if next == username {
+		next = ""
+	}
+	if err := s.startTurn(game, next); err != nil {
+		logging.Component(logGame).ErrorContext(s.context(), "unable to start turn", "game_id", game.GameID, "err", err)
+	}
+}
+
+// startTurn gives the turn to ask a question to the player. Everyone
+// hears how long they have from the game's history, see runEventFeed.
+func (s State) startTurn(game database.Game, username string) error {
+	if !game.Rules.RoundRobin || game.Ended {
+		return nil
+	}
+	if err := s.db.SetTurn(game.GameID, username, turnSeconds(game, username)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// turnSeconds returns how long the player has to ask their question.
+// Nobody having the turn has no time limit.
+func turnSeconds(game database.Game, username string) int {
+	if username == "" {
+		return 0
+	}
+	return game.Rules.TurnSeconds
+}
+
+// advanceTurn passes the turn on from the player who currently has it.
+func (s State) advanceTurn(gameID int64) error {
+	game, err := s.db.GetGameData(gameID)
+	if err != nil {
+		return err
+	}
+	return s.startTurn(game, nextTurn(game, game.TurnPlayer))
+}
+
+// runGameTimers ends games, turns and answers that have run out of time
+// every interval until the context is cancelled. Deadlines are stored with
+// the game, so any that passed while the server was down are handled on
+// the first check.
+func (s State) runGameTimers(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		runCtx, span := tracer().Start(ctx, "worker.gameTimers")
+		run := s.withContext(runCtx)
+		run.expireGames()
+		run.expireTurns()
+		span.End()
+		s.workers.ran("gameTimers", interval, start)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireTurns answers questions the host ran out of time to answer and
+// skips players that ran out of time to ask.
+func (s State) expireTurns() {
+	questions, err := s.db.ExpireQuestions()
+	if err != nil {
+		logging.Component(logGame).ErrorContext(s.context(), "unable to expire questions", "err", err)
+	}
+	for _, q := range questions {
+		gameID, _ := strconv.ParseInt(q.GameID, 10, 64)
+		if err := s.advanceTurn(gameID); err != nil {
+			logging.Component(logGame).ErrorContext(s.context(), "unable to advance turn", "game_id", gameID, "err", err)
+		}
+	}
+
+	gameIDs, err := s.db.ListExpiredTurns()
+	if err != nil {
+		logging.Component(logGame).ErrorContext(s.context(), "unable to list expired turns", "err", err)
+		return
+	}
+	for _, gameID := range gameIDs {
+		game, err := s.db.GetGameData(gameID)
+		if err != nil {
+			logging.Component(logGame).ErrorContext(s.context(), "unable to get game", "game_id", gameID, "err", err)
+			continue
+		}
+		next := nextTurn(game, game.TurnPlayer)
+		if err := s.db.SkipTurn(gameID, game.TurnPlayer, next, turnSeconds(game, next)); err != nil {
+			logging.Component(logGame).ErrorContext(s.context(), "unable to skip turn", "game_id", gameID, "err", err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/soypete/golang-cli-game/database"
+)
+
+// turnDB is a round robin game where it is p1's turn. It records every
+// turn that is handed out.
+type turnDB struct {
+	passDB
+	pending bool
+	expired bool
+	turns   []string
+	skipped []string
+}
+
+func (db *turnDB) GetGameData(gameID int64) (database.Game, error) {
+	return database.Game{
+		GameID:          gameID,
+		Host:            "host",
+		Players:         []string{"host", "p1", "p2", "p3"},
+		Answer:          "gopher",
+		Rules:           database.Rules{RoundRobin: true, TurnSeconds: 30},
+		TurnPlayer:      "p1",
+		PendingQuestion: db.pending,
+	}, nil
+}
+
+func (db *turnDB) SetTurn(gameID int64, username string, seconds int) error {
+	db.turns = append(db.turns, username)
+	return nil
+}
+
+func (db *turnDB) SkipTurn(gameID int64, username, next string, seconds int) error {
+	db.skipped = append(db.skipped, username)
+	return db.SetTurn(gameID, next, seconds)
+}
+
+func (db *turnDB) ListExpiredTurns() ([]int64, error) {
+	if db.expired {
+		return []int64{1}, nil
+	}
+	return nil, nil
+}
+
+func TestNextTurn(t *testing.T) {
+	game := database.Game{Host: "host", Players: []string{"host", "p1", "p2"}}
+	tests := []struct {
+		current string
+		want    string
+	}{
+		{"", "p1"},
+		{"p1", "p2"},
+		{"p2", "p1"},
+		{"gone", "p1"},
+	}
+	for _, tt := range tests {
+		if got := nextTurn(game, tt.current); got != tt.want {
+			t.Errorf("nextTurn after %q: got %q want %q", tt.current, got, tt.want)
+		}
+	}
+	if got := nextTurn(database.Game{Host: "host", Players: []string{"host"}}, ""); got != "" {
+		t.Errorf("nextTurn without players: got %q want none", got)
+	}
+}
+
+func TestRoundRobinTurns(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      string
+		path      string
+		pending   bool
+		want      int
+		wantTurns []string
+	}{
+		{"player on turn can ask", "p1", "/game/1/ask?question=is+it+big", false, http.StatusOK, []string{"p1"}},
+		{"player off turn cannot ask", "p2", "/game/1/ask?question=is+it+big", false, http.StatusConflict, nil},
+		{"player cannot ask twice", "p1", "/game/1/ask?question=is+it+big", true, http.StatusConflict, nil},
+		{"answer passes turn", "host", "/game/1/answer?questionID=1&answer=yes", true, http.StatusOK, []string{"p2"}},
+		{"leaving on turn passes it", "p1", "/game/1/leave", false, http.StatusOK, []string{"p2"}},
+		{"leaving off turn keeps it", "p3", "/game/1/leave", false, http.StatusOK, nil},
+		{"kick on turn passes it", "host", "/game/1/kick?username=p1", false, http.StatusOK, []string{"p2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &turnDB{pending: tt.pending}
+			s := &State{db: db}
+			r := chi.NewRouter()
+			r.Route("/game", s.gameRoutes)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.SetBasicAuth(tt.user, "password")
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v: %s", w.Code, tt.want, w.Body.String())
+			}
+			if len(db.turns) != len(tt.wantTurns) {
+				t.Fatalf("wrong turns: got %v want %v", db.turns, tt.wantTurns)
+			}
+			for i := range tt.wantTurns {
+				if db.turns[i] != tt.wantTurns[i] {
+					t.Errorf("wrong turns: got %v want %v", db.turns, tt.wantTurns)
+				}
+			}
+		})
+	}
+}
+
+func TestExpireTurns(t *testing.T) {
+	db := &turnDB{expired: true}
+	s := State{db: db}
+	s.expireTurns()
+	if len(db.turns) != 1 || db.turns[0] != "p2" {
+		t.Errorf("expired turn was not passed on: got %v", db.turns)
+	}
+	if len(db.skipped) != 1 || db.skipped[0] != "p1" {
+		t.Errorf("skipped turn was not recorded: got %v", db.skipped)
+	}
+}
@@ -0,0 +1,70 @@
+// tracing is a module that sets up OpenTelemetry tracing for the server.
+// Spans are only exported when a collector is configured, otherwise the
+// default no-op tracer provider is left in place.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// DefaultServiceName is the service spans are reported under.
+const DefaultServiceName = "golang-cli-game"
+
+// Config says where spans are exported to.
+type Config struct {
+	// Endpoint is the URL of an OTLP/HTTP collector, e.g.
+	// http://localhost:4318. Tracing is off when it is empty.
+	Endpoint    string
+	ServiceName string
+}
+
+// Setup installs the W3C trace context propagator and, if the config has
+// an endpoint, a tracer provider that exports spans to it. The returned
+// function flushes any spans that have not been exported yet and must be
+// called before the program exits.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("tracing endpoint %q must be a URL like http://localhost:4318", cfg.Endpoint)
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint.Host)}
+	if endpoint.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if path := strings.TrimSuffix(endpoint.Path, "/"); path != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(path))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create trace exporter: %w", err)
+	}
+
+	name := cfg.ServiceName
+	if name == "" {
+		name = DefaultServiceName
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(name))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
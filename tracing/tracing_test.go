@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupNoop(t *testing.T) {
+	before := otel.GetTracerProvider()
+	shutdown, err := Setup(context.Background(), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetTracerProvider() != before {
+		t.Error("tracer provider should not change without an endpoint")
+	}
+}
+
+func TestSetupBadEndpoint(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Endpoint: "localhost"}); err == nil {
+		t.Error("expected an error for an endpoint that is not a URL")
+	}
+}